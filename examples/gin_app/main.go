@@ -3,6 +3,8 @@ package main
 import (
 	"net/http"
 
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	"github.com/appoptics/appoptics-apm-go/v1/contrib/aogin"
 	"github.com/gin-gonic/gin"
 )
 
@@ -12,12 +14,27 @@ func main() {
 	router := gin.Default()
 
 	// add AppOptics middleware
-	router.Use(tracer())
+	router.Use(aogin.Middleware())
 
 	router.GET("/", func(c *gin.Context) {
 		c.String(http.StatusOK, "Hello from Gin")
 	})
 
+	// the transaction of this route is named "GET /hello/:name"
+	router.GET("/hello/:name", func(c *gin.Context) {
+		// profile a part of the request
+		p := aogin.TraceFromContext(c).BeginProfile("greeting")
+		defer p.End()
+		c.String(http.StatusOK, "Hello %s", c.Param("name"))
+	})
+
+	// the trace is also bound to the context of the http.Request
+	router.GET("/span", func(c *gin.Context) {
+		l, _ := ao.BeginSpan(c.Request.Context(), "mySpan")
+		defer l.End()
+		c.String(http.StatusOK, "Hello from a span")
+	})
+
 	// By default it serves on :8080 unless a
 	// PORT environment variable was defined.
 	router.Run()
@@ -4,6 +4,7 @@ package ao
 
 import (
 	"context"
+	"io"
 
 	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/config"
 	aolog "github.com/appoptics/appoptics-apm-go/v1/ao/internal/log"
@@ -88,3 +89,47 @@ func SetLogLevel(level string) error {
 func GetLogLevel() string {
 	return aolog.LevelStr[aolog.Level()]
 }
+
+// Logger is the backend where the agent's diagnostic messages are written to.
+// The messages are filtered by the log level (see SetLogLevel) before being
+// passed to the logger, and the fields carry the structured context of the
+// message, e.g., component, connection and error. Implementations must be
+// safe for concurrent use.
+type Logger = aolog.Logger
+
+// LogLevel is the level of a diagnostic message, its String method returns
+// DEBUG, INFO, WARN or ERROR.
+type LogLevel = aolog.LogLevel
+
+// LogFields are the structured context of a diagnostic message.
+type LogFields = aolog.Fields
+
+// SetLogger replaces the logger which the agent's diagnostic messages are
+// written to, e.g., an adapter to the logging library of the application.
+// A nil logger restores the default one, which writes plain texts through the
+// standard library's log package.
+func SetLogger(l Logger) {
+	aolog.SetLogger(l)
+}
+
+// NewJSONLogger returns a logger which writes each diagnostic message to w as
+// a JSON object in a single line. It's also enabled for os.Stderr by setting
+// APPOPTICS_LOG_FORMAT=json.
+func NewJSONLogger(w io.Writer) Logger {
+	return aolog.NewJSONLogger(w)
+}
+
+// SetHostTags sets the static tags of this host or service, e.g., environment,
+// region, version, team or git SHA. The tags are attached to every metrics
+// message, and to the trace entry events as well if APPOPTICS_TRACE_HOST_TAGS
+// is enabled, in their HostTags sub-document. They are merged into the tags
+// defined by APPOPTICS_HOST_TAGS and take precedence for the same names, but
+// replace the tags set by a previous call. The tags with an empty name, a name
+// containing whitespaces or a reserved name, e.g., "Layer" or "Hostname", are
+// discarded.
+//
+// The __Init message is sent when the package is initialized, therefore it
+// only carries the tags defined by APPOPTICS_HOST_TAGS.
+func SetHostTags(tags map[string]string) {
+	config.Refresh(config.WithHostTags(tags))
+}
@@ -3,10 +3,14 @@
 package ao
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"testing"
 	"time"
 
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/config"
+	aolog "github.com/appoptics/appoptics-apm-go/v1/ao/internal/log"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -33,3 +37,40 @@ func TestShutdown(t *testing.T) {
 	defer cancel()
 	assert.False(t, WaitForReady(ctx))
 }
+
+func TestSetHostTags(t *testing.T) {
+	SetHostTags(map[string]string{"env": "test", "team": "apm", "Hostname": "web1"})
+	assert.Equal(t, map[string]string{"env": "test", "team": "apm"}, config.GetHostTags())
+
+	SetHostTags(nil)
+	assert.Equal(t, map[string]string{}, config.GetHostTags())
+}
+
+type testLogger struct {
+	msgs   []string
+	fields []LogFields
+}
+
+func (l *testLogger) Log(level LogLevel, msg string, fields LogFields) {
+	l.msgs = append(l.msgs, level.String()+" "+msg)
+	l.fields = append(l.fields, fields)
+}
+
+func TestSetLogger(t *testing.T) {
+	defer SetLogger(nil)
+	aolog.DisableDedupForTest(t)
+
+	l := &testLogger{}
+	SetLogger(l)
+	aolog.WithFields(aolog.Fields{"component": "test"}).Error("hello")
+	assert.Equal(t, []string{"ERROR hello"}, l.msgs)
+	assert.Equal(t, LogFields{"component": "test"}, l.fields[0])
+
+	var buf bytes.Buffer
+	SetLogger(NewJSONLogger(&buf))
+	aolog.Error("hello json")
+	var m map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+	assert.Equal(t, "hello json", m["msg"])
+	assert.Equal(t, "ERROR", m["level"])
+}
@@ -0,0 +1,77 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+// Package aotest records the events reported by the agent and decodes them into
+// traces, so that applications can assert on their instrumentation in tests:
+//
+//	func TestHandler(t *testing.T) {
+//		rec := aotest.Record()
+//		// ... run the instrumented code, which reports 5 events ...
+//		traces := rec.Stop(5)
+//
+//		root := traces.FindSpan("myApp")
+//		query := traces.FindSpan("dbQuery")
+//		aotest.AssertChild(t, root, query)
+//		aotest.AssertKV(t, query, "Flavor", "postgresql")
+//	}
+//
+// While recording, all requests are sampled and the events are captured instead
+// of being sent to the collector.
+package aotest
+
+import (
+	"time"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/reporter"
+)
+
+const defaultTimeout = 2 * time.Second
+
+// Option customizes a Recorder.
+type Option func(*options)
+
+type options struct {
+	timeout  time.Duration
+	noSample bool
+}
+
+// WithTimeout sets how long the Recorder waits for each event when it's stopped,
+// before giving up waiting for the events which haven't been reported. It is 2
+// seconds by default.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) { o.timeout = timeout }
+}
+
+// WithoutSampling records with sampling disabled, e.g. to test that nothing is
+// reported for unsampled requests.
+func WithoutSampling() Option {
+	return func(o *options) { o.noSample = true }
+}
+
+// Recorder records the events reported by the agent between Record and Stop.
+type Recorder struct {
+	r *reporter.TestReporter
+}
+
+// Record installs a Recorder capturing the events reported by the agent until
+// it's stopped. Only one Recorder may be installed at a time.
+func Record(opts ...Option) *Recorder {
+	o := options{timeout: defaultTimeout}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	ropts := []reporter.TestReporterOption{reporter.TestReporterTimeout(o.timeout)}
+	if o.noSample {
+		ropts = append(ropts, reporter.TestReporterDisableTracing())
+	}
+	return &Recorder{r: reporter.SetTestReporter(ropts...)}
+}
+
+// Stop waits until numEvents events have been reported, or until no event has
+// been reported for the timeout of the Recorder, restores the previous reporter
+// and returns the traces decoded from the events recorded. Events which can't
+// be decoded are ignored, and the events reported after Stop are dropped.
+func (rec *Recorder) Stop(numEvents int) Traces {
+	rec.r.CloseEvents(numEvents)
+	traces, _ := Decode(rec.r.EventBufs)
+	return traces
+}
@@ -0,0 +1,171 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package aotest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	"github.com/appoptics/appoptics-apm-go/v1/ao/aotest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type recordingT struct{ errs []string }
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errs = append(t.errs, fmt.Sprintf(format, args...))
+}
+
+func TestRecord(t *testing.T) {
+	rec := aotest.Record()
+
+	ctx := ao.NewContext(context.Background(), ao.NewTrace("myApp"))
+	l, _ := ao.BeginSpan(ctx, "dbQuery", "Flavor", "postgresql", "RowCount", 3)
+	l.Info("Cached", false)
+	l.Err(errors.New("conn reset"))
+	l.End()
+	ao.EndTrace(ctx)
+
+	traces := rec.Stop(6)
+	assert.Len(t, traces, 1)
+	assert.Len(t, traces[0].Spans, 2)
+
+	root := aotest.AssertSpan(t, traces, "myApp")
+	query := aotest.AssertSpan(t, traces, "dbQuery")
+	assert.Equal(t, root, traces[0].Root)
+	assert.Equal(t, query, root.FindChild("dbQuery"))
+	assert.Nil(t, query.FindChild("other"))
+	aotest.AssertChild(t, root, query)
+	aotest.AssertKV(t, query, "Flavor", "postgresql")
+	aotest.AssertKV(t, query, "RowCount", int64(3))
+	aotest.AssertKV(t, query, "Cached", false)
+	aotest.AssertError(t, query, "error", "conn reset")
+	assert.True(t, query.Ended)
+	assert.Len(t, query.Events, 4)
+	assert.True(t, root.Duration() >= query.Duration())
+
+	// failed assertions
+	rt := &recordingT{}
+	assert.Nil(t, aotest.AssertSpan(rt, traces, "missing"))
+	assert.False(t, aotest.AssertChild(rt, query, root))
+	assert.False(t, aotest.AssertChild(rt, nil, root))
+	assert.False(t, aotest.AssertKV(rt, query, "Flavor", "mysql"))
+	assert.False(t, aotest.AssertKV(rt, query, "Missing", 1))
+	assert.False(t, aotest.AssertKV(rt, nil, "Flavor", "mysql"))
+	assert.False(t, aotest.AssertError(rt, root, "error", "conn reset"))
+	assert.Len(t, rt.errs, 7)
+}
+
+func TestRecordAsync(t *testing.T) {
+	rec := aotest.Record()
+
+	ctx := ao.NewContext(context.Background(), ao.NewTrace("myApp"))
+	ao.Go(ctx, "worker", func(ctx context.Context) {
+		l, _ := ao.BeginSpan(ctx, "task")
+		l.End()
+	})
+	ao.EndTrace(ctx)
+
+	// the worker may end after the trace: Stop waits for its events
+	traces := rec.Stop(6)
+	root := aotest.AssertSpan(t, traces, "myApp")
+	worker := aotest.AssertSpan(t, traces, "worker")
+	aotest.AssertChild(t, root, worker)
+	aotest.AssertChild(t, worker, aotest.AssertSpan(t, traces, "task"))
+}
+
+func TestRecordDetach(t *testing.T) {
+	rec := aotest.Record()
+
+	ctx := ao.NewContext(context.Background(), ao.NewTrace("myApp"))
+	s, _ := ao.Detach(ctx, "worker")
+	ao.EndTrace(ctx)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		s.End()
+	}()
+
+	// the span message of the trace is reported before the exit of its root
+	// span, which waits for the detached span: it's not counted as an event.
+	traces := rec.Stop(4)
+	root := aotest.AssertSpan(t, traces, "myApp")
+	aotest.AssertChild(t, root, aotest.AssertSpan(t, traces, "worker"))
+	assert.True(t, root.Ended)
+	assert.Len(t, root.Events, 2)
+}
+
+func TestRecordLinks(t *testing.T) {
+	rec := aotest.Record()
+
+	ctx := ao.NewContext(context.Background(), ao.NewTrace("producer"))
+	p1, _ := ao.BeginSpan(ctx, "publish1")
+	p2, _ := ao.BeginSpan(ctx, "publish2")
+	links := []string{p1.MetadataString(), p2.MetadataString()}
+	consumer := ao.NewTraceWithOptions("consumer", ao.SpanOptions{Links: links})
+	consumer.End()
+	p1.End()
+	p2.End()
+	ao.EndTrace(ctx)
+
+	traces := rec.Stop(8)
+	require.Len(t, traces, 2)
+	root := traces[1].Root
+	require.NotNil(t, root)
+	assert.Equal(t, "consumer", root.Name)
+	assert.Equal(t, []interface{}{links[0], links[1]}, root.KVs["Link"])
+}
+
+func md(op string) string { return "2B" + "1BF4CAE2B7C4C2BD2C5E5FA5C48EF4C1C4B0C7A6" + op + "01" }
+
+func testEvent(t *testing.T, layer, label, op string, edges []string, kvs ...bson.DocElem) []byte {
+	d := bson.D{{Name: "Layer", Value: layer}, {Name: "Label", Value: label}, {Name: "X-Trace", Value: md(op)}}
+	for _, e := range edges {
+		d = append(d, bson.DocElem{Name: "Edge", Value: e})
+	}
+	b, err := bson.Marshal(append(d, kvs...))
+	require.NoError(t, err)
+	return b
+}
+
+func TestDecodeLinks(t *testing.T) {
+	// the span "batch" follows from the spans "msg1" and "msg2" of the same
+	// trace, and continues from an event which isn't recorded.
+	traces, err := aotest.Decode([][]byte{
+		testEvent(t, "app", "entry", "0000000000000001", nil),
+		testEvent(t, "msg1", "entry", "0000000000000002", []string{"0000000000000001"}),
+		testEvent(t, "msg2", "entry", "0000000000000003", []string{"0000000000000001"}),
+		testEvent(t, "batch", "entry", "0000000000000004",
+			[]string{"00000000000000FF", "0000000000000002", "0000000000000003"},
+			bson.DocElem{Name: "Link", Value: md("0000000000000002")},
+			bson.DocElem{Name: "Link", Value: md("0000000000000003")}),
+	})
+	require.NoError(t, err)
+	require.Len(t, traces, 1)
+
+	batch := aotest.AssertSpan(t, traces, "batch")
+	assert.Nil(t, batch.Parent)
+	assert.Equal(t, []interface{}{md("0000000000000002"), md("0000000000000003")}, batch.KVs["Link"])
+	assert.Empty(t, aotest.AssertSpan(t, traces, "msg1").Children)
+	assert.Empty(t, aotest.AssertSpan(t, traces, "msg2").Children)
+}
+
+func TestRecordWithoutSampling(t *testing.T) {
+	rec := aotest.Record(aotest.WithoutSampling(), aotest.WithTimeout(100*time.Millisecond))
+	ctx := ao.NewContext(context.Background(), ao.NewTrace("myApp"))
+	ao.EndTrace(ctx)
+	assert.Empty(t, rec.Stop(0))
+}
+
+func TestDecode(t *testing.T) {
+	_, err := aotest.Decode([][]byte{{1, 2, 3}})
+	assert.Error(t, err)
+	traces, err := aotest.Decode(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, traces)
+}
@@ -0,0 +1,103 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package aotest
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// TestingT is the subset of testing.TB used by the assertions, e.g. *testing.T.
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+}
+
+type helper interface {
+	Helper()
+}
+
+// AssertSpan asserts that the traces have a span with the name provided, which
+// is returned.
+func AssertSpan(t TestingT, traces Traces, name string) *Span {
+	if h, ok := t.(helper); ok {
+		h.Helper()
+	}
+	s := traces.FindSpan(name)
+	if s == nil {
+		t.Errorf("aotest: no span %q found in %d trace(s)", name, len(traces))
+	}
+	return s
+}
+
+// AssertChild asserts that the child span is a child of the parent span.
+func AssertChild(t TestingT, parent, child *Span) bool {
+	if h, ok := t.(helper); ok {
+		h.Helper()
+	}
+	if parent == nil || child == nil {
+		t.Errorf("aotest: can't assert child of nil span")
+		return false
+	}
+	if child.Parent != parent {
+		t.Errorf("aotest: span %q is not a child of span %q", child.Name, parent.Name)
+		return false
+	}
+	return true
+}
+
+// AssertKV asserts that the span reported the KV provided. Integers are equal if
+// they have the same value, whatever their type.
+func AssertKV(t TestingT, s *Span, key string, value interface{}) bool {
+	if h, ok := t.(helper); ok {
+		h.Helper()
+	}
+	if s == nil {
+		t.Errorf("aotest: can't assert KV %q of nil span", key)
+		return false
+	}
+	v, ok := s.KVs[key]
+	if !ok {
+		t.Errorf("aotest: span %q has no KV %q", s.Name, key)
+		return false
+	}
+	if !reflect.DeepEqual(normalize(v), normalize(value)) {
+		t.Errorf("aotest: span %q has KV %q = %s, expected %s", s.Name, key, format(v), format(value))
+		return false
+	}
+	return true
+}
+
+// AssertError asserts that the span reported an error with the class and message
+// provided.
+func AssertError(t TestingT, s *Span, class, msg string) bool {
+	if h, ok := t.(helper); ok {
+		h.Helper()
+	}
+	if s == nil {
+		t.Errorf("aotest: can't assert error of nil span")
+		return false
+	}
+	for _, err := range s.Errors {
+		if err.Class == class && err.Message == msg {
+			return true
+		}
+	}
+	t.Errorf("aotest: span %q has no error %s: %q, got %v", s.Name, class, msg, s.Errors)
+	return false
+}
+
+// normalize converts integers to int64, as their BSON type depends on their size.
+func normalize(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint())
+	}
+	return v
+}
+
+func format(v interface{}) string {
+	return fmt.Sprintf("%#v", v)
+}
@@ -0,0 +1,239 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package aotest
+
+import (
+	"time"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/eventgraph"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// The keys of the KVs of the error events.
+const (
+	keyErrorClass = "ErrorClass"
+	keyErrorMsg   = "ErrorMsg"
+)
+
+// Event is a decoded event report. The values of a repeated KV, e.g. the Link
+// of a span with several links, are collected into a slice.
+type Event struct {
+	Layer, Label string
+	TraceID      string
+	OpID         string
+	Edges        []string
+	KVs          map[string]interface{}
+	Time         time.Time
+
+	ev *eventgraph.Event
+}
+
+// Error is an error reported by a span.
+type Error struct {
+	Class, Message string
+}
+
+// Span is a span of a trace, decoded from its events.
+type Span struct {
+	Name     string
+	ID       string // the op ID of its entry event
+	Parent   *Span
+	Children []*Span
+	// KVs holds the KVs reported by the entry, info and exit events of the span.
+	KVs    map[string]interface{}
+	Errors []Error
+	Events []*Event
+	Start  time.Time
+	End    time.Time
+	Ended  bool
+}
+
+// Duration returns the duration of the span, or zero if it hasn't ended.
+func (s *Span) Duration() time.Duration {
+	if !s.Ended {
+		return 0
+	}
+	return s.End.Sub(s.Start)
+}
+
+// FindChild returns the first child span with the name provided, or nil.
+func (s *Span) FindChild(name string) *Span {
+	if s == nil {
+		return nil
+	}
+	for _, c := range s.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Trace is a decoded trace. Its spans are in the order of their first event.
+type Trace struct {
+	ID    string
+	Root  *Span
+	Spans []*Span
+}
+
+// FindSpan returns the first span with the name provided, or nil.
+func (t *Trace) FindSpan(name string) *Span {
+	if spans := t.FindSpans(name); len(spans) > 0 {
+		return spans[0]
+	}
+	return nil
+}
+
+// FindSpans returns the spans with the name provided.
+func (t *Trace) FindSpans(name string) []*Span {
+	var spans []*Span
+	for _, s := range t.Spans {
+		if s.Name == name {
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
+// Traces are the traces decoded from the recorded events, in the order of their
+// first event.
+type Traces []*Trace
+
+// FindSpan returns the first span of the traces with the name provided, or nil.
+func (ts Traces) FindSpan(name string) *Span {
+	for _, t := range ts {
+		if s := t.FindSpan(name); s != nil {
+			return s
+		}
+	}
+	return nil
+}
+
+// Decode decodes the BSON events provided into traces. An event which isn't part
+// of a span of its trace, e.g. an info event following from an event which wasn't
+// decoded, belongs to the root span of its trace.
+func Decode(bufs [][]byte) (Traces, error) {
+	var events []*Event
+	for _, buf := range bufs {
+		e, err := decodeEvent(buf)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return buildTraces(events), nil
+}
+
+func decodeEvent(buf []byte) (*Event, error) {
+	var d bson.D
+	if err := bson.Unmarshal(buf, &d); err != nil {
+		return nil, err
+	}
+	ev := eventgraph.Decode(d)
+	e := &Event{
+		Layer:   ev.Layer,
+		Label:   ev.Label,
+		TraceID: ev.TaskID,
+		OpID:    ev.OpID,
+		Edges:   ev.Edges,
+		KVs:     eventgraph.KVMap(ev.KVs),
+		ev:      ev,
+	}
+	if ev.Timestamp != 0 {
+		e.Time = time.Unix(0, ev.Timestamp*int64(time.Microsecond))
+	}
+	return e, nil
+}
+
+// graph resolves the span of each event from its edges: an entry event begins a
+// span which is a child of the span of the event it follows from, other events
+// belong to the span of the event preceding them in that span.
+type graph struct {
+	g      *eventgraph.Graph
+	events map[*eventgraph.Event]*Event
+	spans  map[*Event]*Span
+}
+
+// from returns the event which an event follows from in its span, or the event
+// its span is a child of for an entry event.
+func (g *graph) from(e *Event) (*Event, bool) {
+	if p := g.g.Parent(e.ev); p != nil {
+		return g.events[p], true
+	}
+	return nil, false
+}
+
+// span returns the span of the event, which is resolved on the first call.
+func (g *graph) span(e *Event, roots map[string]*Span) *Span {
+	if s, ok := g.spans[e]; ok {
+		return s
+	}
+	var s *Span
+	if e.ev.IsEntry() {
+		s = &Span{Name: e.Layer, ID: e.OpID, KVs: make(map[string]interface{}), Start: e.Time}
+		g.spans[e] = s
+		if p, ok := g.from(e); ok {
+			s.Parent = g.span(p, roots)
+			s.Parent.Children = append(s.Parent.Children, s)
+		}
+		return s
+	}
+	if p, ok := g.from(e); ok {
+		s = g.span(p, roots)
+	} else if s = roots[e.TraceID]; s == nil {
+		s = &Span{Name: e.Layer, KVs: make(map[string]interface{})}
+	}
+	g.spans[e] = s
+	return s
+}
+
+func buildTraces(events []*Event) Traces {
+	g := &graph{
+		g:      eventgraph.NewGraph(),
+		events: make(map[*eventgraph.Event]*Event),
+		spans:  make(map[*Event]*Span),
+	}
+	for _, e := range events {
+		g.g.Add(e.ev)
+		g.events[e.ev] = e
+	}
+	var traces Traces
+	byID := make(map[string]*Trace)
+	roots := make(map[string]*Span)
+	for _, e := range events {
+		t, ok := byID[e.TraceID]
+		if !ok {
+			t = &Trace{ID: e.TraceID}
+			byID[e.TraceID] = t
+			traces = append(traces, t)
+		}
+		s := g.span(e, roots)
+		if len(s.Events) == 0 {
+			t.Spans = append(t.Spans, s)
+			if t.Root == nil && s.Parent == nil {
+				t.Root = s
+				roots[e.TraceID] = s
+			}
+		}
+		s.Events = append(s.Events, e)
+		addEvent(s, e)
+	}
+	return traces
+}
+
+// addEvent adds the KVs, errors and timing of the event to its span.
+func addEvent(s *Span, e *Event) {
+	if e.Label == "error" {
+		class, _ := e.KVs[keyErrorClass].(string)
+		msg, _ := e.KVs[keyErrorMsg].(string)
+		s.Errors = append(s.Errors, Error{Class: class, Message: msg})
+		return
+	}
+	for k, v := range e.KVs {
+		s.KVs[k] = v
+	}
+	if e.ev.IsExit() {
+		s.End = e.Time
+		s.Ended = true
+	}
+}
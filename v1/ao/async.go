@@ -0,0 +1,140 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package ao
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// AsyncSpanTimeout is the maximum time the exit of a trace waits for the async
+// child spans started by Go or Detach to end. The async spans ending after the
+// trace has been reported are marked with the Async KV.
+var AsyncSpanTimeout = 10 * time.Second
+
+// Go runs f in a new goroutine, in an async child span of the span bound to
+// ctx. The context passed to f is bound to the async span and is not canceled
+// with ctx, so f may keep running once the request served by ctx is over. The
+// trace of ctx waits for f to return before reporting its exit, up to
+// AsyncSpanTimeout.
+func Go(ctx context.Context, spanName string, f func(ctx context.Context), args ...interface{}) {
+	s, sctx := Detach(ctx, spanName, args...)
+	go func() {
+		defer s.End()
+		f(sctx)
+	}()
+}
+
+// Detach starts an async child span of the span bound to ctx, for work handed
+// off to another goroutine, and returns it along with a context bound to it.
+// The returned context is not canceled with ctx. The trace of ctx waits for the
+// span to end before reporting its exit, up to AsyncSpanTimeout.
+func Detach(ctx context.Context, spanName string, args ...interface{}) (Span, context.Context) {
+	s, sctx := BeginSpan(ctx, spanName, args...)
+	if t, ok := TraceFromContext(ctx).(*aoTrace); ok && s.ok() && t.beginAsyncSpan() {
+		s = &asyncSpan{layerSpan: s.(*layerSpan), trace: t}
+		sctx = newSpanContext(sctx, s)
+	}
+	return s, detachedContext{sctx}
+}
+
+// asyncSpan is a span tracked by its trace, which waits for it to end.
+type asyncSpan struct {
+	*layerSpan
+	trace *aoTrace
+	once  sync.Once
+}
+
+// End ends the async span, marking it as Async if its trace has already been
+// reported, and lets the trace report its exit if it is waiting for it.
+func (s *asyncSpan) End(args ...interface{}) {
+	if s.ok() {
+		if !s.trace.ok() {
+			args = append(args, keyAsync, true)
+		}
+		s.layerSpan.End(args...)
+		s.once.Do(s.trace.endAsyncSpan)
+	}
+}
+
+// EndAt ends the async span at the time provided.
+func (s *asyncSpan) EndAt(end time.Time, args ...interface{}) {
+	s.End(append(args, KeyTimestamp, end)...)
+}
+
+// BeginSpan starts a new child span of the async span.
+func (s *asyncSpan) BeginSpan(spanName string, args ...interface{}) Span {
+	return s.BeginSpanWithOptions(spanName, SpanOptions{}, args...)
+}
+
+// BeginSpanWithOptions starts a new child span of the async span, with the
+// options provided.
+func (s *asyncSpan) BeginSpanWithOptions(spanName string, opts SpanOptions, args ...interface{}) Span {
+	if s.ok() {
+		kvs := addKVsFromOpts(opts, args...)
+		return newSpan(s.aoCtx.Copy(), spanName, s, kvs...)
+	}
+	return nullSpan{}
+}
+
+// asyncSpans keeps track of the async spans of a trace.
+type asyncSpans struct {
+	lock    sync.Mutex
+	pending int         // async spans which haven't ended
+	ending  bool        // the trace is waiting for its async spans to end
+	timer   *time.Timer // reports the exit of the trace after AsyncSpanTimeout
+}
+
+// beginAsyncSpan tracks a new async span of the trace. It returns false if the
+// trace has ended, or is waiting for its async spans to end.
+func (t *aoTrace) beginAsyncSpan() bool {
+	t.async.lock.Lock()
+	defer t.async.lock.Unlock()
+	if t.async.ending || !t.ok() {
+		return false
+	}
+	t.async.pending++
+	return true
+}
+
+// endAsyncSpan reports the exit of the trace if it was waiting for the last of
+// its async spans to end.
+func (t *aoTrace) endAsyncSpan() {
+	t.async.lock.Lock()
+	t.async.pending--
+	exit := t.async.ending && t.async.pending == 0
+	if exit {
+		t.async.timer.Stop()
+	}
+	t.async.lock.Unlock()
+	if exit {
+		t.reportExit()
+	}
+}
+
+// finish reports the exit of the trace, unless some of its async spans haven't
+// ended yet: it is then reported once they end, or after AsyncSpanTimeout.
+func (t *aoTrace) finish() {
+	t.async.lock.Lock()
+	if t.async.pending > 0 {
+		if !t.async.ending {
+			t.async.ending = true
+			t.httpSpan.end = time.Now()
+			t.async.timer = time.AfterFunc(AsyncSpanTimeout, t.reportExit)
+		}
+		t.async.lock.Unlock()
+		return
+	}
+	t.async.lock.Unlock()
+	t.reportExit()
+}
+
+// detachedContext carries the values of its parent context, but not its
+// deadline or cancellation.
+type detachedContext struct{ parent context.Context }
+
+func (c detachedContext) Deadline() (time.Time, bool)       { return time.Time{}, false }
+func (c detachedContext) Done() <-chan struct{}             { return nil }
+func (c detachedContext) Err() error                        { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
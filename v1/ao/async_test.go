@@ -0,0 +1,93 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package ao_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	g "github.com/appoptics/appoptics-apm-go/v1/ao/internal/graphtest"
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/reporter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGo(t *testing.T) {
+	r := reporter.SetTestReporter()
+
+	tr := ao.NewTrace("test")
+	ctx, cancel := context.WithCancel(ao.NewContext(context.Background(), tr))
+	start, done := make(chan struct{}), make(chan struct{})
+	ao.Go(ctx, "bg", func(ctx context.Context) {
+		<-start
+		// the context of the async span isn't canceled with its parent
+		assert.NoError(t, ctx.Err())
+		child, _ := ao.BeginSpan(ctx, "bgChild")
+		child.End()
+		ao.End(ctx, "k", "v")
+		close(done)
+	})
+	cancel()
+	tr.End()
+	// the exit of the trace waits for the async span to end
+	assert.True(t, tr.IsReporting())
+	close(start)
+	<-done
+
+	r.Close(6)
+	assert.False(t, tr.IsReporting())
+	g.AssertGraph(t, r.EventBufs, 6, g.AssertNodeMap{
+		{"test", "entry"}:    {},
+		{"bg", "entry"}:      {Edges: g.Edges{{"test", "entry"}}},
+		{"bgChild", "entry"}: {Edges: g.Edges{{"bg", "entry"}}},
+		{"bgChild", "exit"}:  {Edges: g.Edges{{"bgChild", "entry"}}},
+		{"bg", "exit"}: {Edges: g.Edges{{"bgChild", "exit"}, {"bg", "entry"}}, Callback: func(n g.Node) {
+			assert.Equal(t, "v", n.Map["k"])
+			assert.NotContains(t, n.Map, "Async")
+		}},
+		{"test", "exit"}: {Edges: g.Edges{{"bg", "exit"}, {"test", "entry"}}},
+	})
+}
+
+func TestDetachTimeout(t *testing.T) {
+	r := reporter.SetTestReporter()
+	defer func(timeout time.Duration) { ao.AsyncSpanTimeout = timeout }(ao.AsyncSpanTimeout)
+	ao.AsyncSpanTimeout = 10 * time.Millisecond
+
+	tr := ao.NewTrace("test")
+	ctx := ao.NewContext(context.Background(), tr)
+	s, sctx := ao.Detach(ctx, "bg")
+	assert.Equal(t, s, ao.FromContext(sctx))
+	tr.End()
+	time.Sleep(50 * time.Millisecond)
+	// the trace has been reported after the timeout
+	assert.False(t, tr.IsReporting())
+	// async spans can't be started once the trace ended
+	late, _ := ao.Detach(ctx, "late")
+	assert.False(t, late.IsReporting())
+	s.End()
+
+	r.Close(4)
+	g.AssertGraph(t, r.EventBufs, 4, g.AssertNodeMap{
+		{"test", "entry"}: {},
+		{"bg", "entry"}:   {Edges: g.Edges{{"test", "entry"}}},
+		{"test", "exit"}:  {Edges: g.Edges{{"test", "entry"}}},
+		// the span ending after its trace is marked as Async
+		{"bg", "exit"}: {Edges: g.Edges{{"bg", "entry"}}, Callback: func(n g.Node) {
+			assert.Equal(t, true, n.Map["Async"])
+		}},
+	})
+}
+
+func TestDetachNoTrace(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s, sctx := ao.Detach(ctx, "bg")
+	assert.False(t, s.IsReporting())
+	cancel()
+	assert.NoError(t, sctx.Err())
+
+	done := make(chan struct{})
+	ao.Go(ctx, "bg", func(ctx context.Context) { close(done) })
+	<-done
+}
@@ -0,0 +1,51 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package ao
+
+// MessageHeader is a header of a message, such as the record headers of Kafka messages.
+type MessageHeader struct {
+	Key   []byte
+	Value []byte
+}
+
+// InjectMetadata sets the metadata md of a Span, e.g. the MetadataString() of a Span
+// returned by BeginPublishSpan, in the carrier provided under the "X-Trace" key.
+// The carrier is left unchanged if md is empty.
+func InjectMetadata(carrier map[string]string, md string) {
+	if carrier != nil && md != "" {
+		carrier[HTTPHeaderName] = md
+	}
+}
+
+// ExtractMetadata returns the metadata found in the carrier provided under the
+// "X-Trace" key, or an empty string if there is none.
+func ExtractMetadata(carrier map[string]string) string {
+	return carrier[HTTPHeaderName]
+}
+
+// InjectMetadataHeaders returns the headers provided with the metadata md of a Span
+// set under the "X-Trace" key, replacing any metadata already there. The headers
+// are returned unchanged if md is empty.
+func InjectMetadataHeaders(headers []MessageHeader, md string) []MessageHeader {
+	if md == "" {
+		return headers
+	}
+	for i, h := range headers {
+		if string(h.Key) == HTTPHeaderName {
+			headers[i].Value = []byte(md)
+			return headers
+		}
+	}
+	return append(headers, MessageHeader{Key: []byte(HTTPHeaderName), Value: []byte(md)})
+}
+
+// ExtractMetadataHeaders returns the metadata found in the headers provided under
+// the "X-Trace" key, or an empty string if there is none.
+func ExtractMetadataHeaders(headers []MessageHeader) string {
+	for _, h := range headers {
+		if string(h.Key) == HTTPHeaderName {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,39 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package ao
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetadataCarrier(t *testing.T) {
+	carrier := map[string]string{}
+	InjectMetadata(carrier, "")
+	assert.Empty(t, carrier)
+	assert.Equal(t, "", ExtractMetadata(carrier))
+	assert.Equal(t, "", ExtractMetadata(nil))
+	InjectMetadata(nil, "md") // no panic
+
+	InjectMetadata(carrier, "md")
+	assert.Equal(t, map[string]string{"X-Trace": "md"}, carrier)
+	assert.Equal(t, "md", ExtractMetadata(carrier))
+}
+
+func TestMetadataHeaders(t *testing.T) {
+	headers := []MessageHeader{{Key: []byte("k"), Value: []byte("v")}}
+	assert.Equal(t, headers, InjectMetadataHeaders(headers, ""))
+	assert.Equal(t, "", ExtractMetadataHeaders(headers))
+	assert.Equal(t, "", ExtractMetadataHeaders(nil))
+
+	headers = InjectMetadataHeaders(headers, "md1")
+	assert.Len(t, headers, 2)
+	assert.Equal(t, "md1", ExtractMetadataHeaders(headers))
+
+	// the metadata is replaced
+	headers = InjectMetadataHeaders(headers, "md2")
+	assert.Len(t, headers, 2)
+	assert.Equal(t, "md2", ExtractMetadataHeaders(headers))
+	assert.Equal(t, "v", string(headers[0].Value))
+}
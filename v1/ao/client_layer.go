@@ -58,3 +58,56 @@ func BeginRPCSpan(ctx context.Context, spanName, protocol, controller, remoteHos
 
 	return l
 }
+
+// BeginPublishSpan returns a Span that reports metadata used by AppOptics to filter message
+// publishing latency heatmaps and charts by span name, messaging system and destination.
+// Parameter "system" specifies the messaging system, such as "kafka" or "nats", and
+// "destination" the topic, queue or subject the message is published to. The message ID,
+// if not empty, and the payload size in bytes, if not negative, are reported as well.
+// The Span's MetadataString() should be sent along with the message, e.g. with InjectMetadata,
+// so that the consumer of the message can continue the trace using BeginConsumeSpan.
+// Call or defer the returned Span's End() to time the client-side latency of publishing.
+func BeginPublishSpan(ctx context.Context, spanName, system, destination, msgID string, size int,
+	args ...interface{}) Span {
+	kvs := mergeKVs(messageKVs("publish", system, destination, msgID, size), args)
+	l, _ := BeginSpan(ctx, spanName, kvs...)
+	return l
+}
+
+// BeginConsumeSpan returns a Span that reports metadata used by AppOptics to filter message
+// processing latency heatmaps and charts by span name, messaging system and destination, along
+// with a context bound to it. Parameter "md" is the metadata sent along with the message by its
+// publisher, e.g. extracted with ExtractMetadata, if any. If ctx is bound to a Span, the returned
+// Span is its child and follows from the publisher of the message. Otherwise the returned Span
+// is a new Trace continuing the trace of the publisher.
+// Call or defer the returned Span's End() to time the processing of the message.
+func BeginConsumeSpan(ctx context.Context, spanName, md, system, destination, msgID string, size int,
+	args ...interface{}) (Span, context.Context) {
+	kvs := mergeKVs(messageKVs("consume", system, destination, msgID, size), args)
+	opts := SpanOptions{Links: []string{md}}
+	if parent, ok := fromContext(ctx); ok && parent.ok() {
+		return BeginSpanWithOptions(ctx, spanName, opts, kvs...)
+	}
+	t := NewTraceFromIDWithOptions(spanName, md, opts, func() KVMap {
+		m := make(KVMap)
+		for i := 0; i+1 < len(kvs); i += 2 {
+			if k, ok := kvs[i].(string); ok {
+				m[k] = kvs[i+1]
+			}
+		}
+		return m
+	})
+	return t, NewContext(ctx, t)
+}
+
+// messageKVs returns the KVs reported by the spans of messaging operations.
+func messageKVs(op, system, destination, msgID string, size int) []interface{} {
+	kvs := []interface{}{"Spec", "msgclient", "Op", op, "Flavor", system, "Destination", destination}
+	if msgID != "" {
+		kvs = append(kvs, "MsgID", msgID)
+	}
+	if size >= 0 {
+		kvs = append(kvs, "PayloadSize", size)
+	}
+	return kvs
+}
@@ -73,3 +73,68 @@ func TestSpans(t *testing.T) {
 		{"myExample", "exit"}: {Edges: g.Edges{{"redis", "exit"}, {"myServiceClient", "exit"}, {"querySpan", "exit"}, {"myExample", "entry"}}},
 	})
 }
+
+func TestMessageSpans(t *testing.T) {
+	r := reporter.SetTestReporter() // enable test reporter
+	ctx := ao.NewContext(context.Background(), ao.NewTrace("producer"))
+
+	// publish a message, sending the metadata of the span along with it
+	headers := map[string]string{}
+	l := ao.BeginPublishSpan(ctx, "publish", "kafka", "orders", "msg1", 42)
+	ao.InjectMetadata(headers, l.MetadataString())
+	l.End()
+	ao.End(ctx)
+
+	// consume the message, continuing the trace of its producer
+	l, cctx := ao.BeginConsumeSpan(context.Background(), "consume", ao.ExtractMetadata(headers),
+		"kafka", "orders", "", -1, "Partition", 3)
+	assert.Equal(t, l, ao.TraceFromContext(cctx))
+	l.End()
+
+	r.Close(6)
+	g.AssertGraph(t, r.EventBufs, 6, g.AssertNodeMap{
+		{"producer", "entry"}: {},
+		{"publish", "entry"}: {Edges: g.Edges{{"producer", "entry"}}, Callback: func(n g.Node) {
+			assert.Equal(t, "msgclient", n.Map["Spec"])
+			assert.Equal(t, "publish", n.Map["Op"])
+			assert.Equal(t, "kafka", n.Map["Flavor"])
+			assert.Equal(t, "orders", n.Map["Destination"])
+			assert.Equal(t, "msg1", n.Map["MsgID"])
+			assert.Equal(t, 42, n.Map["PayloadSize"])
+		}},
+		{"publish", "exit"}:  {Edges: g.Edges{{"publish", "entry"}}},
+		{"producer", "exit"}: {Edges: g.Edges{{"publish", "exit"}, {"producer", "entry"}}},
+		{"consume", "entry"}: {Edges: g.Edges{{"publish", "entry"}}, Callback: func(n g.Node) {
+			assert.Equal(t, "msgclient", n.Map["Spec"])
+			assert.Equal(t, "consume", n.Map["Op"])
+			assert.Equal(t, "FollowsFrom", n.Map["LinkType"])
+			assert.Equal(t, 3, n.Map["Partition"])
+			assert.NotContains(t, n.Map, "MsgID")
+			assert.NotContains(t, n.Map, "PayloadSize")
+		}},
+		{"consume", "exit"}: {Edges: g.Edges{{"consume", "entry"}}},
+	})
+}
+
+func TestConsumeSpanFromContext(t *testing.T) {
+	r := reporter.SetTestReporter() // enable test reporter
+	ctx := ao.NewContext(context.Background(), ao.NewTrace("worker"))
+
+	// a message published by another trace is linked to the child span
+	md := "2B0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF0123456701"
+	l, cctx := ao.BeginConsumeSpan(ctx, "consume", md, "nats", "updates", "", 10)
+	assert.Equal(t, l, ao.FromContext(cctx))
+	l.End()
+	ao.End(ctx)
+
+	r.Close(4)
+	g.AssertGraph(t, r.EventBufs, 4, g.AssertNodeMap{
+		{"worker", "entry"}: {},
+		{"consume", "entry"}: {Edges: g.Edges{{"worker", "entry"}}, Callback: func(n g.Node) {
+			assert.Equal(t, md, n.Map["Link"])
+			assert.Equal(t, 10, n.Map["PayloadSize"])
+		}},
+		{"consume", "exit"}: {Edges: g.Edges{{"consume", "entry"}}},
+		{"worker", "exit"}:  {Edges: g.Edges{{"consume", "exit"}, {"worker", "entry"}}},
+	})
+}
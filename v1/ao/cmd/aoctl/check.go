@@ -0,0 +1,272 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/config"
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/host"
+	aolog "github.com/appoptics/appoptics-apm-go/v1/ao/internal/log"
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/reporter/collector"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+)
+
+const checkUsage = `Usage: aoctl check [-timeout duration]
+
+Check loads the configuration in the same way as the agent, i.e., from the
+APPOPTICS_* environment variables, and validates each value. It then connects
+to the collector with the configured certificate, calls Ping and GetSettings
+with the service key and prints the sampling settings returned, or the reason
+of the failure.
+
+`
+
+// the maximum redirects to follow, which is the same as the agent
+const maxRedirects = 20
+
+func runCheck(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout of each RPC call")
+	if err := parseFlags(fs, args, stderr, checkUsage); err != nil {
+		return err
+	}
+
+	c := &checker{w: stdout, timeout: *timeout}
+	return c.run()
+}
+
+// checker checks the configuration and the connectivity to the collector. The
+// results are printed to w.
+type checker struct {
+	w       io.Writer
+	timeout time.Duration
+
+	addr string
+	conn *grpc.ClientConn
+}
+
+func (c *checker) printf(format string, args ...interface{}) {
+	fmt.Fprintf(c.w, format, args...)
+}
+
+func (c *checker) run() error {
+	invalid := c.checkConfig()
+
+	key := config.GetServiceKey()
+	if !config.IsValidServiceKey(key) {
+		return errors.New("no valid service key, which should be in the format of <token>:<service name>")
+	}
+
+	cert, err := c.certificate()
+	if err != nil {
+		return err
+	}
+	c.printf("\nCollector:\n")
+	if err = c.connect(config.GetCollector(), cert); err != nil {
+		return err
+	}
+	defer func() { c.conn.Close() }()
+
+	ping := func(ctx context.Context, client collector.TraceCollectorClient) (collector.ResultCode, string, error) {
+		r, err := client.Ping(ctx, &collector.PingRequest{ApiKey: key})
+		if err != nil {
+			return 0, "", err
+		}
+		return r.Result, r.Arg, nil
+	}
+	if err = c.call("Ping", cert, ping); err != nil {
+		return err
+	}
+
+	var settings []*collector.OboeSetting
+	getSettings := func(ctx context.Context, client collector.TraceCollectorClient) (collector.ResultCode, string, error) {
+		r, err := client.GetSettings(ctx, &collector.SettingsRequest{
+			ApiKey:        key,
+			ClientVersion: collector.ClientVersion,
+			Identity:      &collector.HostID{Hostname: host.Hostname(), Pid: int32(os.Getpid())},
+		})
+		if err != nil {
+			return 0, "", err
+		}
+		settings = r.Settings
+		return r.Result, r.Arg, nil
+	}
+	if err = c.call("GetSettings", cert, getSettings); err != nil {
+		return err
+	}
+
+	if err = c.printSettings(settings); err != nil {
+		return err
+	}
+	if invalid > 0 {
+		return errors.Errorf("%d invalid configuration value(s) discarded", invalid)
+	}
+	return nil
+}
+
+// checkConfig loads and validates the configuration, and returns the number
+// of invalid values.
+func (c *checker) checkConfig() int {
+	// reload it in case the environment variables have been changed since the
+	// initialization, which has logged the non-default values already.
+	level := aolog.Level()
+	aolog.SetLevel(aolog.ERROR)
+	config.Refresh()
+	aolog.SetLevel(level)
+
+	c.printf("Configuration:\n")
+	invalid := 0
+	for _, e := range config.CheckEnvs() {
+		switch {
+		case !e.Set && !e.Valid:
+			invalid++
+			c.printf("  missing  %s\n", e.Name)
+		case !e.Set:
+		case !e.Valid:
+			invalid++
+			c.printf("  invalid  %s=%q (discarded)\n", e.Name, e.Value)
+		default:
+			c.printf("  ok       %s=%q\n", e.Name, e.Value)
+		}
+	}
+
+	c.printf("\n  collector:   %s\n", config.GetCollector())
+	c.printf("  service key: %s\n", config.MaskServiceKey(config.GetServiceKey()))
+	if path := config.GetTrustedPath(); path != "" {
+		c.printf("  certificate: %s\n", path)
+	} else {
+		c.printf("  certificate: built-in\n")
+	}
+	if config.GetSkipVerify() {
+		c.printf("  certificate verification is skipped\n")
+	}
+	if t := config.GetReporterType(); t != "ssl" {
+		c.printf("  the reporter type is %s, the agent doesn't connect to the collector\n", t)
+	}
+	if config.GetDisabled() {
+		c.printf("  the agent is disabled\n")
+	}
+	return invalid
+}
+
+// certificate returns the configured certificate, or the built-in one.
+func (c *checker) certificate() ([]byte, error) {
+	path := config.GetTrustedPath()
+	if path == "" {
+		return []byte(collector.DefaultCertificate), nil
+	}
+	cert, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the certificate")
+	}
+	return cert, nil
+}
+
+// connect resolves the address and dials the collector.
+func (c *checker) connect(addr string, cert []byte) error {
+	h, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return errors.Wrapf(err, "invalid collector address %q", addr)
+	}
+	ips, err := net.LookupHost(h)
+	if err != nil {
+		return errors.Wrapf(err, "failed to resolve the collector %s", h)
+	}
+	conn, err := collector.Dial(addr, cert, config.GetSkipVerify())
+	if err != nil {
+		return errors.Wrapf(err, "failed to dial the collector %s", addr)
+	}
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	c.addr, c.conn = addr, conn
+	c.printf("  resolved %s to %v\n", h, ips)
+	return nil
+}
+
+// rpc calls a method of the collector and returns its result code and arg.
+type rpc func(ctx context.Context, client collector.TraceCollectorClient) (collector.ResultCode, string, error)
+
+// call calls the method and follows the redirects, if any.
+func (c *checker) call(name string, cert []byte, fn rpc) error {
+	for redirects := 0; ; redirects++ {
+		ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+		start := time.Now()
+		result, arg, err := fn(ctx, collector.NewTraceCollectorClient(c.conn))
+		rtt := time.Since(start)
+		cancel()
+		if err != nil {
+			return errors.Wrapf(err, "%s %s failed", name, c.addr)
+		}
+
+		switch result {
+		case collector.ResultCode_OK:
+			c.printf("  %s %s: OK, rtt=%v\n", name, c.addr, rtt)
+			return nil
+		case collector.ResultCode_REDIRECT:
+			c.printf("  %s %s: redirected to %s\n", name, c.addr, arg)
+			if redirects >= maxRedirects {
+				return errors.Errorf("%s: too many redirects", name)
+			}
+			if err = c.connect(arg, cert); err != nil {
+				return err
+			}
+		case collector.ResultCode_INVALID_API_KEY:
+			return errors.Errorf("%s %s: the service key is rejected by the collector (%v)", name, c.addr, result)
+		default:
+			return errors.Errorf("%s %s: %v %s", name, c.addr, result, arg)
+		}
+	}
+}
+
+func (c *checker) printSettings(settings []*collector.OboeSetting) error {
+	c.printf("\nSettings:\n")
+	hasDefault := false
+	for _, s := range settings {
+		if s.Type == collector.OboeSettingType_DEFAULT_SAMPLE_RATE {
+			hasDefault = true
+		}
+		c.printf("  %v", s.Type)
+		if len(s.Layer) > 0 {
+			c.printf(" layer=%s", s.Layer)
+		}
+		c.printf(" value=%d flags=%s ttl=%ds\n", s.Value, s.Flags, s.Ttl)
+
+		var names []string
+		for name := range s.Arguments {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			c.printf("    %s=%s\n", name, formatArgument(s.Arguments[name]))
+		}
+	}
+	if !hasDefault {
+		return errors.New("no default sampling setting is returned, the agent will not trace")
+	}
+	return nil
+}
+
+// formatArgument formats the argument of a setting, which is a little-endian
+// float64 or int32, e.g., BucketCapacity or MetricsFlushInterval.
+func formatArgument(b []byte) string {
+	switch len(b) {
+	case 8:
+		return fmt.Sprint(math.Float64frombits(binary.LittleEndian.Uint64(b)))
+	case 4:
+		return fmt.Sprint(int32(binary.LittleEndian.Uint32(b)))
+	}
+	return fmt.Sprintf("%x", b)
+}
@@ -0,0 +1,170 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/reporter/collector"
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/reporter/collectortest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testServiceKey = "ae38315f6116585d64d82ec2455aa3ec61e02fee25d286f74ace9e4fea189217:go"
+
+// setEnvs sets the environment variables and returns a function to unset them.
+func setEnvs(envs map[string]string) func() {
+	for k, v := range envs {
+		os.Setenv(k, v)
+	}
+	return func() {
+		for k := range envs {
+			os.Unsetenv(k)
+		}
+	}
+}
+
+// testCollector starts a fake collector and sets the environment variables to
+// connect to it.
+func testCollector(t *testing.T) (*collectortest.Server, func()) {
+	s, err := collectortest.NewServer("localhost:0")
+	require.NoError(t, err)
+	dir, err := ioutil.TempDir("", "aoctl")
+	require.NoError(t, err)
+	cert := filepath.Join(dir, "collector.crt")
+	require.NoError(t, ioutil.WriteFile(cert, s.CertPEM, 0644))
+
+	unset := setEnvs(map[string]string{
+		"APPOPTICS_COLLECTOR":   s.Addr,
+		"APPOPTICS_SERVICE_KEY": testServiceKey,
+		"APPOPTICS_TRUSTEDPATH": cert,
+	})
+	return s, func() {
+		unset()
+		s.Stop()
+		os.RemoveAll(dir)
+	}
+}
+
+func check(args ...string) (string, string, int) {
+	var stdout, stderr bytes.Buffer
+	code := run(append([]string{"check"}, args...), nil, &stdout, &stderr)
+	return stdout.String(), stderr.String(), code
+}
+
+func float64Bytes(f float64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, math.Float64bits(f))
+	return b
+}
+
+func TestCheck(t *testing.T) {
+	s, cleanup := testCollector(t)
+	defer cleanup()
+	s.SetSettings(&collector.OboeSetting{
+		Type:      collector.OboeSettingType_DEFAULT_SAMPLE_RATE,
+		Flags:     []byte("SAMPLE_START,SAMPLE_THROUGH_ALWAYS"),
+		Value:     1000000,
+		Arguments: map[string][]byte{"BucketCapacity": float64Bytes(8), "MetricsFlushInterval": {60, 0, 0, 0}},
+		Ttl:       120,
+	})
+
+	stdout, stderr, code := check()
+	assert.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, `  ok       APPOPTICS_COLLECTOR="`+s.Addr+`"`)
+	assert.Contains(t, stdout, `  ok       APPOPTICS_SERVICE_KEY="ae38********************************************************9217:go"`)
+	assert.NotContains(t, stdout, "APPOPTICS_TRACING_MODE")
+	assert.Contains(t, stdout, "  Ping "+s.Addr+": OK")
+	assert.Contains(t, stdout, "  GetSettings "+s.Addr+": OK")
+	assert.Contains(t, stdout, `
+Settings:
+  DEFAULT_SAMPLE_RATE value=1000000 flags=SAMPLE_START,SAMPLE_THROUGH_ALWAYS ttl=120s
+    BucketCapacity=8
+    MetricsFlushInterval=60
+`)
+	assert.Equal(t, 1, s.Pings())
+	require.Len(t, s.SettingsRequests(), 1)
+	assert.Equal(t, testServiceKey, s.SettingsRequests()[0].ApiKey)
+	assert.Equal(t, collector.ClientVersion, s.SettingsRequests()[0].ClientVersion)
+}
+
+func TestCheckInvalidConfig(t *testing.T) {
+	_, cleanup := testCollector(t)
+	defer cleanup()
+	defer setEnvs(map[string]string{"APPOPTICS_TRACING_MODE": "sometimes"})()
+
+	stdout, stderr, code := check()
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, `  invalid  APPOPTICS_TRACING_MODE="sometimes" (discarded)`)
+	assert.Contains(t, stdout, "Settings:")
+	assert.Contains(t, stderr, "aoctl check: 1 invalid configuration value(s) discarded")
+
+	os.Setenv("APPOPTICS_SERVICE_KEY", "invalid")
+	stdout, stderr, code = check()
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, `  invalid  APPOPTICS_SERVICE_KEY="invalid" (discarded)`)
+	assert.NotContains(t, stdout, "Collector:")
+	assert.Contains(t, stderr, "no valid service key")
+
+	os.Unsetenv("APPOPTICS_SERVICE_KEY")
+	stdout, _, code = check()
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, "  missing  APPOPTICS_SERVICE_KEY\n")
+}
+
+func TestCheckCollectorErrors(t *testing.T) {
+	s, cleanup := testCollector(t)
+	defer cleanup()
+
+	s.Respond(collectortest.Ping, collectortest.Response{Result: collector.ResultCode_INVALID_API_KEY})
+	_, stderr, code := check()
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "Ping "+s.Addr+": the service key is rejected by the collector (INVALID_API_KEY)")
+
+	s.Respond(collectortest.GetSettings, collectortest.Response{Result: collector.ResultCode_TRY_LATER, Arg: "busy"})
+	_, stderr, code = check()
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "GetSettings "+s.Addr+": TRY_LATER busy")
+
+	s.SetSettings()
+	_, stderr, code = check()
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "no default sampling setting")
+
+	// the built-in certificate doesn't verify the fake collector
+	os.Unsetenv("APPOPTICS_TRUSTEDPATH")
+	_, stderr, code = check("-timeout", "5s")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "Ping "+s.Addr+" failed")
+	assert.Contains(t, stderr, "certificate")
+
+	os.Setenv("APPOPTICS_TRUSTEDPATH", "/non-existent.crt")
+	_, stderr, code = check()
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "failed to read the certificate")
+}
+
+func TestCheckRedirect(t *testing.T) {
+	s, cleanup := testCollector(t)
+	defer cleanup()
+	s2, err := collectortest.NewServer("localhost:0")
+	require.NoError(t, err)
+	defer s2.Stop()
+
+	s.Respond(collectortest.Ping, collectortest.Response{Result: collector.ResultCode_REDIRECT, Arg: s2.Addr})
+	stdout, stderr, code := check()
+	assert.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "  Ping "+s.Addr+": redirected to "+s2.Addr)
+	assert.Contains(t, stdout, "  Ping "+s2.Addr+": OK")
+	assert.Contains(t, stdout, "  GetSettings "+s2.Addr+": OK")
+	assert.Equal(t, 1, s2.Pings())
+	assert.Len(t, s2.SettingsRequests(), 1)
+	assert.Empty(t, s.SettingsRequests())
+}
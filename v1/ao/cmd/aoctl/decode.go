@@ -0,0 +1,320 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/eventgraph"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const decodeUsage = `Usage: aoctl decode [-format json|tree] [-kvs] [file ...]
+
+Decode prints the BSON messages of the agent, e.g., events, metrics and status
+messages, as JSON or as a tree of events per task. The tree is resolved from
+the op IDs of the X-Trace and Edge KVs of the events.
+
+Each input is either a raw stream of concatenated BSON documents, e.g., the
+payloads of the UDP datagrams sent to 127.0.0.1:7831, or their hex encoding.
+The standard input is read if no file is provided or the file is "-".
+
+`
+
+func runDecode(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("decode", flag.ContinueOnError)
+	format := fs.String("format", "json", "output format: json or tree")
+	kvs := fs.Bool("kvs", false, "print the KVs of each event in the tree format")
+	if err := parseFlags(fs, args, stderr, decodeUsage); err != nil {
+		return err
+	}
+	if *format != "json" && *format != "tree" {
+		return fmt.Errorf("invalid format: %s", *format)
+	}
+
+	inputs := fs.Args()
+	if len(inputs) == 0 {
+		inputs = []string{"-"}
+	}
+	var docs []bson.D
+	for _, input := range inputs {
+		d, err := decodeInput(input, stdin)
+		if err != nil {
+			return err
+		}
+		docs = append(docs, d...)
+	}
+
+	if *format == "tree" {
+		return printTree(stdout, docs, *kvs)
+	}
+	return printJSON(stdout, docs)
+}
+
+// decodeInput reads and decodes the messages of a file, or stdin for "-".
+func decodeInput(name string, stdin io.Reader) ([]bson.D, error) {
+	var data []byte
+	var err error
+	if name == "-" {
+		data, err = ioutil.ReadAll(stdin)
+	} else {
+		data, err = ioutil.ReadFile(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if name == "-" {
+		name = "stdin"
+	}
+
+	if isHex(data) {
+		if data, err = hex.DecodeString(strings.Join(strings.Fields(string(data)), "")); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	msgs, err := splitMessages(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+
+	docs := make([]bson.D, 0, len(msgs))
+	for i, msg := range msgs {
+		var d bson.D
+		if err := bson.Unmarshal(msg, &d); err != nil {
+			return nil, fmt.Errorf("%s: message #%d: %v", name, i+1, err)
+		}
+		docs = append(docs, d)
+	}
+	return docs, nil
+}
+
+// isHex checks if the data is hex encoded, which may be separated by spaces or
+// newlines. Raw BSON documents always contain the null terminator.
+func isHex(data []byte) bool {
+	found := false
+	for _, c := range data {
+		switch {
+		case c >= '0' && c <= '9', c >= 'a' && c <= 'f', c >= 'A' && c <= 'F':
+			found = true
+		case c == ' ', c == '\t', c == '\r', c == '\n':
+		default:
+			return false
+		}
+	}
+	return found
+}
+
+// splitMessages splits a stream of concatenated BSON documents, each of which
+// starts with its length as a little-endian int32 and ends with a null byte.
+func splitMessages(data []byte) ([][]byte, error) {
+	var msgs [][]byte
+	for offset := 0; offset < len(data); {
+		if len(data)-offset < 5 {
+			return nil, fmt.Errorf("truncated message at offset %d", offset)
+		}
+		n := int(binary.LittleEndian.Uint32(data[offset:]))
+		if n < 5 || n > len(data)-offset || data[offset+n-1] != 0 {
+			return nil, fmt.Errorf("invalid message length %d at offset %d", n, offset)
+		}
+		msgs = append(msgs, data[offset:offset+n])
+		offset += n
+	}
+	return msgs, nil
+}
+
+// orderedDoc is a BSON document which is encoded into a JSON object in the
+// order of its keys. The values of a repeated key, e.g., the Edge of an
+// event, are encoded into an array.
+type orderedDoc bson.D
+
+// MarshalJSON implements json.Marshaler.
+func (d orderedDoc) MarshalJSON() ([]byte, error) {
+	var keys []string
+	values := make(map[string][]interface{})
+	for _, e := range d {
+		if _, ok := values[e.Name]; !ok {
+			keys = append(keys, e.Name)
+		}
+		values[e.Name] = append(values[e.Name], jsonValue(e.Value))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		var v interface{} = values[k]
+		if len(values[k]) == 1 {
+			v = values[k][0]
+		}
+		kb, _ := json.Marshal(k)
+		vb, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(kb)
+		buf.WriteByte(':')
+		buf.Write(vb)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case bson.D:
+		return orderedDoc(v)
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i := range v {
+			a[i] = jsonValue(v[i])
+		}
+		return a
+	}
+	return v
+}
+
+func printJSON(w io.Writer, docs []bson.D) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	for _, d := range docs {
+		if err := enc.Encode(orderedDoc(d)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// treeEvent is an event of a task in the tree.
+type treeEvent struct {
+	*eventgraph.Event
+	index    int
+	children []*treeEvent
+}
+
+// task is the events of a task, i.e., a trace.
+type task struct {
+	id     string
+	events []*treeEvent
+}
+
+func sortEvents(events []*treeEvent) {
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Timestamp != events[j].Timestamp {
+			return events[i].Timestamp < events[j].Timestamp
+		}
+		return events[i].index < events[j].index
+	})
+}
+
+// printTree prints the events of each task as a tree, in which the events of a
+// span are at the same depth and its child spans are indented. The messages
+// which aren't events are printed as JSON afterwards.
+func printTree(w io.Writer, docs []bson.D, withKVs bool) error {
+	var tasks []*task
+	byID := make(map[string]*task)
+	var others []bson.D
+	for i, d := range docs {
+		e := &treeEvent{Event: eventgraph.Decode(d), index: i}
+		if e.TaskID == "" {
+			others = append(others, d)
+			continue
+		}
+		t, ok := byID[e.TaskID]
+		if !ok {
+			t = &task{id: e.TaskID}
+			byID[e.TaskID] = t
+			tasks = append(tasks, t)
+		}
+		t.events = append(t.events, e)
+	}
+
+	for i, t := range tasks {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		printTask(w, t, withKVs)
+	}
+
+	if len(others) > 0 {
+		if len(tasks) > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%d message(s) without X-Trace:\n", len(others))
+		return printJSON(w, others)
+	}
+	return nil
+}
+
+func printTask(w io.Writer, t *task, withKVs bool) {
+	g := eventgraph.NewGraph()
+	byEvent := make(map[*eventgraph.Event]*treeEvent)
+	for _, e := range t.events {
+		g.Add(e.Event)
+		byEvent[e.Event] = e
+	}
+
+	var roots []*treeEvent
+	var start int64
+	for _, e := range t.events {
+		if p := g.Parent(e.Event); p != nil {
+			byEvent[p].children = append(byEvent[p].children, e)
+		} else {
+			roots = append(roots, e)
+		}
+		if e.Timestamp != 0 && (start == 0 || e.Timestamp < start) {
+			start = e.Timestamp
+		}
+	}
+	sortEvents(roots)
+
+	fmt.Fprintf(w, "Task %s (%d events)\n", t.id, len(t.events))
+	visited := make(map[*treeEvent]bool)
+	var walk func(e *treeEvent, depth int)
+	walk = func(e *treeEvent, depth int) {
+		if visited[e] {
+			return
+		}
+		visited[e] = true
+		printEvent(w, e, depth, start, withKVs)
+		sortEvents(e.children)
+		for _, c := range e.children {
+			if c.IsEntry() {
+				walk(c, depth+1)
+			} else {
+				walk(c, depth)
+			}
+		}
+	}
+	for _, e := range roots {
+		walk(e, 1)
+	}
+}
+
+func printEvent(w io.Writer, e *treeEvent, depth int, start int64, withKVs bool) {
+	fmt.Fprintf(w, "%s%s %s %s", strings.Repeat("  ", depth), e.Layer, e.Label, e.OpID)
+	if e.Timestamp != 0 {
+		fmt.Fprintf(w, " +%v", time.Duration(e.Timestamp-start)*time.Microsecond)
+	}
+	if withKVs {
+		for _, kv := range e.KVs {
+			v, err := json.Marshal(jsonValue(kv.Value))
+			if err != nil {
+				v = []byte(fmt.Sprint(kv.Value))
+			}
+			fmt.Fprintf(w, " %s=%s", kv.Name, v)
+		}
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,146 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const testTaskID = "1BF4CAE2B7C4C2BD2C5E5FA5C48EF4C1C4B0C7A6"
+
+func md(op string) string { return "2B" + testTaskID + op + "01" }
+
+func testEvent(t *testing.T, layer, label, op string, ts int64, edges ...string) []byte {
+	d := bson.D{{Name: "Layer", Value: layer}, {Name: "Label", Value: label}, {Name: "X-Trace", Value: md(op)}}
+	for _, e := range edges {
+		d = append(d, bson.DocElem{Name: "Edge", Value: e})
+	}
+	d = append(d, bson.DocElem{Name: "Timestamp_u", Value: ts})
+	b, err := bson.Marshal(d)
+	require.NoError(t, err)
+	return b
+}
+
+// testTrace returns the events of a trace: the span "app" which has a child span
+// "db", and a metrics message.
+func testTrace(t *testing.T) [][]byte {
+	metrics, err := bson.Marshal(bson.D{
+		{Name: "Hostname", Value: "web1"},
+		{Name: "measurements", Value: []interface{}{bson.D{{Name: "name", Value: "RequestCount"}, {Name: "value", Value: int64(3)}}}},
+	})
+	require.NoError(t, err)
+	return [][]byte{
+		testEvent(t, "app", "entry", "0000000000000001", 1000),
+		testEvent(t, "db", "exit", "0000000000000004", 1300, "0000000000000003"),
+		testEvent(t, "db", "entry", "0000000000000003", 1100, "0000000000000002"),
+		testEvent(t, "app", "info", "0000000000000002", 1050, "0000000000000001"),
+		testEvent(t, "app", "exit", "0000000000000005", 1500, "0000000000000002", "0000000000000004"),
+		metrics,
+	}
+}
+
+func decode(t *testing.T, input string, args ...string) (string, string, int) {
+	var stdout, stderr bytes.Buffer
+	code := run(append([]string{"decode"}, args...), strings.NewReader(input), &stdout, &stderr)
+	return stdout.String(), stderr.String(), code
+}
+
+func TestDecodeJSON(t *testing.T) {
+	msgs := testTrace(t)
+	stdout, stderr, code := decode(t, string(bytes.Join(msgs, nil)))
+	require.Equal(t, 0, code, stderr)
+
+	dec := json.NewDecoder(strings.NewReader(stdout))
+	var docs []map[string]interface{}
+	for dec.More() {
+		var m map[string]interface{}
+		require.NoError(t, dec.Decode(&m))
+		docs = append(docs, m)
+	}
+	require.Len(t, docs, 6)
+	assert.Equal(t, "db", docs[1]["Layer"])
+	assert.Equal(t, []interface{}{"0000000000000002", "0000000000000004"}, docs[4]["Edge"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"name": "RequestCount", "value": float64(3)}},
+		docs[5]["measurements"])
+
+	// the keys are in their original order
+	assert.True(t, strings.Index(stdout, `"Layer"`) < strings.Index(stdout, `"Label"`))
+	assert.True(t, strings.Index(stdout, `"Label"`) < strings.Index(stdout, `"X-Trace"`))
+}
+
+func TestDecodeHex(t *testing.T) {
+	msgs := testTrace(t)
+	var lines []string
+	for _, m := range msgs {
+		lines = append(lines, strings.ToUpper(hex.EncodeToString(m)))
+	}
+	stdout, stderr, code := decode(t, strings.Join(lines, "\n")+"\n", "-format", "json")
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, 6, strings.Count(stdout, "\n}\n"))
+}
+
+func TestDecodeTree(t *testing.T) {
+	dir, err := ioutil.TempDir("", "aoctl")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "events.bson")
+	require.NoError(t, ioutil.WriteFile(file, bytes.Join(testTrace(t), nil), 0644))
+
+	stdout, stderr, code := decode(t, "", "-format", "tree", file)
+	require.Equal(t, 0, code, stderr)
+	assert.True(t, strings.HasPrefix(stdout, `Task 1BF4CAE2B7C4C2BD2C5E5FA5C48EF4C1C4B0C7A6 (5 events)
+  app entry 0000000000000001 +0s
+  app info 0000000000000002 +50µs
+    db entry 0000000000000003 +100µs
+    db exit 0000000000000004 +300µs
+  app exit 0000000000000005 +500µs
+
+1 message(s) without X-Trace:
+{
+  "Hostname": "web1",`), stdout)
+
+	stdout, _, code = decode(t, string(testTrace(t)[1]), "-format", "tree", "-kvs")
+	require.Equal(t, 0, code)
+	assert.Contains(t, stdout, "  db exit 0000000000000004 +0s\n")
+}
+
+func TestDecodeErrors(t *testing.T) {
+	msg := testTrace(t)[0]
+
+	_, stderr, code := decode(t, string(msg[:len(msg)-1]))
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "stdin: invalid message length")
+
+	_, stderr, code = decode(t, string(append(msg, 1, 2)))
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "truncated message at offset")
+
+	_, stderr, code = decode(t, "", "-format", "yaml")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "invalid format: yaml")
+
+	_, stderr, code = decode(t, "", "-unknown")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "Usage: aoctl decode")
+
+	_, _, code = decode(t, "", "/non-existent")
+	assert.Equal(t, 1, code)
+}
+
+func TestRunUnknownCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, run([]string{"foo"}, nil, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), `unknown command "foo"`)
+	assert.Contains(t, stderr.String(), "decode")
+}
@@ -0,0 +1,87 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+// Command aoctl is a troubleshooting tool for the AppOptics agent.
+//
+//	aoctl check [-timeout duration]
+//	aoctl decode [-format json|tree] [-kvs] [file ...]
+//
+// Run `aoctl <command> -h` for the usage of a command.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// command is a subcommand of aoctl.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdin io.Reader, stdout, stderr io.Writer) error
+}
+
+var commands = []command{
+	{"check", "check the configuration and the connectivity to the collector", runCheck},
+	{"decode", "decode and print the BSON messages of the agent", runDecode},
+}
+
+// errUsage is returned by a command when its arguments are invalid, after the
+// error and the usage have been printed.
+var errUsage = errors.New("invalid usage")
+
+// parseFlags parses the arguments of a command, which prints the usage to
+// stderr on errors.
+func parseFlags(fs *flag.FlagSet, args []string, stderr io.Writer, usage string) error {
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	return nil
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: aoctl <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", c.name, c.summary)
+	}
+}
+
+// run runs the command and returns the exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+	for _, c := range commands {
+		if c.name != args[0] {
+			continue
+		}
+		err := c.run(args[1:], stdin, stdout, stderr)
+		switch {
+		case err == errUsage:
+			return 2
+		case err != nil:
+			fmt.Fprintf(stderr, "aoctl %s: %v\n", c.name, err)
+			return 1
+		}
+		return 0
+	}
+	if args[0] != "help" && args[0] != "-h" && args[0] != "-help" {
+		fmt.Fprintf(stderr, "aoctl: unknown command %q\n", args[0])
+	}
+	usage(stderr)
+	return 2
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
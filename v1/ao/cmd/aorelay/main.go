@@ -0,0 +1,53 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+// Command aorelay receives the messages sent by the UDP reporters of the
+// processes on this host, i.e., the ones started with APPOPTICS_REPORTER=udp,
+// and relays them to the collector, so that short-lived processes can share a
+// single collector connection:
+//
+//	APPOPTICS_SERVICE_KEY=<token>:<service name> aorelay [-listen 127.0.0.1:7831]
+//
+// It's configured with the same APPOPTICS_* environment variables as the agent,
+// which must use the ssl reporter. It also retrieves the sampling settings and
+// reports the metrics of the relayed transactions, like the agent in a
+// long-running process.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/config"
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/reporter"
+)
+
+func main() {
+	listen := flag.String("listen", config.GetCollectorUDP(), "the UDP address to listen on")
+	timeout := flag.Duration("shutdown-timeout", 10*time.Second, "the timeout to flush the messages on shutdown")
+	flag.Parse()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		cancel()
+	}()
+
+	if err := reporter.RelayUDP(ctx, *listen); err != nil {
+		fmt.Fprintf(os.Stderr, "aorelay: %v\n", err)
+		os.Exit(1)
+	}
+
+	sctx, scancel := context.WithTimeout(context.Background(), *timeout)
+	defer scancel()
+	if err := reporter.Shutdown(sctx); err != nil {
+		fmt.Fprintf(os.Stderr, "aorelay: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -14,6 +14,12 @@ func NewContext(ctx context.Context, t Trace) context.Context {
 	return context.WithValue(context.WithValue(ctx, contextKey, t), contextSpanKey, t)
 }
 
+// NewSpanContext returns a copy of the parent context and associates it with a Span,
+// e.g. to bind a Span started by a tracing API bridge to the context.
+func NewSpanContext(ctx context.Context, l Span) context.Context {
+	return newSpanContext(ctx, l)
+}
+
 // newSpanContext returns a copy of the parent context and associates it with a Span.
 func newSpanContext(ctx context.Context, l Span) context.Context {
 	return context.WithValue(ctx, contextSpanKey, l)
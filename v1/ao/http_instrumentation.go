@@ -8,11 +8,14 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/url"
 	"reflect"
 	"runtime"
 	"runtime/debug"
 	"strings"
 	"time"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/config"
 )
 
 // HTTPHeaderName is a constant for the HTTP header used by AppOptics ("X-Trace") to propagate
@@ -20,6 +23,16 @@ import (
 const HTTPHeaderName = "X-Trace"
 const httpHandlerSpanName = "http.HandlerFunc"
 
+// The prefixes of the keys of the reported HTTP request and response headers,
+// e.g. "Request-Header-User-Agent".
+const (
+	requestHeaderKeyPrefix  = "Request-Header-"
+	responseHeaderKeyPrefix = "Response-Header-"
+)
+
+// maskedQueryValue replaces the values of the masked query parameters
+const maskedQueryValue = "********"
+
 // key used for HTTP span to indicate a new context
 var httpSpanKey = contextKeyT("github.com/appoptics/appoptics-apm-go/v1/ao.HTTPSpan")
 
@@ -119,6 +132,9 @@ func (w *HTTPResponseWriter) WriteHeader(status int) {
 			w.t.AddEndArgs(keyEdge, md)
 		}
 		w.Header().Set(HTTPHeaderName, w.t.ExitMetadata()) // replace downstream MD with ours
+		if !w.WroteHeader {
+			w.t.AddEndArgs(headerKVs(w.Header(), responseHeaderKeyPrefix, config.GetResponseHeaders())...)
+		}
 	}
 	w.WroteHeader = true
 	w.Writer.WriteHeader(status)
@@ -152,12 +168,23 @@ func traceFromHTTPRequest(spanName string, r *http.Request, isNewContext bool, o
 			keyHTTPHost:    r.Host,
 			keyURL:         r.URL.EscapedPath(),
 			keyRemoteHost:  r.RemoteAddr,
-			keyQueryString: r.URL.RawQuery,
+			keyQueryString: maskQueryString(r.URL.RawQuery),
+		}
+		hkvs := headerKVs(r.Header, requestHeaderKeyPrefix, config.GetRequestHeaders())
+		for i := 0; i+1 < len(hkvs); i += 2 {
+			kvs[hkvs[i].(string)] = hkvs[i+1]
 		}
 
 		if so.WithBackTrace {
 			kvs[KeyBackTrace] = string(debug.Stack())
 		}
+		if !so.StartTime.IsZero() {
+			kvs[KeyTimestamp] = so.StartTime
+		}
+		links := linkKVs(so.Links)
+		for i := 0; i+1 < len(links); i += 2 {
+			kvs[links[i].(string)] = links[i+1]
+		}
 
 		return kvs
 	})
@@ -175,8 +202,62 @@ func traceFromHTTPRequest(spanName string, r *http.Request, isNewContext bool, o
 	// Clear the start time if it is not a new context
 	if !isNewContext {
 		t.SetStartTime(time.Time{})
+	} else if !so.StartTime.IsZero() {
+		t.SetStartTime(so.StartTime)
 	}
 	// update incoming metadata in request headers for any downstream readers
 	r.Header.Set(HTTPHeaderName, t.MetadataString())
 	return t
 }
+
+// headerKVs returns the KV pairs of the headers named in the list, if present.
+// The keys are the canonical header names with the provided prefix, and the
+// values of a header with multiple values are joined with commas.
+func headerKVs(h http.Header, prefix string, names []string) []interface{} {
+	var kvs []interface{}
+	for _, name := range names {
+		name = http.CanonicalHeaderKey(name)
+		if vals, ok := h[name]; ok {
+			kvs = append(kvs, prefix+name, strings.Join(vals, ","))
+		}
+	}
+	return kvs
+}
+
+// maskQueryString masks the values of the query parameters configured to be
+// masked, e.g. "user=joe&token=********". The names are matched case-insensitively.
+func maskQueryString(rawQuery string) string {
+	params := config.GetMaskedQueryParams()
+	if rawQuery == "" || len(params) == 0 {
+		return rawQuery
+	}
+	parts := strings.Split(rawQuery, "&")
+	for i, part := range parts {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		name, err := url.QueryUnescape(kv[0])
+		if err != nil {
+			name = kv[0]
+		}
+		for _, p := range params {
+			if strings.EqualFold(name, p) {
+				parts[i] = kv[0] + "=" + maskedQueryValue
+				break
+			}
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// maskURL returns the string form of the URL with the values of the configured
+// query parameters masked.
+func maskURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.String()
+	}
+	masked := *u
+	masked.RawQuery = maskQueryString(u.RawQuery)
+	return masked.String()
+}
@@ -148,6 +148,65 @@ func TestHTTPHandler200(t *testing.T) {
 	})
 }
 
+func handlerHeaders(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("X-Secret", "hidden")
+	w.WriteHeader(200)
+}
+
+func TestHTTPHandlerHeaders(t *testing.T) {
+	os.Setenv("APPOPTICS_HTTP_REQUEST_HEADERS", "user-agent, X-Request-Id,X-Missing")
+	os.Setenv("APPOPTICS_HTTP_RESPONSE_HEADERS", "Content-Type")
+	os.Setenv("APPOPTICS_HTTP_MASKED_QUERY_PARAMS", "token,Password")
+	config.Refresh()
+	defer func() {
+		os.Unsetenv("APPOPTICS_HTTP_REQUEST_HEADERS")
+		os.Unsetenv("APPOPTICS_HTTP_RESPONSE_HEADERS")
+		os.Unsetenv("APPOPTICS_HTTP_MASKED_QUERY_PARAMS")
+		config.Refresh()
+	}()
+
+	r := reporter.SetTestReporter() // set up test reporter
+	httpTestWithEndpointWithHeaders(handlerHeaders,
+		"http://test.com/hello?user=joe&token=abc&PASSWORD=xyz&password",
+		map[string]string{"User-Agent": "test-agent", "X-Request-Id": "req-1", "X-Other": "other"})
+
+	r.Close(2)
+	g.AssertGraph(t, r.EventBufs, 2, g.AssertNodeMap{
+		{"http.HandlerFunc", "entry"}: {Edges: g.Edges{}, Callback: func(n g.Node) {
+			assert.Equal(t, "user=joe&token=********&PASSWORD=********&password", n.Map["Query-String"])
+			assert.Equal(t, "test-agent", n.Map["Request-Header-User-Agent"])
+			assert.Equal(t, "req-1", n.Map["Request-Header-X-Request-Id"])
+			assert.NotContains(t, n.Map, "Request-Header-X-Missing")
+			assert.NotContains(t, n.Map, "Request-Header-X-Other")
+		}},
+		{"http.HandlerFunc", "exit"}: {Edges: g.Edges{{"http.HandlerFunc", "entry"}}, Callback: func(n g.Node) {
+			assert.Equal(t, "text/plain", n.Map["Response-Header-Content-Type"])
+			assert.NotContains(t, n.Map, "Response-Header-X-Secret")
+		}},
+	})
+}
+
+func TestHTTPClientMaskedURL(t *testing.T) {
+	r := reporter.SetTestReporter() // set up test reporter
+	ctx := ao.NewContext(context.Background(), ao.NewTrace("httpTest"))
+	req, err := http.NewRequest("GET", "http://test.com/hello?api_key=secret&q=1", nil)
+	require.NoError(t, err)
+	l := ao.BeginHTTPClientSpan(ctx, req)
+	l.End()
+	ao.EndTrace(ctx)
+
+	r.Close(4)
+	g.AssertGraph(t, r.EventBufs, 4, g.AssertNodeMap{
+		{"httpTest", "entry"}: {},
+		{"http.Client", "entry"}: {Edges: g.Edges{{"httpTest", "entry"}}, Callback: func(n g.Node) {
+			assert.Equal(t, "http://test.com/hello?api_key=********&q=1", n.Map["RemoteURL"])
+		}},
+		{"http.Client", "exit"}: {Edges: g.Edges{{"http.Client", "entry"}}},
+		{"httpTest", "exit"}:    {Edges: g.Edges{{"http.Client", "exit"}, {"httpTest", "entry"}}},
+	})
+}
+
 func TestHTTPHandlerNoTrace(t *testing.T) {
 	r := reporter.SetTestReporter(reporter.TestReporterDisableTracing())
 	httpTest(handler404)
@@ -6,6 +6,8 @@
 package config
 
 import (
+	"encoding/json"
+	"strings"
 	"sync"
 
 	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/log"
@@ -24,6 +26,12 @@ const (
 	defaultInsecureSkipVerify = false
 	defaultHistogramPrecision = 2
 	defaultDisabled           = false
+	defaultRequestHeaders     = ""
+	defaultResponseHeaders    = ""
+	defaultMaskedQueryParams  = "token,password,api_key"
+	defaultCloudMetadata      = "aws,gcp,azure"
+	defaultHostTags           = ""
+	defaultTraceHostTags      = false
 )
 
 // The environment variables
@@ -41,6 +49,12 @@ const (
 	envAppOpticsEventsFlushInterval = "APPOPTICS_EVENTS_FLUSH_INTERVAL"
 	envAppOpticsEventsBatchSize     = "APPOPTICS_EVENTS_BATCHSIZE"
 	envAppOpticsDisabled            = "APPOPTICS_DISABLED"
+	envAppOpticsRequestHeaders      = "APPOPTICS_HTTP_REQUEST_HEADERS"
+	envAppOpticsResponseHeaders     = "APPOPTICS_HTTP_RESPONSE_HEADERS"
+	envAppOpticsMaskedQueryParams   = "APPOPTICS_HTTP_MASKED_QUERY_PARAMS"
+	envAppOpticsCloudMetadata       = "APPOPTICS_CLOUD_METADATA"
+	envAppOpticsHostTags            = "APPOPTICS_HOST_TAGS"
+	envAppOpticsTraceHostTags       = "APPOPTICS_TRACE_HOST_TAGS"
 )
 
 // The environment variables, validators and converters. This map is not
@@ -137,6 +151,48 @@ var envs = map[string]Env{
 		convert:  ToBool,
 		mask:     nil,
 	},
+	"RequestHeaders": {
+		name:     envAppOpticsRequestHeaders,
+		optional: true,
+		validate: IsValidList,
+		convert:  ToList,
+		mask:     nil,
+	},
+	"ResponseHeaders": {
+		name:     envAppOpticsResponseHeaders,
+		optional: true,
+		validate: IsValidList,
+		convert:  ToList,
+		mask:     nil,
+	},
+	"MaskedQueryParams": {
+		name:     envAppOpticsMaskedQueryParams,
+		optional: true,
+		validate: IsValidList,
+		convert:  ToList,
+		mask:     nil,
+	},
+	"CloudMetadata": {
+		name:     envAppOpticsCloudMetadata,
+		optional: true,
+		validate: IsValidList,
+		convert:  ToList,
+		mask:     nil,
+	},
+	"HostTags": {
+		name:     envAppOpticsHostTags,
+		optional: true,
+		validate: IsValidTags,
+		convert:  ToTags,
+		mask:     nil,
+	},
+	"TraceHostTags": {
+		name:     envAppOpticsTraceHostTags,
+		optional: true,
+		validate: IsValidBool,
+		convert:  ToBool,
+		mask:     nil,
+	},
 }
 
 // Config is the struct to define the agent configuration. The configuration
@@ -179,6 +235,24 @@ type Config struct {
 	Reporter *ReporterOptions `yaml:"ReporterOptions" json:"ReporterOptions"`
 
 	Disabled bool `yaml:"Disabled" json:"Disabled"`
+
+	// The names of the HTTP request headers to be reported
+	RequestHeaders []string `yaml:"HTTPRequestHeaders" json:"HTTPRequestHeaders"`
+
+	// The names of the HTTP response headers to be reported
+	ResponseHeaders []string `yaml:"HTTPResponseHeaders" json:"HTTPResponseHeaders"`
+
+	// The names of the query parameters whose values are masked before reporting
+	MaskedQueryParams []string `yaml:"HTTPMaskedQueryParams" json:"HTTPMaskedQueryParams"`
+
+	// The cloud providers whose metadata endpoints are queried, among aws, gcp and azure
+	CloudMetadata []string `yaml:"CloudMetadata" json:"CloudMetadata"`
+
+	// The static tags of this host or service, e.g., environment, region or version
+	HostTags map[string]string `yaml:"HostTags" json:"HostTags"`
+
+	// Whether the host tags are reported in the HostTags sub-document of the trace entry events
+	TraceHostTags bool `yaml:"TraceHostTags" json:"TraceHostTags"`
 }
 
 // Option is a function type that accepts a Config pointer and
@@ -199,6 +273,67 @@ func WithServiceKey(key string) Option {
 	}
 }
 
+// WithRequestHeaders defines a Config option for the HTTP request headers
+// to be reported.
+func WithRequestHeaders(headers ...string) Option {
+	return func(c *Config) {
+		c.RequestHeaders = headers
+	}
+}
+
+// WithResponseHeaders defines a Config option for the HTTP response headers
+// to be reported.
+func WithResponseHeaders(headers ...string) Option {
+	return func(c *Config) {
+		c.ResponseHeaders = headers
+	}
+}
+
+// WithMaskedQueryParams defines a Config option for the query parameters
+// whose values are masked before reporting.
+func WithMaskedQueryParams(params ...string) Option {
+	return func(c *Config) {
+		c.MaskedQueryParams = params
+	}
+}
+
+// WithCloudMetadata defines a Config option for the cloud providers whose
+// metadata endpoints are queried. No endpoint is queried if none is provided.
+func WithCloudMetadata(providers ...string) Option {
+	return func(c *Config) {
+		c.CloudMetadata = providers
+	}
+}
+
+// WithHostTags defines a Config option for the static tags of this host or
+// service. They are merged into the tags defined by the environment variable,
+// and take precedence for the same tag names. The tags with an invalid name are
+// discarded.
+func WithHostTags(tags map[string]string) Option {
+	return func(c *Config) {
+		merged := make(map[string]string, len(c.HostTags)+len(tags))
+		for k, v := range c.HostTags {
+			merged[k] = v
+		}
+		for k, v := range tags {
+			if !IsValidTagName(k) {
+				log.Warningf("Invalid host tag name, discarded: %q", k)
+				continue
+			}
+			merged[k] = v
+		}
+		c.HostTags = merged
+	}
+}
+
+// WithTraceHostTags defines a Config option for whether the host tags are
+// reported in the HostTags sub-document of the trace entry events.
+func WithTraceHostTags(enabled bool) Option {
+	return func(c *Config) {
+		c.TraceHostTags = enabled
+	}
+}
+
 // NewConfig initializes a ReporterOptions object and override default values
 // with options provided as arguments. It may print errors if there are invalid
 // values in the configuration file or the environment variables.
@@ -244,6 +379,12 @@ func (c *Config) reset() {
 	c.Precision = defaultHistogramPrecision
 	c.Reporter = defaultReporterOptions()
 	c.Disabled = defaultDisabled
+	c.RequestHeaders = ToList(defaultRequestHeaders).([]string)
+	c.ResponseHeaders = ToList(defaultResponseHeaders).([]string)
+	c.MaskedQueryParams = ToList(defaultMaskedQueryParams).([]string)
+	c.CloudMetadata = ToList(defaultCloudMetadata).([]string)
+	c.HostTags = ToTags(defaultHostTags).(map[string]string)
+	c.TraceHostTags = defaultTraceHostTags
 }
 
 // loadEnvs loads environment variable values and update the Config object.
@@ -265,6 +406,13 @@ func (c *Config) loadEnvs() {
 	c.Precision = envs["Precision"].LoadInt(c.Precision)
 	c.Disabled = envs["Disabled"].LoadBool(c.Disabled)
 
+	c.RequestHeaders = envs["RequestHeaders"].LoadStringSlice(c.RequestHeaders)
+	c.ResponseHeaders = envs["ResponseHeaders"].LoadStringSlice(c.ResponseHeaders)
+	c.MaskedQueryParams = envs["MaskedQueryParams"].LoadStringSlice(c.MaskedQueryParams)
+	c.CloudMetadata = envs["CloudMetadata"].LoadStringSlice(c.CloudMetadata)
+	c.HostTags = envs["HostTags"].LoadStringMap(c.HostTags)
+	c.TraceHostTags = envs["TraceHostTags"].LoadBool(c.TraceHostTags)
+
 	c.Reporter.loadEnvs()
 }
 
@@ -351,6 +499,76 @@ func (c *Config) GetDisabled() bool {
 	return c.Disabled
 }
 
+// GetRequestHeaders returns the names of the HTTP request headers to be reported
+func (c *Config) GetRequestHeaders() []string {
+	c.RLock()
+	defer c.RUnlock()
+	return c.RequestHeaders
+}
+
+// GetResponseHeaders returns the names of the HTTP response headers to be reported
+func (c *Config) GetResponseHeaders() []string {
+	c.RLock()
+	defer c.RUnlock()
+	return c.ResponseHeaders
+}
+
+// GetMaskedQueryParams returns the names of the query parameters to be masked
+func (c *Config) GetMaskedQueryParams() []string {
+	c.RLock()
+	defer c.RUnlock()
+	return c.MaskedQueryParams
+}
+
+// GetCloudMetadata returns the cloud providers whose metadata endpoints are queried
+func (c *Config) GetCloudMetadata() []string {
+	c.RLock()
+	defer c.RUnlock()
+	return c.CloudMetadata
+}
+
+// GetHostTags returns a copy of the static tags of this host or service
+func (c *Config) GetHostTags() map[string]string {
+	c.RLock()
+	defer c.RUnlock()
+	tags := make(map[string]string, len(c.HostTags))
+	for k, v := range c.HostTags {
+		tags[k] = v
+	}
+	return tags
+}
+
+// GetTraceHostTags returns if the host tags are reported in trace entry events
+func (c *Config) GetTraceHostTags() bool {
+	c.RLock()
+	defer c.RUnlock()
+	return c.TraceHostTags
+}
+
+// ToMaskedMap returns the configuration as a map for diagnostics, with the
+// service key masked. The keys are the same as the JSON representation.
+func (c *Config) ToMaskedMap() map[string]interface{} {
+	c.RLock()
+	b, err := json.Marshal(c)
+	key := c.ServiceKey
+	c.RUnlock()
+
+	m := make(map[string]interface{})
+	if err != nil || json.Unmarshal(b, &m) != nil {
+		log.Warningf("Failed to convert the config to a map: %v", err)
+		return m
+	}
+
+	// MaskServiceKey expects the token:service_name format
+	if strings.Contains(key, ":") {
+		key = MaskServiceKey(key)
+	} else {
+		key = strings.Repeat("*", len(key))
+	}
+	m["ServiceKey"] = key
+	return m
+}
+
 // GetReporter returns the reporter options struct
 func (c *Config) GetReporter() *ReporterOptions {
 	c.RLock()
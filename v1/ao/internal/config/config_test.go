@@ -52,4 +52,65 @@ func TestLoadConfig(t *testing.T) {
 	assert.Equal(t, "test.crt", filepath.Base(c.GetTrustedPath()))
 	assert.Equal(t, "hello.udp", c.GetCollectorUDP())
 	assert.Equal(t, false, c.GetDisabled())
+
+	assert.Equal(t, []string{}, c.GetRequestHeaders())
+	assert.Equal(t, []string{"token", "password", "api_key"}, c.GetMaskedQueryParams())
+	os.Setenv(envAppOpticsRequestHeaders, "User-Agent,X-Request-Id")
+	os.Setenv(envAppOpticsResponseHeaders, "Content-Type")
+	os.Setenv(envAppOpticsMaskedQueryParams, "")
+	c.RefreshConfig()
+	assert.Equal(t, []string{"User-Agent", "X-Request-Id"}, c.GetRequestHeaders())
+	assert.Equal(t, []string{"Content-Type"}, c.GetResponseHeaders())
+	assert.Equal(t, []string{}, c.GetMaskedQueryParams())
+
+	c = NewConfig(WithRequestHeaders("X-Forwarded-For"), WithMaskedQueryParams("secret"))
+	assert.Equal(t, []string{"X-Forwarded-For"}, c.GetRequestHeaders())
+	assert.Equal(t, []string{"secret"}, c.GetMaskedQueryParams())
+	os.Unsetenv(envAppOpticsRequestHeaders)
+	os.Unsetenv(envAppOpticsResponseHeaders)
+	os.Unsetenv(envAppOpticsMaskedQueryParams)
+
+	assert.Equal(t, []string{"aws", "gcp", "azure"}, c.GetCloudMetadata())
+	os.Setenv(envAppOpticsCloudMetadata, "gcp")
+	c.RefreshConfig()
+	assert.Equal(t, []string{"gcp"}, c.GetCloudMetadata())
+	os.Setenv(envAppOpticsCloudMetadata, "")
+	c.RefreshConfig()
+	assert.Equal(t, []string{}, c.GetCloudMetadata())
+	os.Unsetenv(envAppOpticsCloudMetadata)
+
+	c = NewConfig(WithCloudMetadata())
+	assert.Empty(t, c.GetCloudMetadata())
+
+	assert.Equal(t, map[string]string{}, c.GetHostTags())
+	assert.False(t, c.GetTraceHostTags())
+	os.Setenv(envAppOpticsHostTags, "env=prod,team=payments")
+	os.Setenv(envAppOpticsTraceHostTags, "true")
+	c.RefreshConfig()
+	assert.Equal(t, map[string]string{"env": "prod", "team": "payments"}, c.GetHostTags())
+	assert.True(t, c.GetTraceHostTags())
+	c.GetHostTags()["env"] = "modified"
+	assert.Equal(t, "prod", c.GetHostTags()["env"])
+
+	c = NewConfig(WithHostTags(map[string]string{"env": "staging", "version": "1.2.3", "Layer": "web"}),
+		WithTraceHostTags(false))
+	assert.Equal(t, map[string]string{"env": "staging", "team": "payments", "version": "1.2.3"},
+		c.GetHostTags())
+	assert.False(t, c.GetTraceHostTags())
+	os.Unsetenv(envAppOpticsHostTags)
+	os.Unsetenv(envAppOpticsTraceHostTags)
+}
+
+func TestToMaskedMap(t *testing.T) {
+	key := "ae38315f6116585d64d82ec2455aa3ec61e02fee25d286f74ace9e4fea189217:Go"
+	c := NewConfig(WithServiceKey(key), WithCollector("example.com:443"))
+	m := c.ToMaskedMap()
+	assert.Equal(t, MaskServiceKey(key), m["ServiceKey"])
+	assert.Equal(t, "example.com:443", m["CollectorHost"])
+	assert.Contains(t, m, "ReporterOptions")
+	assert.NotContains(t, m, "RWMutex")
+	assert.Equal(t, key, c.GetServiceKey())
+
+	c = NewConfig(WithServiceKey("invalid"))
+	assert.Equal(t, "*******", c.ToMaskedMap()["ServiceKey"])
 }
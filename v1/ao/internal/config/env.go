@@ -4,6 +4,7 @@ package config
 
 import (
 	"os"
+	"sort"
 
 	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/log"
 )
@@ -66,6 +67,24 @@ func (e Env) LoadInt64(fallback int64) int64 {
 	return fallback
 }
 
+// LoadStringSlice loads the env and returns a string slice value
+func (e Env) LoadStringSlice(fallback []string) []string {
+	v := e.load(fallback)
+	if s, ok := v.([]string); ok {
+		return s
+	}
+	return fallback
+}
+
+// LoadStringMap loads the env and returns a string map value
+func (e Env) LoadStringMap(fallback map[string]string) map[string]string {
+	v := e.load(fallback)
+	if s, ok := v.(map[string]string); ok {
+		return s
+	}
+	return fallback
+}
+
 // load loads the environment variable and returns the value
 func (e Env) load(fallback interface{}) interface{} {
 	validate := e.validate
@@ -97,6 +116,48 @@ func (e Env) load(fallback interface{}) interface{} {
 	return fallback
 }
 
+// EnvCheck is the result of validating an environment variable.
+type EnvCheck struct {
+	// the name of the environment variable
+	Name string
+	// the value, which is masked if it's sensitive
+	Value string
+	// is the environment variable set or not
+	Set bool
+	// is the environment variable optional or not
+	Optional bool
+	// is the value valid, or is the variable optional if it's not set
+	Valid bool
+}
+
+// check validates the environment variable without loading or logging it.
+func (e Env) check() EnvCheck {
+	c := EnvCheck{Name: e.name, Optional: e.optional}
+	v, ok := os.LookupEnv(e.name)
+	if !ok {
+		c.Valid = e.optional
+		return c
+	}
+	c.Set = true
+	c.Valid = e.validate == nil || e.validate(v)
+	if e.mask != nil {
+		v = e.mask(v)
+	}
+	c.Value = v
+	return c
+}
+
+// CheckEnvs validates the environment variables of the agent with their
+// validators, in the order of their names.
+func CheckEnvs() []EnvCheck {
+	var checks []EnvCheck
+	for _, e := range envs {
+		checks = append(checks, e.check())
+	}
+	sort.Slice(checks, func(i, j int) bool { return checks[i].Name < checks[j].Name })
+	return checks
+}
+
 func (e Env) reportInvalid(v string) {
 	if e.mask != nil {
 		v = e.mask(v)
@@ -4,6 +4,7 @@ package config
 
 import (
 	"os"
+	"sort"
 	"strconv"
 	"testing"
 
@@ -164,3 +165,48 @@ func TestLoadInt(t *testing.T) {
 		assert.Equal(t, c.expected, c.e.LoadInt(c.fallback), i)
 	}
 }
+
+func TestCheckEnvs(t *testing.T) {
+	key := "ae38315f6116585d64d82ec2455aa3ec61e02fee25d286f74ace9e4fea189217:go"
+	os.Setenv(envAppOpticsServiceKey, key)
+	os.Setenv(envAppOpticsTracingMode, "sometimes")
+	os.Unsetenv(envAppOpticsCollector)
+	defer os.Unsetenv(envAppOpticsServiceKey)
+	defer os.Unsetenv(envAppOpticsTracingMode)
+
+	checks := make(map[string]EnvCheck)
+	var names []string
+	for _, c := range CheckEnvs() {
+		checks[c.Name] = c
+		names = append(names, c.Name)
+	}
+	assert.Len(t, names, len(envs))
+	assert.True(t, sort.StringsAreSorted(names))
+
+	assert.Equal(t, EnvCheck{
+		Name:  envAppOpticsServiceKey,
+		Value: MaskServiceKey(key),
+		Set:   true,
+		Valid: true,
+	}, checks[envAppOpticsServiceKey])
+	assert.Equal(t, EnvCheck{
+		Name:     envAppOpticsTracingMode,
+		Value:    "sometimes",
+		Set:      true,
+		Optional: true,
+		Valid:    false,
+	}, checks[envAppOpticsTracingMode])
+	assert.Equal(t, EnvCheck{
+		Name:     envAppOpticsCollector,
+		Optional: true,
+		Valid:    true,
+	}, checks[envAppOpticsCollector])
+
+	os.Unsetenv(envAppOpticsServiceKey)
+	for _, c := range CheckEnvs() {
+		if c.Name == envAppOpticsServiceKey {
+			assert.False(t, c.Set)
+			assert.False(t, c.Valid)
+		}
+	}
+}
@@ -163,6 +163,79 @@ func ToInt64(i string) interface{} {
 	return int64(n)
 }
 
+// IsValidList checks if the string represents a valid comma-separated list.
+// An empty string is an empty list.
+func IsValidList(l string) bool {
+	for _, item := range strings.Split(l, ",") {
+		if strings.ContainsAny(strings.TrimSpace(item), " \t\r\n") {
+			return false
+		}
+	}
+	return true
+}
+
+// ToList converts a comma-separated list to a string slice, the empty items
+// are dropped.
+func ToList(l string) interface{} {
+	items := []string{}
+	for _, item := range strings.Split(l, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// reservedTagNames are the names of the KVs describing the events and messages,
+// which can't be used as tag names.
+var reservedTagNames = map[string]bool{
+	"Layer":    true,
+	"Label":    true,
+	"X-Trace":  true,
+	"Edge":     true,
+	"Hostname": true,
+	"__Init":   true,
+	"HostTags": true,
+}
+
+// IsValidTagName checks if the string is a valid tag name: it can't be empty,
+// contain whitespaces or be a reserved name, e.g., "Layer" or "Hostname".
+func IsValidTagName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, " \t\r\n") && !reservedTagNames[name]
+}
+
+// IsValidTags checks if the string represents a valid comma-separated list of
+// name=value tags, e.g., "env=prod,region=us-east-1". The names must be valid,
+// see IsValidTagName. An empty string is an empty list.
+func IsValidTags(l string) bool {
+	for _, item := range strings.Split(l, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 || !IsValidTagName(strings.TrimSpace(kv[0])) {
+			return false
+		}
+	}
+	return true
+}
+
+// ToTags converts a comma-separated list of name=value tags to a map, the
+// empty items are dropped.
+func ToTags(l string) interface{} {
+	tags := make(map[string]string)
+	for _, item := range strings.Split(l, ",") {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		if name := strings.TrimSpace(kv[0]); name != "" {
+			tags[name] = strings.TrimSpace(kv[1])
+		}
+	}
+	return tags
+}
+
 // MaskServiceKey masks the middle part of the token and returns the
 // masked service key. For example:
 // key: "ae38315f6116585d64d82ec2455aa3ec61e02fee25d286f74ace9e4fea189217:go"
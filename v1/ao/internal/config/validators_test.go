@@ -77,6 +77,30 @@ func TestConverters(t *testing.T) {
 	assert.Equal(t, "never", ToTracingMode("never").(string))
 }
 
+func TestList(t *testing.T) {
+	assert.Equal(t, true, IsValidList(""))
+	assert.Equal(t, true, IsValidList("a, b ,c"))
+	assert.Equal(t, false, IsValidList("a b,c"))
+	assert.Equal(t, []string{}, ToList(""))
+	assert.Equal(t, []string{"a", "b", "c"}, ToList(" a, b,,c ,"))
+}
+
+func TestTags(t *testing.T) {
+	assert.Equal(t, true, IsValidTags(""))
+	assert.Equal(t, true, IsValidTags("env=prod, region = us-east-1,,version="))
+	assert.Equal(t, false, IsValidTags("env"))
+	assert.Equal(t, false, IsValidTags("=prod"))
+	assert.Equal(t, false, IsValidTags("my env=prod"))
+	assert.Equal(t, false, IsValidTags("env=prod,Hostname=web1"))
+	assert.Equal(t, true, IsValidTagName("hostname"))
+	for _, name := range []string{"", "my env", "Layer", "Label", "X-Trace", "Edge", "Hostname", "__Init", "HostTags"} {
+		assert.Equal(t, false, IsValidTagName(name), name)
+	}
+	assert.Equal(t, map[string]string{}, ToTags(""))
+	assert.Equal(t, map[string]string{"env": "prod", "region": "us-east-1", "git": "a=b"},
+		ToTags(" env=prod,region = us-east-1,,git=a=b"))
+}
+
 func withDemoKey(sn string) string {
 	return "demo_service_key:" + sn
 }
@@ -37,6 +37,27 @@ var GetPrecision = conf.GetPrecision
 // GetDisabled is a wrapper to the method of the global config
 var GetDisabled = conf.GetDisabled
 
+// GetRequestHeaders is a wrapper to the method of the global config
+var GetRequestHeaders = conf.GetRequestHeaders
+
+// GetResponseHeaders is a wrapper to the method of the global config
+var GetResponseHeaders = conf.GetResponseHeaders
+
+// GetMaskedQueryParams is a wrapper to the method of the global config
+var GetMaskedQueryParams = conf.GetMaskedQueryParams
+
+// GetCloudMetadata is a wrapper to the method of the global config
+var GetCloudMetadata = conf.GetCloudMetadata
+
+// GetHostTags is a wrapper to the method of the global config
+var GetHostTags = conf.GetHostTags
+
+// GetTraceHostTags is a wrapper to the method of the global config
+var GetTraceHostTags = conf.GetTraceHostTags
+
+// ToMaskedMap is a wrapper to the method of the global config
+var ToMaskedMap = conf.ToMaskedMap
+
 // ReporterOpts is a wrapper to the method of the global config
 var ReporterOpts = conf.GetReporter
 
@@ -0,0 +1,156 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+// Package eventgraph decodes the events reported by the agent and resolves the
+// parent of each event from its edges. It's shared by the tools which inspect
+// the recorded events, e.g. the aotest package and the aoctl command.
+package eventgraph
+
+import (
+	"gopkg.in/mgo.v2/bson"
+)
+
+// The keys of the event KVs which describe the event graph.
+const (
+	KeyLayer     = "Layer"
+	KeyLabel     = "Label"
+	KeyXTrace    = "X-Trace"
+	KeyEdge      = "Edge"
+	KeyLink      = "Link"
+	KeyTimestamp = "Timestamp_u"
+)
+
+// Event is a decoded event.
+type Event struct {
+	Layer, Label string
+	TaskID       string // empty if the event has no valid X-Trace
+	OpID         string
+	Edges        []string
+	Links        map[string]bool // the op IDs of the linked events
+	Timestamp    int64           // in microseconds, 0 if unknown
+	// KVs holds the other KVs, including the Links, in their original order.
+	KVs bson.D
+}
+
+// Decode decodes the BSON document of an event.
+func Decode(d bson.D) *Event {
+	e := &Event{Links: make(map[string]bool)}
+	for _, kv := range d {
+		switch kv.Name {
+		case KeyLayer:
+			e.Layer, _ = kv.Value.(string)
+		case KeyLabel:
+			e.Label, _ = kv.Value.(string)
+		case KeyXTrace:
+			md, _ := kv.Value.(string)
+			e.TaskID, e.OpID = TaskID(md), OpID(md)
+		case KeyEdge:
+			if edge, ok := kv.Value.(string); ok {
+				e.Edges = append(e.Edges, edge)
+			}
+		case KeyTimestamp:
+			e.Timestamp, _ = kv.Value.(int64)
+		default:
+			if kv.Name == KeyLink {
+				addLinks(e.Links, kv.Value)
+			}
+			e.KVs = append(e.KVs, kv)
+		}
+	}
+	if e.OpID == "" {
+		e.TaskID = ""
+	}
+	return e
+}
+
+// addLinks adds the op IDs of the linked metadata strings, which may be reported
+// as a repeated key or an array.
+func addLinks(links map[string]bool, v interface{}) {
+	switch v := v.(type) {
+	case string:
+		if op := OpID(v); op != "" {
+			links[op] = true
+		}
+	case []interface{}:
+		for _, md := range v {
+			addLinks(links, md)
+		}
+	}
+}
+
+// TaskID returns the task ID of the metadata string provided, or an empty
+// string if it's invalid.
+func TaskID(md string) string {
+	if len(md) < 42 {
+		return ""
+	}
+	return md[2:42]
+}
+
+// OpID returns the op ID of the metadata string provided, or an empty string if
+// it's invalid.
+func OpID(md string) string {
+	if len(md) < 58 {
+		return ""
+	}
+	return md[42:58]
+}
+
+// KVMap returns the KVs as a map. The values of a repeated key, e.g. the Link of
+// a span with several links, are collected into a slice.
+func KVMap(kvs bson.D) map[string]interface{} {
+	m := make(map[string]interface{})
+	repeated := make(map[string][]interface{})
+	for _, kv := range kvs {
+		prev, ok := m[kv.Name]
+		if !ok {
+			m[kv.Name] = kv.Value
+			continue
+		}
+		if repeated[kv.Name] == nil {
+			repeated[kv.Name] = []interface{}{prev}
+		}
+		repeated[kv.Name] = append(repeated[kv.Name], kv.Value)
+		m[kv.Name] = repeated[kv.Name]
+	}
+	return m
+}
+
+// IsEntry returns if the event begins a span.
+func (e *Event) IsEntry() bool { return e.Label == "entry" || e.Label == "profile_entry" }
+
+// IsExit returns if the event ends a span.
+func (e *Event) IsExit() bool { return e.Label == "exit" || e.Label == "profile_exit" }
+
+// Graph indexes events by their task and op IDs to resolve their parents.
+type Graph struct {
+	events map[string]*Event
+}
+
+// NewGraph returns an empty graph.
+func NewGraph() *Graph {
+	return &Graph{events: make(map[string]*Event)}
+}
+
+// Add adds an event to the graph. An event with the same op ID as an event
+// added before is ignored.
+func (g *Graph) Add(e *Event) {
+	k := e.TaskID + e.OpID
+	if _, ok := g.events[k]; !ok {
+		g.events[k] = e
+	}
+}
+
+// Parent returns the event which the event follows from: the preceding event
+// of its span, or the event of the parent span for an entry event. It returns
+// nil if that event isn't in the graph. The other edges are the exit events of
+// its children and the links.
+func (g *Graph) Parent(e *Event) *Event {
+	for _, edge := range e.Edges {
+		p, ok := g.events[e.TaskID+edge]
+		if !ok || p == e || e.Links[edge] || p.IsExit() {
+			continue
+		}
+		return p
+	}
+	return nil
+}
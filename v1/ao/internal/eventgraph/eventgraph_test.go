@@ -0,0 +1,84 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package eventgraph
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const testTaskID = "1BF4CAE2B7C4C2BD2C5E5FA5C48EF4C1C4B0C7A6"
+
+func md(op string) string { return "2B" + testTaskID + op + "01" }
+
+func testEvent(layer, label, op string, edges ...string) bson.D {
+	d := bson.D{{Name: KeyLayer, Value: layer}, {Name: KeyLabel, Value: label}, {Name: KeyXTrace, Value: md(op)}}
+	for _, e := range edges {
+		d = append(d, bson.DocElem{Name: KeyEdge, Value: e})
+	}
+	return d
+}
+
+func TestDecode(t *testing.T) {
+	d := append(testEvent("app", "entry", "0000000000000002", "0000000000000001"),
+		bson.DocElem{Name: KeyTimestamp, Value: int64(1000)},
+		bson.DocElem{Name: "Method", Value: "GET"},
+		bson.DocElem{Name: KeyLink, Value: md("0000000000000003")},
+		bson.DocElem{Name: KeyLink, Value: []interface{}{md("0000000000000004")}})
+	e := Decode(d)
+	assert.Equal(t, "app", e.Layer)
+	assert.Equal(t, "entry", e.Label)
+	assert.Equal(t, testTaskID, e.TaskID)
+	assert.Equal(t, "0000000000000002", e.OpID)
+	assert.Equal(t, []string{"0000000000000001"}, e.Edges)
+	assert.Equal(t, int64(1000), e.Timestamp)
+	assert.Equal(t, map[string]bool{"0000000000000003": true, "0000000000000004": true}, e.Links)
+	assert.Equal(t, d[5:], e.KVs)
+	assert.True(t, e.IsEntry())
+	assert.False(t, e.IsExit())
+
+	assert.Equal(t, "", Decode(bson.D{{Name: KeyXTrace, Value: "2B"}}).TaskID)
+	assert.Equal(t, "", Decode(bson.D{{Name: "Hostname", Value: "web1"}}).TaskID)
+}
+
+func TestKVMap(t *testing.T) {
+	assert.Equal(t, map[string]interface{}{
+		"Method": "GET",
+		"Link":   []interface{}{"a", "b", "c"},
+		"Args":   []interface{}{"x"},
+	}, KVMap(bson.D{
+		{Name: "Method", Value: "GET"},
+		{Name: "Link", Value: "a"},
+		{Name: "Args", Value: []interface{}{"x"}},
+		{Name: "Link", Value: "b"},
+		{Name: "Link", Value: "c"},
+	}))
+}
+
+func TestParent(t *testing.T) {
+	root := Decode(testEvent("app", "entry", "0000000000000001"))
+	msg1 := Decode(testEvent("msg1", "entry", "0000000000000002", "0000000000000001"))
+	msg2 := Decode(testEvent("msg2", "entry", "0000000000000003", "0000000000000001"))
+	msg2Exit := Decode(testEvent("msg2", "exit", "0000000000000004", "0000000000000003"))
+	// follows from msg1 and msg2, and from an event which isn't in the graph
+	batch := Decode(append(testEvent("batch", "entry", "0000000000000005",
+		"00000000000000FF", "0000000000000002", "0000000000000003"),
+		bson.DocElem{Name: KeyLink, Value: md("0000000000000002")},
+		bson.DocElem{Name: KeyLink, Value: md("0000000000000003")}))
+	// follows from the exit of its child
+	rootExit := Decode(testEvent("app", "exit", "0000000000000006", "0000000000000004", "0000000000000001"))
+
+	g := NewGraph()
+	for _, e := range []*Event{root, msg1, msg2, msg2Exit, batch, rootExit} {
+		g.Add(e)
+	}
+	g.Add(Decode(testEvent("duplicate", "entry", "0000000000000001")))
+
+	assert.Nil(t, g.Parent(root))
+	assert.Equal(t, root, g.Parent(msg1))
+	assert.Equal(t, msg2, g.Parent(msg2Exit))
+	assert.Nil(t, g.Parent(batch))
+	assert.Equal(t, root, g.Parent(rootExit))
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2017 Librato, Inc. All rights reserved.
+
+package host
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/config"
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/log"
+)
+
+// the cloud providers whose metadata endpoints can be queried
+const (
+	providerAWS   = "aws"
+	providerGCP   = "gcp"
+	providerAzure = "azure"
+)
+
+// the timeout of a single request to a metadata endpoint. It is kept short so
+// non-cloud hosts do not wait long for the probes to fail.
+const metadataTimeout = time.Second
+
+// GCE and Azure Metadata URLs
+const (
+	// the prefix of the GCE instance metadata, the attribute name is appended
+	gcpMetadataURL = "http://metadata.google.internal/computeMetadata/v1/instance/"
+	// the Azure Instance Metadata Service (IMDS) compute metadata
+	azureMetadataURL = "http://169.254.169.254/metadata/instance/compute?api-version=2021-02-01"
+)
+
+// cloudMetadataEnabled returns if the metadata endpoint of the provider is
+// allowed to be queried by the configuration.
+func cloudMetadataEnabled(provider string) bool {
+	for _, p := range config.GetCloudMetadata() {
+		if strings.EqualFold(strings.TrimSpace(p), provider) {
+			return true
+		}
+	}
+	return false
+}
+
+// getCloudMetadata gets the GCE or Azure instance metadata (or an empty
+// CloudMetadata if it's neither a GCE nor an Azure instance)
+func getCloudMetadata() CloudMetadata {
+	cloudOnce.Do(func() {
+		if cloudMetadataEnabled(providerGCP) {
+			cloud = getGCPMetadata(gcpMetadataURL)
+		}
+		if cloud.InstanceID == "" && cloudMetadataEnabled(providerAzure) {
+			cloud = getAzureMetadata(azureMetadataURL)
+		}
+		log.Debugf("Got and cached cloud metadata: %+v", cloud)
+	})
+	return cloud
+}
+
+// getMetadata fetches the metadata from a URL with the headers required by the
+// metadata service. It returns nil if the request fails.
+func getMetadata(url string, header map[string]string) []byte {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+
+	client := http.Client{Timeout: metadataTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Debugf("Failed to get metadata from %s", url)
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Debugf("Failed to get metadata from %s: %s", url, resp.Status)
+		return nil
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Debugf("Failed to read metadata response: %s", url)
+		return nil
+	}
+	return body
+}
+
+// getGCPMetadata queries the GCE metadata server at the URL provided, to which
+// the attribute names are appended. The zone and machine type
+// are returned as full resource names, e.g., projects/123/zones/us-central1-a,
+// so only the last path element is kept.
+func getGCPMetadata(url string) CloudMetadata {
+	get := func(attr string) string {
+		meta := getMetadata(url+attr, map[string]string{"Metadata-Flavor": "Google"})
+		return lastPathElem(strings.TrimSpace(string(meta)))
+	}
+
+	id := get("id")
+	if id == "" {
+		return CloudMetadata{}
+	}
+	zone := get("zone")
+	return CloudMetadata{
+		Provider:    providerGCP,
+		InstanceID:  id,
+		Zone:        zone,
+		Region:      gcpRegion(zone),
+		MachineType: get("machine-type"),
+	}
+}
+
+// gcpRegion derives the region from a GCE zone, e.g., us-central1-a is in the
+// region us-central1.
+func gcpRegion(zone string) string {
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		return zone[:i]
+	}
+	return zone
+}
+
+// getAzureMetadata queries the Azure Instance Metadata Service at the URL provided.
+func getAzureMetadata(url string) CloudMetadata {
+	meta := getMetadata(url, map[string]string{"Metadata": "true"})
+	if meta == nil {
+		return CloudMetadata{}
+	}
+
+	var compute struct {
+		VMID     string `json:"vmId"`
+		Location string `json:"location"`
+		Zone     string `json:"zone"`
+		VMSize   string `json:"vmSize"`
+	}
+	if err := json.Unmarshal(meta, &compute); err != nil || compute.VMID == "" {
+		log.Debugf("Failed to parse Azure metadata: %v", err)
+		return CloudMetadata{}
+	}
+	return CloudMetadata{
+		Provider:    providerAzure,
+		InstanceID:  compute.VMID,
+		Zone:        compute.Zone,
+		Region:      compute.Location,
+		MachineType: compute.VMSize,
+	}
+}
+
+// lastPathElem returns the last element of a slash-separated path
+func lastPathElem(s string) string {
+	return s[strings.LastIndex(s, "/")+1:]
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2017 Librato, Inc. All rights reserved.
+
+package host
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetGCPMetadata(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/instance/id":
+			fmt.Fprint(w, "4520031799277581759")
+		case "/instance/zone":
+			fmt.Fprint(w, "projects/123456789/zones/us-central1-a")
+		case "/instance/machine-type":
+			fmt.Fprint(w, "projects/123456789/machineTypes/n1-standard-1")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	assert.Equal(t, CloudMetadata{
+		Provider:    "gcp",
+		InstanceID:  "4520031799277581759",
+		Zone:        "us-central1-a",
+		Region:      "us-central1",
+		MachineType: "n1-standard-1",
+	}, getGCPMetadata(s.URL+"/instance/"))
+
+	assert.Equal(t, CloudMetadata{}, getGCPMetadata(s.URL+"/invalid/"))
+}
+
+// azureServer returns a server responding to the Azure metadata requests with
+// the body provided.
+func azureServer(body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata") != "true" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+}
+
+func TestGetAzureMetadata(t *testing.T) {
+	s := azureServer(`{"location":"westus2","name":"vm1","vmId":"02aab8a4-74ef-476e-8182-f6d2ba4166a6",` +
+		`"vmSize":"Standard_D2s_v3","zone":"1"}`)
+	defer s.Close()
+	assert.Equal(t, CloudMetadata{
+		Provider:    "azure",
+		InstanceID:  "02aab8a4-74ef-476e-8182-f6d2ba4166a6",
+		Zone:        "1",
+		Region:      "westus2",
+		MachineType: "Standard_D2s_v3",
+	}, getAzureMetadata(s.URL+"/metadata/instance/compute?api-version=2021-02-01"))
+
+	invalid := azureServer("not json")
+	defer invalid.Close()
+	assert.Equal(t, CloudMetadata{}, getAzureMetadata(invalid.URL+"/metadata/instance/compute"))
+}
+
+func TestCloudMetadataEnabled(t *testing.T) {
+	assert.True(t, cloudMetadataEnabled(providerAWS))
+	assert.True(t, cloudMetadataEnabled(providerGCP))
+	assert.True(t, cloudMetadataEnabled(providerAzure))
+
+	os.Setenv("APPOPTICS_CLOUD_METADATA", "AWS")
+	config.Refresh()
+	assert.True(t, cloudMetadataEnabled(providerAWS))
+	assert.False(t, cloudMetadataEnabled(providerGCP))
+	assert.False(t, cloudMetadataEnabled(providerAzure))
+
+	os.Setenv("APPOPTICS_CLOUD_METADATA", "")
+	config.Refresh()
+	assert.False(t, cloudMetadataEnabled(providerAWS))
+
+	os.Unsetenv("APPOPTICS_CLOUD_METADATA")
+	config.Refresh()
+}
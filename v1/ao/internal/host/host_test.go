@@ -72,6 +72,7 @@ func TestConfiguredHostname(t *testing.T) {
 	defer func() {
 		log.SetOutput(os.Stderr)
 	}()
+	aolog.DisableDedupForTest(t)
 
 	var old string
 	var has bool
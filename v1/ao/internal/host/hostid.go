@@ -29,6 +29,14 @@ var (
 	// the Heroku DYNO id
 	dyno     string
 	dynoOnce sync.Once
+
+	// the Kubernetes pod metadata
+	k8s     K8sMetadata
+	k8sOnce sync.Once
+
+	// the GCE or Azure instance metadata
+	cloud     CloudMetadata
+	cloudOnce sync.Once
 )
 
 // lockedID is a ID protected by a mutex. To avoid being modified without
@@ -65,7 +73,9 @@ func (h *ID) copy() ID {
 		withEC2Zone(h.ec2Zone),
 		withContainerId(h.containerId),
 		withMAC(h.mac),
-		withHerokuId(h.herokuId))
+		withHerokuId(h.herokuId),
+		withK8s(h.k8s),
+		withCloud(h.cloud))
 	return *c
 }
 
@@ -143,6 +153,35 @@ type ID struct {
 
 	// The Heroku DynoID
 	herokuId string
+
+	// the metadata of the Kubernetes pod
+	k8s K8sMetadata
+
+	// the metadata of the GCE or Azure instance
+	cloud CloudMetadata
+}
+
+// K8sMetadata is the metadata of the Kubernetes pod the process runs in. All
+// its fields are empty if it doesn't run in Kubernetes. It's only reported in
+// the host KVs of the metrics messages, as the collector's HostID has no fields
+// for it.
+type K8sMetadata struct {
+	Namespace string
+	PodName   string
+	PodUID    string
+	NodeName  string
+}
+
+// CloudMetadata is the metadata of the GCE or Azure instance the process runs
+// on. All its fields are empty if it's not running on either of them. EC2
+// instances are identified by ID.EC2Id and ID.EC2Zone instead.
+type CloudMetadata struct {
+	// the cloud provider, either "gcp" or "azure"
+	Provider    string
+	InstanceID  string
+	Zone        string
+	Region      string
+	MachineType string
 }
 
 // Hostname returns the hostname field of ID
@@ -180,6 +219,16 @@ func (h ID) HerokuID() string {
 	return h.herokuId
 }
 
+// K8s returns the k8s field of ID
+func (h ID) K8s() K8sMetadata {
+	return h.k8s
+}
+
+// Cloud returns the cloud field of ID
+func (h ID) Cloud() CloudMetadata {
+	return h.cloud
+}
+
 // IDSetter defines a function type which set a field of ID
 type IDSetter func(h *ID)
 
@@ -228,6 +277,18 @@ func withHerokuId(id string) IDSetter {
 	}
 }
 
+func withK8s(k8s K8sMetadata) IDSetter {
+	return func(h *ID) {
+		h.k8s = k8s
+	}
+}
+
+func withCloud(cloud CloudMetadata) IDSetter {
+	return func(h *ID) {
+		h.cloud = cloud
+	}
+}
+
 func newID(setters ...IDSetter) *ID {
 	h := &ID{}
 	h.update(setters...)
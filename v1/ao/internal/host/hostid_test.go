@@ -16,6 +16,9 @@ func TestLockedHostID(t *testing.T) {
 	dockerId := "23423jlksl4j2l"
 	mac := []string{"72:00:07:e5:23:51", "c6:61:8b:53:d6:b5", "72:00:07:e5:23:50"}
 	herokuId := "heroku-test"
+	k8s := K8sMetadata{Namespace: "default", PodName: "web-1", PodUID: "uid-1", NodeName: "node-1"}
+	cloud := CloudMetadata{Provider: "gcp", InstanceID: "123", Zone: "us-central1-a",
+		Region: "us-central1", MachineType: "n1-standard-1"}
 
 	lh := newLockedID()
 	assert.False(t, lh.ready())
@@ -30,7 +33,9 @@ func TestLockedHostID(t *testing.T) {
 		withEC2Zone(ec2Zone),
 		withContainerId(dockerId),
 		withMAC(mac),
-		withHerokuId(herokuId))
+		withHerokuId(herokuId),
+		withK8s(k8s),
+		withCloud(cloud))
 
 	assert.True(t, lh.ready())
 	lh.setReady()
@@ -44,4 +49,6 @@ func TestLockedHostID(t *testing.T) {
 	assert.Equal(t, dockerId, h.ContainerId())
 	assert.Equal(t, mac, h.MAC())
 	assert.EqualValues(t, herokuId, h.HerokuID())
+	assert.Equal(t, k8s, h.K8s())
+	assert.Equal(t, cloud, h.Cloud())
 }
@@ -25,6 +25,16 @@ const (
 
 	// the environment variable for Heroku DYNO ID
 	envDyno = "DYNO"
+
+	// the environment variable set in every Kubernetes container
+	envK8sServiceHost = "KUBERNETES_SERVICE_HOST"
+	// the environment variables usually set with the downward API
+	envK8sNamespace = "POD_NAMESPACE"
+	envK8sPodName   = "POD_NAME"
+	envK8sPodUID    = "POD_UID"
+	envK8sNodeName  = "NODE_NAME"
+	// the namespace of the service account mounted in the pod
+	k8sNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
 )
 
 // logging texts
@@ -109,6 +119,8 @@ func updateHostID(lh *lockedID) {
 	ec2Zone := getOrFallback(getEC2Zone, old.ec2Zone)
 	cid := getOrFallback(getContainerID, old.containerId)
 	herokuId := getOrFallback(getHerokuDynoId, old.herokuId)
+	k8s := getK8sMetadata()
+	cloud := getCloudMetadata()
 
 	mac := getMACAddressList()
 	if len(mac) == 0 {
@@ -123,6 +135,8 @@ func updateHostID(lh *lockedID) {
 		withContainerId(cid),
 		withMAC(mac),
 		withHerokuId(herokuId),
+		withK8s(k8s),
+		withCloud(cloud),
 	}
 
 	lh.fullUpdate(setters...)
@@ -148,7 +162,7 @@ func getPid() int {
 func getAWSMeta(url string) (meta string) {
 	// Fetch it from the specified URL if the cache is uninitialized or no
 	// cache at all.
-	client := http.Client{Timeout: time.Second}
+	client := http.Client{Timeout: metadataTimeout}
 	resp, err := client.Get(url)
 	if err != nil {
 		log.Debugf("Failed to get AWS metadata from %s", url)
@@ -168,6 +182,9 @@ func getAWSMeta(url string) (meta string) {
 // gets the AWS instance ID (or empty string if not an AWS instance)
 func getEC2ID() string {
 	ec2IdOnce.Do(func() {
+		if !cloudMetadataEnabled(providerAWS) {
+			return
+		}
 		ec2Id = getAWSMeta(ec2IDURL)
 		log.Debugf("Got and cached ec2Id: %s", ec2Id)
 	})
@@ -177,49 +194,97 @@ func getEC2ID() string {
 // gets the AWS instance zone (or empty string if not an AWS instance)
 func getEC2Zone() string {
 	ec2ZoneOnce.Do(func() {
+		if !cloudMetadataEnabled(providerAWS) {
+			return
+		}
 		ec2Zone = getAWSMeta(ec2ZoneURL)
 		log.Debugf("Got and cached ec2Zone: %s", ec2Zone)
 	})
 	return ec2Zone
 }
 
-// getContainerID fetches the container ID by reading '/proc/self/cgroup'
+// getContainerID fetches the container ID by reading '/proc/self/cgroup', or
+// '/proc/self/mountinfo' if the cgroup paths are hidden by a cgroup namespace.
 func getContainerID() (id string) {
 	containerIdOnce.Do(func() {
 		containerId = getContainerIDFromString(func(keyword string) string {
 			return utils.GetLineByKeyword("/proc/self/cgroup", keyword)
 		})
+		if containerId == "" {
+			containerId = getContainerIDFromMountInfo(func(keyword string) string {
+				return utils.GetLineByKeyword("/proc/self/mountinfo", keyword)
+			})
+		}
 		log.Debugf("Got and cached container id: %s", containerId)
 	})
 
 	return containerId
 }
 
+// the keywords of the cgroup paths created by the container runtimes, with
+// either the cgroupfs or the systemd cgroup driver.
+var cgroupKeywords = []string{
+	"/docker/",        // Docker with cgroupfs
+	"/ecs/",           // Amazon ECS
+	"/kubepods/",      // Kubernetes with cgroupfs
+	"docker-",         // Docker with systemd, e.g., /system.slice/docker-<id>.scope
+	"cri-containerd-", // containerd with systemd
+	"crio-",           // CRI-O with systemd
+	"libpod-",         // Podman
+	"kubepods",        // Kubernetes with systemd, e.g., /kubepods.slice/...
+}
+
+// the keywords of the mount sources of the files (/etc/hostname, etc.) which
+// the container runtimes bind-mount from their per-container directories.
+var mountInfoKeywords = []string{
+	"/docker/containers/",  // Docker: /var/lib/docker/containers/<id>/hostname
+	"/overlay-containers/", // Podman and CRI-O: .../overlay-containers/<id>/userdata/hostname
+}
+
 // getContainerIDFromString initializes the container ID (or empty
-// string if not a docker/ecs container). It accepts a function parameter
+// string if not a container). It accepts a function parameter
 // as the source where it gets container metadata from, which makes it more
 // flexible and enables better testability.
-// A typical line returned by cat /proc/self/cgroup:
+// Typical lines returned by cat /proc/self/cgroup:
 // 9:devices:/docker/40188af19439697187e3f60b933e7e37c5c41035f4c0b266a51c86c5a0074b25
+// 0::/system.slice/docker-40188af19439697187e3f60b933e7e37c5c41035f4c0b266a51c86c5a0074b25.scope
 func getContainerIDFromString(getter func(string) string) string {
-	keywords := []string{"/docker/", "/ecs/", "/kubepods/"}
-	isID := regexp.MustCompile("^[0-9a-f]{64}$").MatchString
-	line := ""
+	return findContainerID(getter, cgroupKeywords)
+}
 
+// getContainerIDFromMountInfo gets the container ID from the mount points
+// (or empty string if not found). It's the fallback when /proc/self/cgroup
+// is '0::/', i.e., the cgroup v2 unified hierarchy in a cgroup namespace.
+// A typical line returned by cat /proc/self/mountinfo:
+// 584 566 254:1 /docker/containers/40188af1...74b25/hostname /etc/hostname rw,relatime - ext4 /dev/vda1 rw
+func getContainerIDFromMountInfo(getter func(string) string) string {
+	return findContainerID(getter, mountInfoKeywords)
+}
+
+// findContainerID returns the first container ID found in the lines which
+// contain the keywords.
+func findContainerID(getter func(string) string, keywords []string) string {
 	for _, keyword := range keywords {
-		if line = getter(keyword); line != "" {
-			break
+		if id := containerIDFromLine(getter(keyword)); id != "" {
+			return id
 		}
 	}
+	return ""
+}
 
-	if line == "" {
-		return ""
-	}
+// containerIDFromLine returns the first path element which is a container ID.
+// The element may have a runtime prefix and a systemd unit suffix, e.g.,
+// cri-containerd-<id>.scope
+func containerIDFromLine(line string) string {
+	// a length of 64 indicates a container ID
+	// ensure token is hex SHA1
+	isID := regexp.MustCompile("^[0-9a-f]{64}$").MatchString
 
-	tokens := strings.Split(line, "/")
-	for _, token := range tokens {
-		// a length of 64 indicates a container ID
-		// ensure token is hex SHA1
+	for _, token := range strings.FieldsFunc(line, func(r rune) bool {
+		return r == '/' || r == ' '
+	}) {
+		token = strings.TrimSuffix(token, ".scope")
+		token = token[strings.LastIndex(token, "-")+1:]
 		if isID(token) {
 			return token
 		}
@@ -258,3 +323,73 @@ func initDyno(dyno *string) {
 		*dyno = ""
 	}
 }
+
+// getK8sMetadata returns the metadata of the Kubernetes pod, or empty metadata
+// if it doesn't run in Kubernetes.
+func getK8sMetadata() K8sMetadata {
+	k8sOnce.Do(func() {
+		k8s = getK8sMetadataFrom(os.LookupEnv, func(path string) string {
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				return ""
+			}
+			return string(b)
+		}, func(keyword string) string {
+			return utils.GetLineByKeyword("/proc/self/cgroup", keyword)
+		})
+		log.Debugf("Got and cached k8s metadata: %+v", k8s)
+	})
+	return k8s
+}
+
+// getK8sMetadataFrom detects Kubernetes from its environment variables or its
+// service account files, and returns the metadata of the pod. The metadata set
+// with the downward API environment variables takes precedence, otherwise the
+// namespace is read from the service account, the pod name is the hostname and
+// the pod UID is read from the cgroup of the process, e.g.
+// 11:memory:/kubepods/burstable/pod2e1a1f0a-46a5-11e9-b9b2-0a580a2c0105/40188af1...
+func getK8sMetadataFrom(lookupEnv func(string) (string, bool), readFile func(string) string,
+	cgroup func(string) string) K8sMetadata {
+	namespace := strings.TrimSpace(readFile(k8sNamespaceFile))
+	if _, ok := lookupEnv(envK8sServiceHost); !ok && namespace == "" {
+		return K8sMetadata{}
+	}
+
+	md := K8sMetadata{Namespace: namespace}
+	if v, ok := lookupEnv(envK8sNamespace); ok && v != "" {
+		md.Namespace = v
+	}
+	if v, ok := lookupEnv(envK8sPodName); ok && v != "" {
+		md.PodName = v
+	} else {
+		md.PodName = Hostname()
+	}
+	if v, ok := lookupEnv(envK8sPodUID); ok && v != "" {
+		md.PodUID = v
+	} else {
+		md.PodUID = getPodUIDFromString(cgroup)
+	}
+	if v, ok := lookupEnv(envK8sNodeName); ok {
+		md.NodeName = v
+	}
+	return md
+}
+
+// getPodUIDFromString returns the pod UID found in the kubepods cgroup of the
+// process, which is also formatted with underscores by the systemd cgroup driver:
+// 1:name=systemd:/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod2e1a1f0a_46a5_11e9_b9b2_0a580a2c0105.slice/...
+func getPodUIDFromString(getter func(string) string) string {
+	isUID := regexp.MustCompile("^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$").MatchString
+	line := getter("kubepods")
+	for _, token := range strings.Split(line, "/") {
+		token = strings.TrimSuffix(token, ".slice")
+		i := strings.LastIndex(token, "pod")
+		if i < 0 {
+			continue
+		}
+		if uid := strings.Replace(token[i+3:], "_", "-", -1); isUID(uid) {
+			return uid
+		}
+	}
+	return ""
+}
@@ -87,9 +87,74 @@ func TestGetContainerID(t *testing.T) {
 		utils.GetLineByKeyword("/proc/self/cgroup", "/ecs/") != "" {
 
 		assert.NotEmpty(t, id)
+	}
+	if id != "" {
 		assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]+$`), id)
-	} else {
-		assert.Empty(t, id)
+	}
+}
+
+// linesGetter returns a getter which returns the first line of the content
+// containing the keyword, as utils.GetLineByKeyword does for a file.
+func linesGetter(content string) func(string) string {
+	return func(keyword string) string {
+		for _, line := range strings.Split(content, "\n") {
+			if strings.Contains(line, keyword) {
+				return line
+			}
+		}
+		return ""
+	}
+}
+
+func TestGetContainerIDFromCgroup(t *testing.T) {
+	const id = "40188af19439697187e3f60b933e7e37c5c41035f4c0b266a51c86c5a0074b25"
+	cases := []struct {
+		name   string
+		cgroup string
+		id     string
+	}{
+		{"docker cgroup v1", `12:pids:/docker/` + id + `
+11:devices:/docker/` + id + `
+1:name=systemd:/docker/` + id, id},
+		{"ecs", `9:perf_event:/ecs/9a3d2ee8-b6a1-4b5e-9b2e-2f4c7d6f8e51/` + id, id},
+		{"kubernetes cgroupfs", `11:freezer:/kubepods/besteffort/pod23b7d80b-7b31-11e8-9fa1-0ea6a2c824d6/` + id, id},
+		{"docker systemd cgroup v2", `0::/system.slice/docker-` + id + `.scope`, id},
+		{"docker systemd cgroup v1", `12:pids:/system.slice/docker-` + id + `.scope
+1:name=systemd:/system.slice/docker-` + id + `.scope`, id},
+		{"containerd systemd cgroup v2",
+			`0::/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod5c4ea4a1_8e21_4d3b_a2ef_5d1f0d3c5b2e.slice/cri-containerd-` + id + `.scope`, id},
+		{"cri-o systemd cgroup v1",
+			`11:memory:/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod5c4ea4a1_8e21_4d3b_a2ef_5d1f0d3c5b2e.slice/crio-` + id + `.scope`, id},
+		{"containerd cgroupfs", `0::/kubepods/burstable/pod5c4ea4a1-8e21-4d3b-a2ef-5d1f0d3c5b2e/` + id, id},
+		{"podman", `0::/user.slice/user-1000.slice/user@1000.service/user.slice/libpod-` + id + `.scope/container`, id},
+		{"invalid id", `11:freezer:/kubepods/besteffort/pod23b7d80b-7b31-11e8-9fa1-0ea6a2c824d6/abc123hello-world`, ""},
+		{"cgroup namespace", `0::/`, ""},
+		{"host systemd service", `0::/system.slice/docker.service`, ""},
+		{"host user session", `0::/user.slice/user-1000.slice/session-2.scope`, ""},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.id, getContainerIDFromString(linesGetter(c.cgroup)), c.name)
+	}
+}
+
+func TestGetContainerIDFromMountInfo(t *testing.T) {
+	const id = "40188af19439697187e3f60b933e7e37c5c41035f4c0b266a51c86c5a0074b25"
+	cases := []struct {
+		name      string
+		mountinfo string
+		id        string
+	}{
+		{"docker", `1226 1195 0:64 / / rw,relatime master:461 - overlay overlay rw,lowerdir=/var/lib/docker/overlay2/l/ZQ2:/var/lib/docker/overlay2/l/7X
+1233 1226 254:1 /docker/containers/` + id + `/resolv.conf /etc/resolv.conf rw,relatime - ext4 /dev/vda1 rw
+1234 1226 254:1 /docker/containers/` + id + `/hostname /etc/hostname rw,relatime - ext4 /dev/vda1 rw`, id},
+		{"podman", `738 722 0:45 /containers/storage/overlay-containers/` + id + `/userdata/hostname /etc/hostname rw,nosuid,nodev - tmpfs tmpfs rw`, id},
+		{"cri-o", `1410 1391 0:24 /containers/storage/overlay-containers/` + id + `/userdata/hostname /etc/hostname rw,nosuid,nodev - tmpfs tmpfs rw`, id},
+		{"no container", `22 1 254:1 / / rw,relatime shared:1 - ext4 /dev/vda1 rw`, ""},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.id, getContainerIDFromMountInfo(linesGetter(c.mountinfo)), c.name)
 	}
 }
 
@@ -176,3 +241,49 @@ func TestInitDyno(t *testing.T) {
 	initDyno(&dyno)
 	assert.Equal(t, "", dyno)
 }
+
+func TestGetK8sMetadataFrom(t *testing.T) {
+	noFile := func(string) string { return "" }
+	cgroup := func(keyword string) string {
+		return "11:freezer:/kubepods/besteffort/pod23b7d80b-7b31-11e8-9fa1-0ea6a2c824d6/32fd701b15f2a907051d3b07b791cc08d45696c3aa372a4764c98c8be9c57626"
+	}
+	env := func(vars map[string]string) func(string) (string, bool) {
+		return func(k string) (string, bool) {
+			v, ok := vars[k]
+			return v, ok
+		}
+	}
+
+	// not in Kubernetes
+	assert.Equal(t, K8sMetadata{}, getK8sMetadataFrom(env(nil), noFile, cgroup))
+
+	// detected from the service account, without the downward API
+	md := getK8sMetadataFrom(env(nil), func(path string) string {
+		if path == k8sNamespaceFile {
+			return "prod\n"
+		}
+		return ""
+	}, cgroup)
+	assert.Equal(t, K8sMetadata{Namespace: "prod", PodName: Hostname(),
+		PodUID: "23b7d80b-7b31-11e8-9fa1-0ea6a2c824d6"}, md)
+
+	// the downward API takes precedence
+	md = getK8sMetadataFrom(env(map[string]string{
+		envK8sServiceHost: "10.0.0.1",
+		envK8sNamespace:   "staging",
+		envK8sPodName:     "web-5d8f7",
+		envK8sPodUID:      "uid-1",
+		envK8sNodeName:    "node-1",
+	}), noFile, cgroup)
+	assert.Equal(t, K8sMetadata{Namespace: "staging", PodName: "web-5d8f7", PodUID: "uid-1", NodeName: "node-1"}, md)
+}
+
+func TestGetPodUIDFromString(t *testing.T) {
+	assert.Equal(t, "2e1a1f0a-46a5-11e9-b9b2-0a580a2c0105", getPodUIDFromString(func(string) string {
+		return "1:name=systemd:/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod2e1a1f0a_46a5_11e9_b9b2_0a580a2c0105.slice/docker-40188af1.scope"
+	}))
+	assert.Equal(t, "", getPodUIDFromString(func(string) string {
+		return "9:devices:/docker/40188af19439697187e3f60b933e7e37c5c41035f4c0b266a51c86c5a0074b25"
+	}))
+	assert.Equal(t, "", getPodUIDFromString(func(string) string { return "" }))
+}
@@ -0,0 +1,268 @@
+// Copyright (C) 2017 Librato, Inc. All rights reserved.
+
+package log
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const envAppOpticsLogDedupWindow = "APPOPTICS_LOG_DEDUP_WINDOW"
+
+// The default window in which the identical messages are collapsed. DEBUG
+// messages are not collapsed by default as they are enabled for
+// troubleshooting only.
+const defaultDedupWindow = time.Minute
+
+// the minimum interval between two sweeps of the expired messages
+const dedupSweepInterval = time.Second
+
+// dedupKey identifies the identical messages. The fields are not part of the
+// key as they usually carry the varying details, e.g., the error.
+type dedupKey struct {
+	level LogLevel
+	msg   string
+}
+
+// dedupEntry records the suppressed occurrences of a message in the window
+type dedupEntry struct {
+	first    time.Time
+	repeated int
+	fields   Fields
+}
+
+// summary is the log message emitted for the suppressed occurrences
+type summary struct {
+	level  LogLevel
+	msg    string
+	fields Fields
+}
+
+// limiter collapses the identical messages logged within a window, which is
+// configured per log level. The first message is logged, the following ones in
+// the window are suppressed and a "message repeated N times" summary is logged
+// after the window expires, by the next message or by a timer.
+type limiter struct {
+	sync.Mutex
+	windows   map[LogLevel]time.Duration
+	seen      map[dedupKey]*dedupEntry
+	lastSweep time.Time
+	// emit logs the summaries of the expired messages flushed by the timer,
+	// which is pending while there are suppressed messages.
+	emit  func([]summary)
+	timer *time.Timer
+}
+
+func newLimiter(emit func([]summary)) *limiter {
+	l := &limiter{seen: make(map[dedupKey]*dedupEntry), emit: emit}
+	l.resetWindows()
+	return l
+}
+
+// the global limiter of the log messages
+var globalLimiter = newLimiter(logSummaries)
+
+// SetDedupWindow sets the window in which the identical messages of the level
+// are collapsed. A window of zero disables the deduplication of the level.
+func SetDedupWindow(level LogLevel, window time.Duration) {
+	globalLimiter.setWindow(level, window)
+}
+
+// DedupWindow returns the window in which the identical messages of the level
+// are collapsed.
+func DedupWindow(level LogLevel) time.Duration {
+	return globalLimiter.window(level)
+}
+
+// DisableDedupForTest disables the deduplication of all the levels until the
+// test ends, so the messages it expects aren't collapsed with the identical
+// ones logged by the previous tests. The windows are restored by t.Cleanup.
+func DisableDedupForTest(t interface{ Cleanup(func()) }) {
+	globalLimiter.Lock()
+	saved := globalLimiter.windows
+	globalLimiter.windows = make(map[LogLevel]time.Duration)
+	globalLimiter.Unlock()
+	t.Cleanup(func() {
+		globalLimiter.Lock()
+		defer globalLimiter.Unlock()
+		globalLimiter.windows = saved
+	})
+}
+
+func (l *limiter) setWindow(level LogLevel, window time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+	if window < 0 {
+		window = 0
+	}
+	l.windows[level] = window
+}
+
+func (l *limiter) window(level LogLevel) time.Duration {
+	l.Lock()
+	defer l.Unlock()
+	return l.windows[level]
+}
+
+// resetWindows restores the default windows of all the levels.
+func (l *limiter) resetWindows() {
+	l.Lock()
+	defer l.Unlock()
+	l.windows = map[LogLevel]time.Duration{
+		INFO:    defaultDedupWindow,
+		WARNING: defaultDedupWindow,
+		ERROR:   defaultDedupWindow,
+	}
+}
+
+// reset drops the recorded messages without logging the summaries.
+func (l *limiter) reset() {
+	l.Lock()
+	defer l.Unlock()
+	l.seen = make(map[dedupKey]*dedupEntry)
+	l.stopTimer()
+}
+
+// flush drops the recorded messages and returns the summaries of the suppressed
+// ones, whether their windows have expired or not.
+func (l *limiter) flush() []summary {
+	l.Lock()
+	defer l.Unlock()
+	var summaries []summary
+	for k, e := range l.seen {
+		summaries = l.expire(k, e, summaries)
+	}
+	l.stopTimer()
+	return summaries
+}
+
+// flushExpired is called by the timer to emit the summaries of the messages
+// whose windows have expired.
+func (l *limiter) flushExpired() {
+	l.Lock()
+	l.timer = nil
+	now := time.Now()
+	summaries := l.sweep(now, nil)
+	l.lastSweep = now
+	l.schedule(now)
+	l.Unlock()
+
+	if len(summaries) > 0 {
+		l.emit(summaries)
+	}
+}
+
+// schedule starts the timer, if it isn't pending, to fire when the window of
+// the first suppressed message expires.
+func (l *limiter) schedule(now time.Time) {
+	if l.emit == nil || l.timer != nil {
+		return
+	}
+	var next time.Time
+	for k, e := range l.seen {
+		if e.repeated == 0 {
+			continue
+		}
+		if end := e.first.Add(l.windows[k.level]); next.IsZero() || end.Before(next) {
+			next = end
+		}
+	}
+	if !next.IsZero() {
+		l.timer = time.AfterFunc(next.Sub(now), l.flushExpired)
+	}
+}
+
+func (l *limiter) stopTimer() {
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+}
+
+// allow checks if the message should be logged at the time provided. It also
+// returns the summaries of the messages whose windows have expired.
+func (l *limiter) allow(level LogLevel, msg string, fields Fields, now time.Time) (bool, []summary) {
+	l.Lock()
+	defer l.Unlock()
+
+	var summaries []summary
+	if now.Sub(l.lastSweep) >= dedupSweepInterval {
+		summaries = l.sweep(now, summaries)
+		l.lastSweep = now
+	}
+
+	window := l.windows[level]
+	if window == 0 {
+		return true, summaries
+	}
+
+	k := dedupKey{level, msg}
+	if e, ok := l.seen[k]; ok {
+		if now.Sub(e.first) < window {
+			e.repeated++
+			e.fields = fields
+			l.schedule(now)
+			return false, summaries
+		}
+		summaries = l.expire(k, e, summaries)
+	}
+	l.seen[k] = &dedupEntry{first: now}
+	return true, summaries
+}
+
+// sweep expires the messages whose windows have passed.
+func (l *limiter) sweep(now time.Time, summaries []summary) []summary {
+	for k, e := range l.seen {
+		if now.Sub(e.first) >= l.windows[k.level] {
+			summaries = l.expire(k, e, summaries)
+		}
+	}
+	return summaries
+}
+
+// expire removes a message and appends its summary if it has been suppressed.
+func (l *limiter) expire(k dedupKey, e *dedupEntry, summaries []summary) []summary {
+	delete(l.seen, k)
+	if e.repeated == 0 {
+		return summaries
+	}
+	return append(summaries, summary{
+		level: k.level,
+		msg: fmt.Sprintf("%s (message repeated %d times in %v)",
+			k.msg, e.repeated, l.windows[k.level]),
+		fields: e.fields.with("repeated", e.repeated),
+	})
+}
+
+// loadEnv restores the default windows and overrides them by the environment
+// variable, which is either a duration applied to the INFO, WARN and ERROR
+// levels, e.g., "30s", or a comma-separated list of level=duration, e.g.,
+// "WARN=1m,ERROR=5m".
+func (l *limiter) loadEnv() {
+	l.resetWindows()
+	s, ok := os.LookupEnv(envAppOpticsLogDedupWindow)
+	if !ok {
+		return
+	}
+	if d, err := time.ParseDuration(strings.TrimSpace(s)); err == nil {
+		for _, level := range []LogLevel{INFO, WARNING, ERROR} {
+			l.setWindow(level, d)
+		}
+		return
+	}
+	for _, item := range strings.Split(s, ",") {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		level, valid := ToLogLevel(kv[0])
+		d, err := time.ParseDuration(strings.TrimSpace(kv[1]))
+		if !valid || err != nil {
+			continue
+		}
+		l.setWindow(level, d)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright (C) 2017 Librato, Inc. All rights reserved.
+
+package log
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(nil)
+	now := time.Now()
+
+	ok, sm := l.allow(WARNING, "collector down", Fields{"error": "e1"}, now)
+	assert.True(t, ok)
+	assert.Empty(t, sm)
+
+	// identical messages in the window are suppressed
+	for i := 1; i <= 3; i++ {
+		ok, sm = l.allow(WARNING, "collector down", Fields{"error": i}, now.Add(time.Duration(i)*time.Second))
+		assert.False(t, ok)
+		assert.Empty(t, sm)
+	}
+
+	// different messages or levels are not affected
+	ok, _ = l.allow(ERROR, "collector down", nil, now.Add(3*time.Second))
+	assert.True(t, ok)
+	ok, _ = l.allow(WARNING, "collector up", nil, now.Add(3*time.Second))
+	assert.True(t, ok)
+
+	// DEBUG messages are not collapsed by default
+	for i := 0; i < 3; i++ {
+		ok, _ = l.allow(DEBUG, "debug", nil, now.Add(3*time.Second))
+		assert.True(t, ok)
+	}
+
+	// the summary is returned with the next message after the window expires
+	ok, sm = l.allow(WARNING, "collector down", nil, now.Add(time.Minute))
+	assert.True(t, ok)
+	require.Len(t, sm, 1)
+	assert.Equal(t, WARNING, sm[0].level)
+	assert.Equal(t, "collector down (message repeated 3 times in 1m0s)", sm[0].msg)
+	assert.Equal(t, Fields{"error": 3, "repeated": 3}, sm[0].fields)
+}
+
+func TestLimiterSweep(t *testing.T) {
+	l := newLimiter(nil)
+	now := time.Now()
+
+	l.allow(ERROR, "failed", nil, now)
+	l.allow(ERROR, "failed", nil, now.Add(time.Second))
+	l.allow(INFO, "once", nil, now)
+
+	// any other message triggers the summaries of the expired ones
+	ok, sm := l.allow(WARNING, "other", nil, now.Add(2*time.Minute))
+	assert.True(t, ok)
+	require.Len(t, sm, 1)
+	assert.Equal(t, "failed (message repeated 1 times in 1m0s)", sm[0].msg)
+	assert.Len(t, l.seen, 1)
+}
+
+func TestLimiterTimer(t *testing.T) {
+	emitted := make(chan []summary, 1)
+	l := newLimiter(func(sm []summary) { emitted <- sm })
+	l.setWindow(ERROR, 50*time.Millisecond)
+	defer l.reset()
+
+	now := time.Now()
+	l.allow(ERROR, "failed", nil, now)
+	l.allow(ERROR, "once", nil, now)
+	assert.Nil(t, l.timer, "no message is suppressed")
+	l.allow(ERROR, "failed", Fields{"error": "e2"}, now)
+	l.allow(ERROR, "failed", Fields{"error": "e3"}, now)
+
+	// the summary is logged when the window expires, without another message
+	select {
+	case sm := <-emitted:
+		require.Len(t, sm, 1)
+		assert.Equal(t, "failed (message repeated 2 times in 50ms)", sm[0].msg)
+		assert.Equal(t, Fields{"error": "e3", "repeated": 2}, sm[0].fields)
+	case <-time.After(time.Second):
+		t.Fatal("the summary is not flushed")
+	}
+	l.Lock()
+	assert.Empty(t, l.seen)
+	assert.Nil(t, l.timer)
+	l.Unlock()
+}
+
+func TestLimiterFlush(t *testing.T) {
+	l := newLimiter(func([]summary) { t.Error("the timer is not stopped") })
+	now := time.Now()
+	l.allow(WARNING, "collector down", nil, now)
+	l.allow(WARNING, "collector down", nil, now.Add(time.Second))
+	l.allow(INFO, "once", nil, now)
+	assert.NotNil(t, l.timer)
+
+	// the suppressed messages are flushed before their windows expire
+	sm := l.flush()
+	require.Len(t, sm, 1)
+	assert.Equal(t, "collector down (message repeated 1 times in 1m0s)", sm[0].msg)
+	assert.Empty(t, l.seen)
+	assert.Nil(t, l.timer)
+	assert.Empty(t, l.flush())
+}
+
+func TestFlush(t *testing.T) {
+	defer SetLogger(nil)
+	defer globalLimiter.reset()
+
+	r := &recordingLogger{}
+	SetLogger(r)
+	globalLimiter.reset()
+
+	Warning("flush test")
+	Warning("flush test")
+	Flush()
+	assert.Equal(t, []string{"flush test", "flush test (message repeated 1 times in 1m0s)"}, r.msgs)
+}
+
+func TestLimiterWindows(t *testing.T) {
+	l := newLimiter(nil)
+	now := time.Now()
+
+	l.setWindow(WARNING, 0)
+	for i := 0; i < 3; i++ {
+		ok, _ := l.allow(WARNING, "not collapsed", nil, now)
+		assert.True(t, ok)
+	}
+
+	defer os.Unsetenv(envAppOpticsLogDedupWindow)
+	os.Setenv(envAppOpticsLogDedupWindow, "30s")
+	l.loadEnv()
+	assert.Equal(t, time.Duration(0), l.window(DEBUG))
+	assert.Equal(t, 30*time.Second, l.window(INFO))
+	assert.Equal(t, 30*time.Second, l.window(WARNING))
+	assert.Equal(t, 30*time.Second, l.window(ERROR))
+
+	os.Setenv(envAppOpticsLogDedupWindow, "debug=10s, WARN=5m,ERROR=0,INFO=invalid")
+	l.loadEnv()
+	assert.Equal(t, 10*time.Second, l.window(DEBUG))
+	assert.Equal(t, time.Minute, l.window(INFO))
+	assert.Equal(t, 5*time.Minute, l.window(WARNING))
+	assert.Equal(t, time.Duration(0), l.window(ERROR))
+}
+
+func TestLogDedup(t *testing.T) {
+	defer SetLogger(nil)
+	defer globalLimiter.reset()
+
+	r := &recordingLogger{}
+	SetLogger(r)
+	globalLimiter.reset()
+
+	for i := 0; i < 5; i++ {
+		Warningf("dedup test %d", 1)
+	}
+	assert.Equal(t, []string{"dedup test 1"}, r.msgs)
+}
+
+func TestDisableDedupForTest(t *testing.T) {
+	defer SetLogger(nil)
+	defer globalLimiter.reset()
+
+	r := &recordingLogger{}
+	SetLogger(r)
+	globalLimiter.reset()
+
+	window := DedupWindow(WARNING)
+	t.Run("disabled", func(t *testing.T) {
+		DisableDedupForTest(t)
+		for _, level := range []LogLevel{DEBUG, INFO, WARNING, ERROR} {
+			assert.Equal(t, time.Duration(0), DedupWindow(level))
+		}
+		Warning("not collapsed")
+		Warning("not collapsed")
+	})
+	assert.Equal(t, []string{"not collapsed", "not collapsed"}, r.msgs)
+	// the windows are restored once the test ends
+	assert.Equal(t, window, DedupWindow(WARNING))
+}
@@ -0,0 +1,193 @@
+// Copyright (C) 2017 Librato, Inc. All rights reserved.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// the field of the file name and line number of the caller, which is only
+// provided for DEBUG messages.
+const fieldCaller = "caller"
+
+// Fields are the structured context of a log message, e.g., the component,
+// the connection name and the error.
+type Fields map[string]interface{}
+
+// with returns a copy of the fields with the key/value added.
+func (f Fields) with(k string, v interface{}) Fields {
+	c := make(Fields, len(f)+1)
+	for fk, fv := range f {
+		c[fk] = fv
+	}
+	c[k] = v
+	return c
+}
+
+// Logger is the backend where the agent's log messages are written to. The
+// messages have been filtered by the current log level before being passed
+// to the logger. Implementations must be safe for concurrent use.
+type Logger interface {
+	Log(level LogLevel, msg string, fields Fields)
+}
+
+// loggerHolder makes it possible to store loggers of different types in the
+// same atomic.Value.
+type loggerHolder struct {
+	Logger
+}
+
+var globalLogger atomic.Value
+
+// SetLogger replaces the logger which the agent's log messages are written
+// to. A nil logger restores the default one, which writes plain texts through
+// the standard library's log package.
+func SetLogger(l Logger) {
+	if l == nil {
+		l = textLogger{}
+	}
+	globalLogger.Store(loggerHolder{l})
+}
+
+func currentLogger() Logger {
+	if h, ok := globalLogger.Load().(loggerHolder); ok {
+		return h.Logger
+	}
+	return textLogger{}
+}
+
+// textLogger is the default logger, which writes a line like
+// "WARN  [AO] message key=value" through the standard library's log package.
+type textLogger struct{}
+
+func (textLogger) Log(level LogLevel, msg string, fields Fields) {
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("%-5s [AO] ", level))
+	if caller, ok := fields[fieldCaller]; ok {
+		buffer.WriteString(fmt.Sprintf("%v ", caller))
+	}
+	buffer.WriteString(msg)
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		if k != fieldCaller {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := fmt.Sprint(fields[k])
+		if strings.ContainsAny(v, " =\"") {
+			v = fmt.Sprintf("%q", v)
+		}
+		buffer.WriteString(fmt.Sprintf(" %s=%s", k, v))
+	}
+
+	log.Print(buffer.String())
+}
+
+// jsonLogger writes each log message as a JSON object in a single line.
+type jsonLogger struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONLogger returns a logger which writes each log message to w as a JSON
+// object in a single line, with the time, level and msg keys besides the
+// fields of the message.
+func NewJSONLogger(w io.Writer) Logger {
+	return &jsonLogger{w: w}
+}
+
+func (l *jsonLogger) Log(level LogLevel, msg string, fields Fields) {
+	m := make(map[string]interface{}, len(fields)+3)
+	for k, v := range fields {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		m[k] = v
+	}
+	m["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	m["level"] = level.String()
+	m["msg"] = msg
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		// fall back to the string representation of the unsupported values
+		for k, v := range fields {
+			m[k] = fmt.Sprint(v)
+		}
+		if b, err = json.Marshal(m); err != nil {
+			return
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(append(b, '\n'))
+}
+
+// Entry is a log message builder with the structured fields attached.
+type Entry struct {
+	fields Fields
+}
+
+// WithFields returns a log message builder with the fields attached, e.g.,
+//
+//	log.WithFields(log.Fields{"connection": name, "error": err}).Warning("invocation error")
+func WithFields(fields Fields) Entry {
+	return Entry{fields: fields}
+}
+
+// Debugf formats the log message with specified args
+// and print it in the DEBUG level
+func (e Entry) Debugf(msg string, args ...interface{}) {
+	logIt(DEBUG, e.fields, msg, args)
+}
+
+// Debug prints the log message in the DEBUG level
+func (e Entry) Debug(args ...interface{}) {
+	logIt(DEBUG, e.fields, "", args)
+}
+
+// Infof formats the log message with specified args
+// and print it in the INFO level
+func (e Entry) Infof(msg string, args ...interface{}) {
+	logIt(INFO, e.fields, msg, args)
+}
+
+// Info prints the log message in the INFO level
+func (e Entry) Info(args ...interface{}) {
+	logIt(INFO, e.fields, "", args)
+}
+
+// Warningf formats the log message with specified args
+// and print it in the WARNING level
+func (e Entry) Warningf(msg string, args ...interface{}) {
+	logIt(WARNING, e.fields, msg, args)
+}
+
+// Warning prints the log message in the WARNING level
+func (e Entry) Warning(args ...interface{}) {
+	logIt(WARNING, e.fields, "", args)
+}
+
+// Errorf formats the log message with specified args
+// and print it in the ERROR level
+func (e Entry) Errorf(msg string, args ...interface{}) {
+	logIt(ERROR, e.fields, msg, args)
+}
+
+// Error prints the log message in the ERROR level
+func (e Entry) Error(args ...interface{}) {
+	logIt(ERROR, e.fields, "", args)
+}
@@ -0,0 +1,116 @@
+// Copyright (C) 2017 Librato, Inc. All rights reserved.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingLogger struct {
+	levels []LogLevel
+	msgs   []string
+	fields []Fields
+}
+
+func (r *recordingLogger) Log(level LogLevel, msg string, fields Fields) {
+	r.levels = append(r.levels, level)
+	r.msgs = append(r.msgs, msg)
+	r.fields = append(r.fields, fields)
+}
+
+func TestSetLogger(t *testing.T) {
+	defer SetLogger(nil)
+	defer SetLevel(Level())
+	defer globalLimiter.reset()
+
+	r := &recordingLogger{}
+	SetLogger(r)
+	SetLevel(INFO)
+	globalLimiter.reset()
+
+	Debug("dropped")
+	Warningf("hello %s", "world")
+	WithFields(Fields{"connection": "events"}).Error("failed")
+
+	assert.Equal(t, []LogLevel{WARNING, ERROR}, r.levels)
+	assert.Equal(t, []string{"hello world", "failed"}, r.msgs)
+	assert.Nil(t, r.fields[0])
+	assert.Equal(t, Fields{"connection": "events"}, r.fields[1])
+
+	SetLevel(DEBUG)
+	fields := Fields{"component": "reporter"}
+	WithFields(fields).Debugf("debug %d", 1)
+	assert.Equal(t, "debug 1", r.msgs[2])
+	assert.Equal(t, "reporter", r.fields[2]["component"])
+	assert.True(t, strings.HasPrefix(r.fields[2][fieldCaller].(string), "logger_test.go:"))
+	assert.NotContains(t, fields, fieldCaller)
+}
+
+func TestTextLogger(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	textLogger{}.Log(WARNING, "invocation error", Fields{
+		"connection": "events",
+		"error":      errors.New("connection refused"),
+	})
+	assert.True(t, strings.HasSuffix(buf.String(),
+		`WARN  [AO] invocation error connection=events error="connection refused"`+"\n"), buf.String())
+
+	buf.Reset()
+	textLogger{}.Log(DEBUG, "hello", Fields{fieldCaller: "logger.go:10"})
+	assert.True(t, strings.HasSuffix(buf.String(), "DEBUG [AO] logger.go:10 hello\n"), buf.String())
+}
+
+func TestJSONLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewJSONLogger(&buf)
+
+	l.Log(ERROR, "invocation error", Fields{
+		"connection": "events",
+		"error":      errors.New("connection refused"),
+		"retries":    3,
+		"callback":   func() {},
+	})
+	l.Log(INFO, "connected", nil)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var m map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &m))
+	assert.Equal(t, "ERROR", m["level"])
+	assert.Equal(t, "invocation error", m["msg"])
+	assert.Equal(t, "events", m["connection"])
+	assert.Equal(t, "connection refused", m["error"])
+	assert.Equal(t, "3", m["retries"])
+	assert.NotEmpty(t, m["time"])
+
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &m))
+	assert.Equal(t, "INFO", m["level"])
+}
+
+func TestLogFormatEnv(t *testing.T) {
+	defer func() {
+		os.Unsetenv("APPOPTICS_LOG_FORMAT")
+		initLog()
+	}()
+
+	os.Setenv("APPOPTICS_LOG_FORMAT", "JSON")
+	initLog()
+	assert.IsType(t, &jsonLogger{}, currentLogger())
+
+	os.Setenv("APPOPTICS_LOG_FORMAT", "text")
+	initLog()
+	assert.IsType(t, textLogger{}, currentLogger())
+}
@@ -5,16 +5,15 @@
 package log
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 // LogLevel is a type that defines the log level.
@@ -36,7 +35,8 @@ const (
 )
 
 const (
-	envAppOpticsLogLevel = "APPOPTICS_DEBUG_LEVEL"
+	envAppOpticsLogLevel  = "APPOPTICS_DEBUG_LEVEL"
+	envAppOpticsLogFormat = "APPOPTICS_LOG_FORMAT"
 )
 
 // LevelStr represents the log levels in strings
@@ -66,6 +66,14 @@ func initLog() {
 		}
 	}
 	SetLevel(level)
+	globalLimiter.loadEnv()
+
+	var logger Logger
+	if s, ok := os.LookupEnv(envAppOpticsLogFormat); ok &&
+		strings.ToLower(strings.TrimSpace(s)) == "json" {
+		logger = NewJSONLogger(os.Stderr)
+	}
+	SetLogger(logger)
 }
 
 // ToLogLevel converts a string to a log level, or returns false for any error
@@ -90,6 +98,14 @@ func ToLogLevel(level string) (LogLevel, bool) {
 	return lvl, true
 }
 
+// String returns the name of the log level, e.g., "WARN"
+func (l LogLevel) String() string {
+	if int(l) < len(LevelStr) {
+		return LevelStr[l]
+	}
+	return strconv.Itoa(int(l))
+}
+
 // SetLevel sets the log level of AppOptics agent
 func (l *logLevel) SetLevel(level LogLevel) {
 	l.Lock()
@@ -141,16 +157,14 @@ func shouldLog(lv LogLevel) bool {
 }
 
 // logIt prints logs based on the debug level.
-func logIt(level LogLevel, msg string, args []interface{}) {
+func logIt(level LogLevel, fields Fields, msg string, args []interface{}) {
 	if !shouldLog(level) {
 		return
 	}
 
-	var buffer bytes.Buffer
 	// layer 1: logIt(), layer 2: its wrappers, e.g., Info()
 	const numberOfLayersToSkip = 2
 
-	var pre string
 	if level == DEBUG {
 		// `runtime.Caller()` is called here to get the metadata of the caller of `Caller`:
 		// the program counter, file name, and line number within the file of the corresponding call.
@@ -159,17 +173,12 @@ func logIt(level LogLevel, msg string, args []interface{}) {
 		// skip = 2 is used here as there are wrappers on top of `logIt` (Info,
 		// Infof, Error, etc). By skipping two layers (logIt and its wrapper), you may get
 		// the information of real callers of the logging functions.
-		_, file, line, ok := runtime.Caller(numberOfLayersToSkip)
-		if ok {
-			pre = fmt.Sprintf("%-5s [AO] %s:%d ", LevelStr[level], filepath.Base(file), line)
-		} else {
-			pre = fmt.Sprintf("%-5s [AO] %s:%s ", LevelStr[level], "na", "na")
+		caller := "na:na"
+		if _, file, line, ok := runtime.Caller(numberOfLayersToSkip); ok {
+			caller = fmt.Sprintf("%s:%d", filepath.Base(file), line)
 		}
-	} else { // avoid expensive reflections in production
-		pre = fmt.Sprintf("%-5s [AO] ", LevelStr[level])
-	}
-
-	buffer.WriteString(pre)
+		fields = fields.with(fieldCaller, caller)
+	} // avoid expensive reflections in production
 
 	s := msg
 	if msg == "" {
@@ -177,62 +186,82 @@ func logIt(level LogLevel, msg string, args []interface{}) {
 	} else {
 		s = fmt.Sprintf(msg, args...)
 	}
-	buffer.WriteString(s)
 
-	log.Print(buffer.String())
+	ok, summaries := globalLimiter.allow(level, s, fields, time.Now())
+	logSummaries(summaries)
+	if ok {
+		currentLogger().Log(level, s, fields)
+	}
+}
+
+// logSummaries logs the summaries of the suppressed messages.
+func logSummaries(summaries []summary) {
+	if len(summaries) == 0 {
+		return
+	}
+	logger := currentLogger()
+	for _, sm := range summaries {
+		logger.Log(sm.level, sm.msg, sm.fields)
+	}
+}
+
+// Flush logs the summaries of the suppressed identical messages right away,
+// e.g. before the program exits, instead of when their windows expire.
+func Flush() {
+	logSummaries(globalLimiter.flush())
 }
 
 // Logf formats the log message with specified args
 // and print it in the specified level
 func Logf(level LogLevel, msg string, args ...interface{}) {
-	logIt(level, msg, args)
+	logIt(level, nil, msg, args)
 }
 
 // Log prints the log message in the specified level
 func Log(level LogLevel, args ...interface{}) {
-	logIt(level, "", args)
+	logIt(level, nil, "", args)
 }
 
 // Debugf formats the log message with specified args
 // and print it in the specified level
 func Debugf(msg string, args ...interface{}) {
-	logIt(DEBUG, msg, args)
+	logIt(DEBUG, nil, msg, args)
 }
 
 // Debug prints the log message in the specified level
 func Debug(args ...interface{}) {
-	logIt(DEBUG, "", args)
+	logIt(DEBUG, nil, "", args)
 }
 
 // Infof formats the log message with specified args
 // and print it in the specified level
 func Infof(msg string, args ...interface{}) {
-	logIt(INFO, msg, args)
+	logIt(INFO, nil, msg, args)
 }
 
 // Info prints the log message in the specified level
 func Info(args ...interface{}) {
-	logIt(INFO, "", args)
+	logIt(INFO, nil, "", args)
 }
 
 // Warningf formats the log message with specified args
 // and print it in the specified level
 func Warningf(msg string, args ...interface{}) {
-	logIt(WARNING, msg, args)
+	logIt(WARNING, nil, msg, args)
 }
 
 // Warning prints the log message in the specified level
 func Warning(args ...interface{}) {
-	logIt(WARNING, "", args)
+	logIt(WARNING, nil, "", args)
 }
 
 // Errorf formats the log message with specified args
 // and print it in the specified level
 func Errorf(msg string, args ...interface{}) {
-	logIt(ERROR, msg, args)
+	logIt(ERROR, nil, msg, args)
 }
 
 // Error prints the log message in the specified level
 func Error(args ...interface{}) {
-	logIt(ERROR, "", args)
+	logIt(ERROR, nil, "", args)
 }
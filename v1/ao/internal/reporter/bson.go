@@ -71,6 +71,16 @@ func bsonAppendBool(b *bsonBuffer, k string, v bool) {
 	}
 }
 
+// bsonAppendStringMap appends the map as a sub-document, in increasing order of
+// its keys.
+func bsonAppendStringMap(b *bsonBuffer, k string, m map[string]string) {
+	start := bsonAppendStartObject(b, k)
+	for _, name := range sortedKeys(m) {
+		bsonAppendString(b, name, m[name])
+	}
+	bsonAppendFinishObject(b, start)
+}
+
 func bsonAppendStartObject(b *bsonBuffer, k string) (start int) {
 	b.addElemName('\x03', k)
 	start = b.reserveInt32()
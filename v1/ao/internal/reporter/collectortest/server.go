@@ -0,0 +1,290 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+// Package collectortest provides an in-process fake TraceCollector gRPC server,
+// serving TLS with a self-signed certificate, to test the gRPC reporter end to end.
+//
+//	s, err := collectortest.NewServer("localhost:0")
+//	defer s.Stop()
+//	s.Respond(collectortest.PostEvents, collectortest.Response{Result: collector.ResultCode_TRY_LATER})
+//	// ... connect with s.Addr and s.CertPEM, post events ...
+//	events := s.Events()
+//
+// The responses queued for a method are returned to its next calls, after which
+// each call succeeds.
+package collectortest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/reporter/collector"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Method is a method of the TraceCollector service.
+type Method string
+
+// The methods of the TraceCollector service.
+const (
+	PostEvents  Method = "PostEvents"
+	PostMetrics Method = "PostMetrics"
+	PostStatus  Method = "PostStatus"
+	GetSettings Method = "GetSettings"
+	Ping        Method = "Ping"
+)
+
+// Response is the response of the server to a call.
+type Response struct {
+	Result collector.ResultCode
+	Arg    string        // e.g. the address to redirect to
+	Delay  time.Duration // delay before responding, e.g. to make the call time out
+	Err    error         // gRPC error returned instead of a result
+}
+
+// Server is a fake TraceCollector gRPC server, which captures the requests it
+// receives.
+type Server struct {
+	// Addr is the address the server listens on.
+	Addr string
+	// CertPEM is the PEM encoded self-signed certificate of the server, which
+	// is valid for localhost and 127.0.0.1. It is shared by all the servers, so
+	// that a client can be redirected from one to another.
+	CertPEM []byte
+
+	grpcServer *grpc.Server
+
+	mutex        sync.Mutex
+	responses    map[Method][]Response
+	settings     []*collector.OboeSetting
+	events       []*collector.MessageRequest
+	metrics      []*collector.MessageRequest
+	status       []*collector.MessageRequest
+	settingsReqs []*collector.SettingsRequest
+	pings        int
+}
+
+// DefaultSettings are the settings returned by GetSettings by default, sampling
+// every request.
+var DefaultSettings = []*collector.OboeSetting{{
+	Type:      collector.OboeSettingType_DEFAULT_SAMPLE_RATE,
+	Flags:     []byte("SAMPLE_START,SAMPLE_THROUGH_ALWAYS"),
+	Value:     1000000,
+	Arguments: map[string][]byte{},
+	Ttl:       120,
+}}
+
+var (
+	certOnce sync.Once
+	cert     tls.Certificate
+	certPEM  []byte
+	certErr  error
+)
+
+// NewServer starts a server listening on the address provided, e.g. "localhost:0"
+// to listen on a free port.
+func NewServer(addr string) (*Server, error) {
+	certOnce.Do(func() { cert, certPEM, certErr = selfSignedCert() })
+	if err := certErr; err != nil {
+		return nil, err
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	creds := credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{cert}})
+	s := &Server{
+		Addr:       lis.Addr().String(),
+		CertPEM:    certPEM,
+		grpcServer: grpc.NewServer(grpc.Creds(creds)),
+		responses:  make(map[Method][]Response),
+		settings:   DefaultSettings,
+	}
+	collector.RegisterTraceCollectorServer(s.grpcServer, s)
+	go s.grpcServer.Serve(lis)
+	return s, nil
+}
+
+// Stop stops the server, closing its connections.
+func (s *Server) Stop() { s.grpcServer.Stop() }
+
+// Respond queues the responses provided for the next calls of the method.
+func (s *Server) Respond(m Method, responses ...Response) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.responses[m] = append(s.responses[m], responses...)
+}
+
+// SetSettings sets the settings returned by GetSettings.
+func (s *Server) SetSettings(settings ...*collector.OboeSetting) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.settings = settings
+}
+
+// Events returns the PostEvents requests received.
+func (s *Server) Events() []*collector.MessageRequest { return s.requests(&s.events) }
+
+// Metrics returns the PostMetrics requests received.
+func (s *Server) Metrics() []*collector.MessageRequest { return s.requests(&s.metrics) }
+
+// Status returns the PostStatus requests received.
+func (s *Server) Status() []*collector.MessageRequest { return s.requests(&s.status) }
+
+// SettingsRequests returns the GetSettings requests received.
+func (s *Server) SettingsRequests() []*collector.SettingsRequest {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*collector.SettingsRequest(nil), s.settingsReqs...)
+}
+
+// Pings returns the number of Ping requests received.
+func (s *Server) Pings() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.pings
+}
+
+func (s *Server) requests(reqs *[]*collector.MessageRequest) []*collector.MessageRequest {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*collector.MessageRequest(nil), *reqs...)
+}
+
+// Decode decodes the BSON messages of the requests provided, e.g. the events
+// returned by Events.
+func Decode(reqs []*collector.MessageRequest) ([]bson.M, error) {
+	var msgs []bson.M
+	for _, req := range reqs {
+		for _, buf := range req.Messages {
+			m := bson.M{}
+			if err := bson.Unmarshal(buf, &m); err != nil {
+				return nil, err
+			}
+			msgs = append(msgs, m)
+		}
+	}
+	return msgs, nil
+}
+
+// respond returns the next response queued for the method, after its delay.
+func (s *Server) respond(ctx context.Context, m Method) (Response, error) {
+	s.mutex.Lock()
+	var resp Response
+	if queued := s.responses[m]; len(queued) > 0 {
+		resp, s.responses[m] = queued[0], queued[1:]
+	}
+	s.mutex.Unlock()
+
+	if resp.Delay > 0 {
+		select {
+		case <-time.After(resp.Delay):
+		case <-ctx.Done():
+			return resp, ctx.Err()
+		}
+	}
+	return resp, resp.Err
+}
+
+func (s *Server) postMessages(ctx context.Context, m Method, reqs *[]*collector.MessageRequest,
+	req *collector.MessageRequest) (*collector.MessageResult, error) {
+	s.mutex.Lock()
+	*reqs = append(*reqs, req)
+	s.mutex.Unlock()
+
+	resp, err := s.respond(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	return &collector.MessageResult{Result: resp.Result, Arg: resp.Arg}, nil
+}
+
+// PostEvents captures the events request.
+func (s *Server) PostEvents(ctx context.Context, req *collector.MessageRequest) (*collector.MessageResult, error) {
+	return s.postMessages(ctx, PostEvents, &s.events, req)
+}
+
+// PostMetrics captures the metrics request.
+func (s *Server) PostMetrics(ctx context.Context, req *collector.MessageRequest) (*collector.MessageResult, error) {
+	return s.postMessages(ctx, PostMetrics, &s.metrics, req)
+}
+
+// PostStatus captures the status request.
+func (s *Server) PostStatus(ctx context.Context, req *collector.MessageRequest) (*collector.MessageResult, error) {
+	return s.postMessages(ctx, PostStatus, &s.status, req)
+}
+
+// GetSettings captures the settings request and returns the settings of the
+// server, unless its response isn't OK.
+func (s *Server) GetSettings(ctx context.Context, req *collector.SettingsRequest) (*collector.SettingsResult, error) {
+	s.mutex.Lock()
+	s.settingsReqs = append(s.settingsReqs, req)
+	settings := s.settings
+	s.mutex.Unlock()
+
+	resp, err := s.respond(ctx, GetSettings)
+	if err != nil {
+		return nil, err
+	}
+	result := &collector.SettingsResult{Result: resp.Result, Arg: resp.Arg}
+	if resp.Result == collector.ResultCode_OK {
+		result.Settings = settings
+	}
+	return result, nil
+}
+
+// Ping counts the ping request.
+func (s *Server) Ping(ctx context.Context, req *collector.PingRequest) (*collector.MessageResult, error) {
+	s.mutex.Lock()
+	s.pings++
+	s.mutex.Unlock()
+
+	resp, err := s.respond(ctx, Ping)
+	if err != nil {
+		return nil, err
+	}
+	return &collector.MessageResult{Result: resp.Result, Arg: resp.Arg}, nil
+}
+
+// selfSignedCert returns a self-signed certificate for localhost, and its PEM encoding.
+func selfSignedCert() (tls.Certificate, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	return cert, certPEM, err
+}
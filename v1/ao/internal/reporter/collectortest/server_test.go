@@ -0,0 +1,97 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package collectortest
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/reporter/collector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func dial(t *testing.T, s *Server) (collector.TraceCollectorClient, func()) {
+	pool := x509.NewCertPool()
+	require.True(t, pool.AppendCertsFromPEM(s.CertPEM))
+	creds := credentials.NewTLS(&tls.Config{RootCAs: pool, ServerName: "localhost"})
+	conn, err := grpc.Dial(s.Addr, grpc.WithTransportCredentials(creds))
+	require.NoError(t, err)
+	return collector.NewTraceCollectorClient(conn), func() { conn.Close() }
+}
+
+func TestServer(t *testing.T) {
+	s, err := NewServer("localhost:0")
+	require.NoError(t, err)
+	defer s.Stop()
+	client, closeConn := dial(t, s)
+	defer closeConn()
+	ctx := context.Background()
+
+	msg, err := bson.Marshal(bson.M{"Layer": "test"})
+	require.NoError(t, err)
+	s.Respond(PostEvents, Response{Result: collector.ResultCode_TRY_LATER},
+		Response{Result: collector.ResultCode_REDIRECT, Arg: "localhost:1234"})
+	for _, expected := range []collector.ResultCode{collector.ResultCode_TRY_LATER,
+		collector.ResultCode_REDIRECT, collector.ResultCode_OK} {
+		res, err := client.PostEvents(ctx, &collector.MessageRequest{ApiKey: "key", Messages: [][]byte{msg}})
+		require.NoError(t, err)
+		assert.Equal(t, expected, res.Result)
+	}
+	assert.Len(t, s.Events(), 3)
+	msgs, err := Decode(s.Events())
+	require.NoError(t, err)
+	require.Len(t, msgs, 3)
+	assert.Equal(t, "test", msgs[0]["Layer"])
+
+	_, err = client.PostMetrics(ctx, &collector.MessageRequest{Messages: [][]byte{msg}})
+	assert.NoError(t, err)
+	_, err = client.PostStatus(ctx, &collector.MessageRequest{Messages: [][]byte{msg}})
+	assert.NoError(t, err)
+	assert.Len(t, s.Metrics(), 1)
+	assert.Len(t, s.Status(), 1)
+
+	settings, err := client.GetSettings(ctx, &collector.SettingsRequest{ApiKey: "key"})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultSettings[0].Value, settings.Settings[0].Value)
+	s.SetSettings()
+	s.Respond(GetSettings, Response{Result: collector.ResultCode_INVALID_API_KEY})
+	settings, err = client.GetSettings(ctx, &collector.SettingsRequest{ApiKey: "invalid"})
+	require.NoError(t, err)
+	assert.Equal(t, collector.ResultCode_INVALID_API_KEY, settings.Result)
+	assert.Empty(t, settings.Settings)
+	assert.Len(t, s.SettingsRequests(), 2)
+
+	_, err = client.Ping(ctx, &collector.PingRequest{ApiKey: "key"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, s.Pings())
+}
+
+func TestServerErrors(t *testing.T) {
+	s, err := NewServer("localhost:0")
+	require.NoError(t, err)
+	defer s.Stop()
+	client, closeConn := dial(t, s)
+	defer closeConn()
+
+	s.Respond(Ping, Response{Delay: time.Second}, Response{Err: errors.New("unavailable")})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.Ping(ctx, &collector.PingRequest{})
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	_, err = client.Ping(context.Background(), &collector.PingRequest{})
+	assert.Error(t, err)
+	assert.Equal(t, 2, s.Pings())
+
+	_, err = Decode([]*collector.MessageRequest{{Messages: [][]byte{{1, 2}}}})
+	assert.Error(t, err)
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/config"
 	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/log"
 )
 
@@ -349,6 +350,10 @@ func NewContext(layer, mdStr string, reportEntry bool, cb func() map[string]inte
 			}
 			kvs["SampleRate"] = rate
 			kvs["SampleSource"] = source
+			if tags := config.GetHostTags(); config.GetTraceHostTags() && len(tags) > 0 {
+				// reported in a sub-document, as in the metrics messages
+				kvs[hostTagsKey] = tags
+			}
 			if _, ok = ctx.(*oboeContext); !ok {
 				return &nullContext{}, false
 			}
@@ -6,12 +6,15 @@ import (
 	"bytes"
 	"crypto/rand"
 	"errors"
+	"os"
 	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/config"
 	g "github.com/appoptics/appoptics-apm-go/v1/ao/internal/graphtest"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2/bson"
 )
 
 func TestMetadata(t *testing.T) {
@@ -244,6 +247,40 @@ func TestNewContext(t *testing.T) {
 	})
 }
 
+func TestNewContextHostTags(t *testing.T) {
+	os.Setenv("APPOPTICS_HOST_TAGS", "env=prod,team=payments")
+	config.Refresh()
+	defer func() {
+		os.Unsetenv("APPOPTICS_HOST_TAGS")
+		os.Unsetenv("APPOPTICS_TRACE_HOST_TAGS")
+		config.Refresh()
+	}()
+
+	r := SetTestReporter()
+	_, ok := NewContext("testNoTags", "", true, nil)
+	assert.True(t, ok)
+
+	os.Setenv("APPOPTICS_TRACE_HOST_TAGS", "true")
+	config.Refresh()
+	_, ok = NewContext("testTags", "", true, func() map[string]interface{} {
+		return map[string]interface{}{"team": "checkout"}
+	})
+	assert.True(t, ok)
+	r.Close(2)
+
+	g.AssertGraph(t, r.EventBufs, 2, g.AssertNodeMap{
+		{"testNoTags", "entry"}: {Callback: func(n g.Node) {
+			assert.NotContains(t, n.Map, "HostTags")
+		}},
+		{"testTags", "entry"}: {Callback: func(n g.Node) {
+			// the host tags don't collide with the KVs provided by the caller
+			assert.Equal(t, bson.D{{Name: "env", Value: "prod"}, {Name: "team", Value: "payments"}}, n.Map["HostTags"])
+			assert.Equal(t, "checkout", n.Map["team"])
+			assert.NotContains(t, n.Map, "env")
+		}},
+	})
+}
+
 func TestNewContextTracingDisabled(t *testing.T) {
 	r := SetTestReporter(TestReporterDisableTracing()) // set up test reporter
 
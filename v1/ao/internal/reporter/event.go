@@ -9,13 +9,16 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"time"
 
 	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/log"
 )
 
 type event struct {
-	metadata oboeMetadata
-	bbuf     bsonBuffer
+	metadata  oboeMetadata
+	bbuf      bsonBuffer
+	timestamp time.Time // the time of the event, if not the time it's reported
+	edges     []string  // op IDs of the edges already added to the event
 }
 
 // Label is a required event attribute.
@@ -30,6 +33,7 @@ const (
 	LabelProfileEntry = "profile_entry"
 	LabelProfileExit  = "profile_exit"
 	EdgeKey           = "Edge"
+	TimestampKey      = "Timestamp_u"
 )
 
 const (
@@ -149,7 +153,7 @@ func (e *event) AddFloat64(key string, value float64) { bsonAppendFloat64(&e.bbu
 func (e *event) AddBool(key string, value bool) { bsonAppendBool(&e.bbuf, key, value) }
 
 // Adds edge (reference to previous event) to event
-func (e *event) AddEdge(ctx *oboeContext) { bsonAppendString(&e.bbuf, EdgeKey, ctx.metadata.opString()) }
+func (e *event) AddEdge(ctx *oboeContext) { e.addEdge(ctx.metadata.opString()) }
 
 func (e *event) AddEdgeFromMetadataString(mdstr string) {
 	var md oboeMetadata
@@ -157,10 +161,21 @@ func (e *event) AddEdgeFromMetadataString(mdstr string) {
 	err := md.FromString(mdstr)
 	// only add Edge if metadata references same trace as ours
 	if err == nil && bytes.Equal(e.metadata.ids.taskID, md.ids.taskID) {
-		bsonAppendString(&e.bbuf, EdgeKey, md.opString())
+		e.addEdge(md.opString())
 	}
 }
 
+// addEdge adds an edge to the op ID provided, unless the event already has it.
+func (e *event) addEdge(op string) {
+	for _, edge := range e.edges {
+		if edge == op {
+			return
+		}
+	}
+	e.edges = append(e.edges, op)
+	bsonAppendString(&e.bbuf, EdgeKey, op)
+}
+
 // Add any key/value to event. May not add KV if key or value is invalid. Used to facilitate
 // reporting variadic args.
 func (e *event) AddKV(key, value interface{}) error {
@@ -177,6 +192,16 @@ func (e *event) AddKV(key, value interface{}) error {
 		} else {
 			e.AddString(k, v)
 		}
+	case []string:
+		// a list of strings is reported as repeated KVs, e.g. multiple edges
+		for _, str := range v {
+			if err := e.AddKV(k, str); err != nil {
+				return err
+			}
+		}
+	case map[string]string:
+		// a map of strings is reported as a sub-document, e.g. the host tags
+		bsonAppendStringMap(&e.bbuf, k, v)
 	case []byte:
 		e.AddBinary(k, v)
 	case int:
@@ -207,6 +232,11 @@ func (e *event) AddKV(key, value interface{}) error {
 		}
 	case sampleSource:
 		e.AddInt(k, int(v))
+	case time.Time:
+		// the event is reported with the time provided instead of the current time
+		if k == TimestampKey && !v.IsZero() {
+			e.timestamp = v
+		}
 
 	// allow reporting of pointers to basic types as well (for delayed evaluation)
 	case *string:
@@ -177,3 +177,33 @@ func TestEventNoEdge(t *testing.T) {
 		{"go_test", "exit"}:  {},
 	})
 }
+
+func TestEventMultipleEdges(t *testing.T) {
+	r := SetTestReporter()
+	ctx := newTestContext(t)
+	a, err := ctx.newEvent(LabelEntry, "a")
+	assert.NoError(t, err)
+	assert.NoError(t, a.Report(ctx))
+	b, err := ctx.newEvent(LabelEntry, "b")
+	assert.NoError(t, err)
+	assert.NoError(t, b.Report(ctx))
+
+	// a list of edges is reported as repeated Edge KVs, skipping duplicates
+	// and edges to other traces
+	c, err := ctx.newEvent(LabelEntry, "c")
+	assert.NoError(t, err)
+	ctx2 := newContext(true)
+	assert.NoError(t, c.AddKV(EdgeKey, []string{a.MetadataString(), b.MetadataString(),
+		a.MetadataString(), ctx2.MetadataString()}))
+	assert.NoError(t, c.AddKV("Link", []string{"l1", "l2"}))
+	assert.NoError(t, c.Report(ctx))
+
+	r.Close(3)
+	g.AssertGraph(t, r.EventBufs, 3, g.AssertNodeMap{
+		{"a", "entry"}: {},
+		{"b", "entry"}: {},
+		{"c", "entry"}: {Edges: g.Edges{{"a", "entry"}, {"b", "entry"}}, Callback: func(n g.Node) {
+			assert.Len(t, n.Edges, 2)
+		}},
+	})
+}
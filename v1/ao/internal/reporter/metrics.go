@@ -13,6 +13,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/config"
 	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/hdrhist"
 	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/host"
 	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/log"
@@ -26,6 +27,8 @@ const (
 
 	metricsTagNameLengthMax  = 64  // max number of characters for tag names
 	metricsTagValueLengthMax = 255 // max number of characters for tag values
+
+	hostTagsKey = "HostTags" // the sub-document of the user-defined host tags
 )
 
 // Special transaction names
@@ -227,6 +230,7 @@ func generateMetricsMessage(metricsFlushInterval int, queueStats *eventQueueStat
 	bbuf := NewBsonBuffer()
 
 	appendHostId(bbuf)
+	appendHostTags(bbuf, config.GetHostTags())
 	bsonAppendInt64(bbuf, "Timestamp_u", int64(time.Now().UnixNano()/1000))
 	bsonAppendInt(bbuf, "MetricsFlushInterval", metricsFlushInterval)
 
@@ -309,7 +313,8 @@ func generateMetricsMessage(metricsFlushInterval int, queueStats *eventQueueStat
 	return bbuf.buf
 }
 
-// append host ID to a BSON buffer
+// append host ID to a BSON buffer. It includes the Kubernetes and cloud metadata,
+// which the host ID of the collector RPCs has no fields for.
 // bbuf	the BSON buffer to append the KVs to
 func appendHostId(bbuf *bsonBuffer) {
 	if host.ConfiguredHostname() != "" {
@@ -318,6 +323,60 @@ func appendHostId(bbuf *bsonBuffer) {
 	appendUname(bbuf)
 	bsonAppendString(bbuf, "Distro", host.Distro())
 	appendIPAddresses(bbuf)
+	id := host.BestEffortCurrentID()
+	appendK8sMetadata(bbuf, id.K8s())
+	appendCloudMetadata(bbuf, id.Cloud())
+}
+
+// appends the metadata of the Kubernetes pod, if any, to a BSON buffer
+// bbuf	the BSON buffer to append the KVs to
+func appendK8sMetadata(bbuf *bsonBuffer, k8s host.K8sMetadata) {
+	for _, kv := range []struct{ k, v string }{
+		{"K8sNamespace", k8s.Namespace},
+		{"K8sPodName", k8s.PodName},
+		{"K8sPodUID", k8s.PodUID},
+		{"K8sNodeName", k8s.NodeName},
+	} {
+		if kv.v != "" {
+			bsonAppendString(bbuf, kv.k, kv.v)
+		}
+	}
+}
+
+// appends the GCE or Azure instance metadata, if any, to a BSON buffer
+// bbuf	the BSON buffer to append the KVs to
+func appendCloudMetadata(bbuf *bsonBuffer, cloud host.CloudMetadata) {
+	for _, kv := range []struct{ k, v string }{
+		{"CloudProvider", cloud.Provider},
+		{"CloudInstanceID", cloud.InstanceID},
+		{"CloudZone", cloud.Zone},
+		{"CloudRegion", cloud.Region},
+		{"CloudMachineType", cloud.MachineType},
+	} {
+		if kv.v != "" {
+			bsonAppendString(bbuf, kv.k, kv.v)
+		}
+	}
+}
+
+// appends the user-defined host tags, if any, as a sub-document to a BSON buffer
+// bbuf	the BSON buffer to append the KVs to
+// tags	the host tags
+func appendHostTags(bbuf *bsonBuffer, tags map[string]string) {
+	if len(tags) == 0 {
+		return
+	}
+	bsonAppendStringMap(bbuf, hostTagsKey, tags)
+}
+
+// sortedKeys returns the keys of the map in increasing order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 // gets and appends IP addresses to a BSON buffer
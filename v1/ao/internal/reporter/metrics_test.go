@@ -69,6 +69,46 @@ func TestAppendIPAddresses(t *testing.T) {
 	}
 }
 
+func TestAppendK8sMetadata(t *testing.T) {
+	bbuf := NewBsonBuffer()
+	appendK8sMetadata(bbuf, host.K8sMetadata{Namespace: "prod", PodName: "web-1"})
+	bsonBufferFinish(bbuf)
+	m := bsonToMap(bbuf)
+
+	assert.Equal(t, "prod", m["K8sNamespace"])
+	assert.Equal(t, "web-1", m["K8sPodName"])
+	assert.NotContains(t, m, "K8sPodUID")
+	assert.NotContains(t, m, "K8sNodeName")
+}
+
+func TestAppendCloudMetadata(t *testing.T) {
+	bbuf := NewBsonBuffer()
+	appendCloudMetadata(bbuf, host.CloudMetadata{Provider: "gcp", InstanceID: "4520031799277581759",
+		Zone: "us-central1-a", Region: "us-central1"})
+	bsonBufferFinish(bbuf)
+	m := bsonToMap(bbuf)
+
+	assert.Equal(t, "gcp", m["CloudProvider"])
+	assert.Equal(t, "4520031799277581759", m["CloudInstanceID"])
+	assert.Equal(t, "us-central1-a", m["CloudZone"])
+	assert.Equal(t, "us-central1", m["CloudRegion"])
+	assert.NotContains(t, m, "CloudMachineType")
+}
+
+func TestAppendHostTags(t *testing.T) {
+	bbuf := NewBsonBuffer()
+	appendHostTags(bbuf, map[string]string{"env": "prod", "team": "payments"})
+	bsonBufferFinish(bbuf)
+	m := bsonToMap(bbuf)
+
+	assert.Equal(t, map[string]interface{}{"env": "prod", "team": "payments"}, m["HostTags"])
+
+	bbuf = NewBsonBuffer()
+	appendHostTags(bbuf, map[string]string{})
+	bsonBufferFinish(bbuf)
+	assert.NotContains(t, bsonToMap(bbuf), "HostTags")
+}
+
 func TestAppendMACAddresses(t *testing.T) {
 	bbuf := NewBsonBuffer()
 	appendMACAddresses(bbuf, host.CurrentID().MAC())
@@ -134,6 +134,8 @@ func sendInitMessage() {
 		_ = e.AddKV("Go.Version", utils.GoVersion())
 		_ = e.AddKV("Go.AppOptics.Version", utils.Version())
 
+		appendHostTags(&e.bbuf, config.GetHostTags())
+
 		_ = e.ReportStatus(c)
 	}
 }
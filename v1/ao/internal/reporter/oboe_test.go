@@ -38,6 +38,26 @@ func assertInitMessage(t *testing.T, bufs [][]byte) {
 	})
 }
 
+func TestInitMessageHostTags(t *testing.T) {
+	os.Setenv("APPOPTICS_HOST_TAGS", "env=prod,version=1.2.3")
+	config.Refresh()
+	defer func() {
+		os.Unsetenv("APPOPTICS_HOST_TAGS")
+		config.Refresh()
+	}()
+
+	r := SetTestReporter()
+	sendInitMessage()
+	r.Close(1)
+	g.AssertGraph(t, r.EventBufs, 1, g.AssertNodeMap{
+		{"go", "single"}: {Edges: g.Edges{}, Callback: func(n g.Node) {
+			assert.Equal(t, 1, n.Map["__Init"])
+			assert.Equal(t, bson.D{{Name: "env", Value: "prod"}, {Name: "version", Value: "1.2.3"}}, n.Map["HostTags"])
+			assert.NotContains(t, n.Map, "env")
+		}},
+	})
+}
+
 func TestInitMessageUDP(t *testing.T) {
 	assertUDPMode(t)
 
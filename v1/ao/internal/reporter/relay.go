@@ -0,0 +1,127 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package reporter
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"time"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/log"
+	"github.com/pkg/errors"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// the maximum size of a UDP datagram
+const relayMaxDatagramSize = 64 * 1024
+
+var (
+	errRelayRequiresGRPC  = errors.New("the relay requires the ssl reporter")
+	errInvalidRelayedMsg  = errors.New("invalid BSON message")
+	errUnknownRelayedType = errors.New("unknown message type")
+)
+
+// relayedSpan is a span message sent by the UDP reporter.
+type relayedSpan struct {
+	Transaction string `bson:"transaction"`
+	URL         string `bson:"url"`
+	Status      int    `bson:"status"`
+	Method      string `bson:"method"`
+	HasError    bool   `bson:"hasError"`
+	Duration    int64  `bson:"duration"`
+}
+
+// RelayUDP listens on the UDP address provided and relays the messages sent by
+// the UDP reporters of other processes to the collector through the gRPC
+// reporter, until the context is canceled or the reporter is closed. The events
+// and status messages are posted to the collector in batches, and the span
+// messages are aggregated into the metrics of the reporter.
+//
+// It returns an error if the gRPC reporter is not in use, e.g., it's disabled
+// or APPOPTICS_REPORTER is not ssl.
+func RelayUDP(ctx context.Context, addr string) error {
+	r, ok := globalReporter.(*grpcReporter)
+	if !ok {
+		return errRelayRequiresGRPC
+	}
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	log.Warningf("AppOptics relay is listening on %s.", conn.LocalAddr())
+	return r.relay(ctx, conn)
+}
+
+// relay reads the messages from the connection and relays them, until the
+// context is canceled or the reporter is closed. The connection is closed on
+// return.
+func (r *grpcReporter) relay(ctx context.Context, conn net.PacketConn) error {
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-r.done:
+		case <-stop:
+		}
+		conn.Close()
+	}()
+
+	buf := make([]byte, relayMaxDatagramSize)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			switch {
+			case ctx.Err() != nil:
+				return nil
+			case r.Closed():
+				return ErrReporterIsClosed
+			}
+			return err
+		}
+		msg := make([]byte, n)
+		copy(msg, buf[:n])
+		if err := r.relayMessage(msg); err != nil {
+			log.WithFields(log.Fields{
+				"component": "relay",
+				"from":      from.String(),
+				"error":     err,
+			}).Warning("Relayed message is dropped.")
+		}
+	}
+}
+
+// relayMessage puts the message on the channel of its type.
+func (r *grpcReporter) relayMessage(msg []byte) error {
+	if len(msg) < 5 || int(binary.LittleEndian.Uint32(msg)) != len(msg) || msg[len(msg)-1] != 0 {
+		return errInvalidRelayedMsg
+	}
+	m := bson.M{}
+	if err := bson.Unmarshal(msg, m); err != nil {
+		return errors.Wrap(errInvalidRelayedMsg, err.Error())
+	}
+
+	switch {
+	case m["__Init"] != nil:
+		return r.queueStatus(msg)
+	case m["X-Trace"] != nil:
+		return r.queueEvent(msg)
+	case m["transaction"] != nil:
+		var s relayedSpan
+		if err := bson.Unmarshal(msg, &s); err != nil {
+			return errors.Wrap(errInvalidRelayedMsg, err.Error())
+		}
+		return r.reportSpan(&HTTPSpanMessage{
+			BaseSpanMessage: BaseSpanMessage{
+				Duration: time.Duration(s.Duration),
+				HasError: s.HasError,
+			},
+			Transaction: s.Transaction,
+			Path:        s.URL,
+			Status:      s.Status,
+			Method:      s.Method,
+		})
+	}
+	return errUnknownRelayedType
+}
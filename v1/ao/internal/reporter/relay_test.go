@@ -0,0 +1,133 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package reporter
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/config"
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/reporter/collectortest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// testSpanMessage returns a span message encoded in the same way as the UDP
+// reporter.
+func testSpanMessage() []byte {
+	bbuf := NewBsonBuffer()
+	bsonAppendString(bbuf, "transaction", "my-transaction")
+	bsonAppendString(bbuf, "url", "/path/to")
+	bsonAppendInt(bbuf, "status", 503)
+	bsonAppendString(bbuf, "method", "POST")
+	bsonAppendBool(bbuf, "hasError", true)
+	bsonAppendInt64(bbuf, "duration", int64(time.Second))
+	bsonBufferFinish(bbuf)
+	return bbuf.buf
+}
+
+func TestRelayMessage(t *testing.T) {
+	r := &grpcReporter{
+		eventConnection: &grpcConnection{queueStats: &eventQueueStats{}},
+		eventMessages:   make(chan []byte, 1),
+		spanMessages:    make(chan SpanMessage, 1),
+		statusMessages:  make(chan []byte, 1),
+	}
+
+	event, err := bson.Marshal(bson.M{"X-Trace": "2B", "Layer": "relayed"})
+	require.NoError(t, err)
+	assert.NoError(t, r.relayMessage(event))
+	assert.Equal(t, event, <-r.eventMessages)
+	assert.EqualValues(t, 1, r.eventConnection.queueStats.totalEvents)
+
+	status, err := bson.Marshal(bson.M{"X-Trace": "2B", "__Init": 1})
+	require.NoError(t, err)
+	assert.NoError(t, r.relayMessage(status))
+	assert.Equal(t, status, <-r.statusMessages)
+	assert.NoError(t, r.relayMessage(status))
+	assert.Error(t, r.relayMessage(status), "the status message queue is full")
+
+	assert.NoError(t, r.relayMessage(testSpanMessage()))
+	assert.Equal(t, &HTTPSpanMessage{
+		BaseSpanMessage: BaseSpanMessage{Duration: time.Second, HasError: true},
+		Transaction:     "my-transaction",
+		Path:            "/path/to",
+		Status:          503,
+		Method:          "POST",
+	}, <-r.spanMessages)
+
+	unknown, err := bson.Marshal(bson.M{"hello": "world"})
+	require.NoError(t, err)
+	assert.Equal(t, errUnknownRelayedType, r.relayMessage(unknown))
+	assert.Equal(t, errInvalidRelayedMsg, r.relayMessage([]byte("hello world")))
+	assert.Equal(t, errInvalidRelayedMsg, r.relayMessage(event[:len(event)-1]))
+}
+
+func TestRelayUDP(t *testing.T) {
+	oldReporter := globalReporter
+	defer func() { globalReporter = oldReporter }()
+	globalReporter = &nullReporter{}
+	assert.Equal(t, errRelayRequiresGRPC, RelayUDP(context.Background(), "127.0.0.1:0"))
+
+	s, err := collectortest.NewServer("localhost:0")
+	require.NoError(t, err)
+	defer s.Stop()
+
+	certFile, err := ioutil.TempFile("", "collector-cert")
+	require.NoError(t, err)
+	defer os.Remove(certFile.Name())
+	_, err = certFile.Write(s.CertPEM)
+	require.NoError(t, err)
+	certFile.Close()
+
+	os.Setenv("APPOPTICS_COLLECTOR", s.Addr)
+	os.Setenv("APPOPTICS_TRUSTEDPATH", certFile.Name())
+	config.Refresh()
+	defer func() {
+		os.Unsetenv("APPOPTICS_COLLECTOR")
+		os.Unsetenv("APPOPTICS_TRUSTEDPATH")
+		config.Refresh()
+	}()
+	setGlobalReporter("ssl")
+	require.IsType(t, &grpcReporter{}, globalReporter)
+	r := globalReporter.(*grpcReporter)
+	defer r.ShutdownNow()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.relay(ctx, conn) }()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	tctx := newTestContext(t)
+	ev, err := tctx.newEvent(LabelInfo, "relayed")
+	require.NoError(t, err)
+	require.NoError(t, prepareEvent(tctx, ev))
+	_, err = client.Write([]byte("invalid"))
+	require.NoError(t, err)
+	_, err = client.Write(ev.bbuf.GetBuf())
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return len(s.Events()) >= 1 }, 5*time.Second, 10*time.Millisecond)
+	msgs, err := collectortest.Decode(s.Events())
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "relayed", msgs[0]["Layer"])
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("the relay is not stopped")
+	}
+}
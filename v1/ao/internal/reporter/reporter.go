@@ -107,7 +107,10 @@ func WaitForReady(ctx context.Context) bool {
 // Shutdown flushes the metrics and stops the reporter. It blocked until the reporter
 // is shutdown or the context is canceled.
 func Shutdown(ctx context.Context) error {
-	return globalReporter.Shutdown(ctx)
+	err := globalReporter.Shutdown(ctx)
+	// log the summaries of the suppressed log messages before the program exits
+	log.Flush()
+	return err
 }
 
 // Closed indicates if the reporter has been shutdown
@@ -143,8 +146,11 @@ func prepareEvent(ctx *oboeContext, e *event) error {
 		return errors.New("invalid event, same as context")
 	}
 
-	us := time.Now().UnixNano() / 1000
-	e.AddInt64("Timestamp_u", us)
+	ts := e.timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	e.AddInt64(TimestampKey, ts.UnixNano()/1000)
 
 	e.AddString("Hostname", host.Hostname())
 	e.AddInt("PID", host.PID())
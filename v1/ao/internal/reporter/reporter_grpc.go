@@ -3,18 +3,14 @@
 package reporter
 
 import (
-	"crypto/tls"
-	"crypto/x509"
 	"io/ioutil"
 	"math"
-	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/utils"
 	"github.com/pkg/errors"
-	"google.golang.org/grpc/credentials"
 
 	"context"
 
@@ -29,34 +25,11 @@ import (
 )
 
 const (
-	grpcReporterVersion = "golang-v2"
+	grpcReporterVersion = collector.ClientVersion
 
 	// default certificate used to verify the collector endpoint,
 	// can be overridden via APPOPTICS_TRUSTEDPATH
-	grpcCertDefault = `-----BEGIN CERTIFICATE-----
-MIID8TCCAtmgAwIBAgIJAMoDz7Npas2/MA0GCSqGSIb3DQEBCwUAMIGOMQswCQYD
-VQQGEwJVUzETMBEGA1UECAwKQ2FsaWZvcm5pYTEWMBQGA1UEBwwNU2FuIEZyYW5j
-aXNjbzEVMBMGA1UECgwMTGlicmF0byBJbmMuMRUwEwYDVQQDDAxBcHBPcHRpY3Mg
-Q0ExJDAiBgkqhkiG9w0BCQEWFXN1cHBvcnRAYXBwb3B0aWNzLmNvbTAeFw0xNzA5
-MTUyMjAxMzlaFw0yNzA5MTMyMjAxMzlaMIGOMQswCQYDVQQGEwJVUzETMBEGA1UE
-CAwKQ2FsaWZvcm5pYTEWMBQGA1UEBwwNU2FuIEZyYW5jaXNjbzEVMBMGA1UECgwM
-TGlicmF0byBJbmMuMRUwEwYDVQQDDAxBcHBPcHRpY3MgQ0ExJDAiBgkqhkiG9w0B
-CQEWFXN1cHBvcnRAYXBwb3B0aWNzLmNvbTCCASIwDQYJKoZIhvcNAQEBBQADggEP
-ADCCAQoCggEBAOxO0wsGba3iI4r3L5BMST0rAO/gGaUhpQre6nRwVTmPCnLw1bmn
-GdiFgYv/oRRwU+VieumHSQqoOmyFrg+ajGmvUDp2WqQ0It+XhcbaHFiAp2H7+mLf
-cUH6S43/em0WUxZHeRzRupRDyO1bX6Hh2jgxykivlFrn5HCIQD5Hx1/SaZoW9v2n
-oATCbgFOiPW6kU/AVs4R0VBujon13HCehVelNKkazrAEBT1i6RvdOB6aQQ32seW+
-gLV5yVWSPEJvA9ZJqad/nQ8EQUMSSlVN191WOjp4bGpkJE1svs7NmM+Oja50W56l
-qOH5eWermr/8qWjdPlDJ+I0VkgN0UyHVuRECAwEAAaNQME4wHQYDVR0OBBYEFOuL
-KDTFhRQXwlBRxhPqhukrNYeRMB8GA1UdIwQYMBaAFOuLKDTFhRQXwlBRxhPqhukr
-NYeRMAwGA1UdEwQFMAMBAf8wDQYJKoZIhvcNAQELBQADggEBAJQtH446NZhjusy6
-iCyvmnD95ybfNPDpjHmNx5n9Y6w9n+9y1o3732HUJE+WjvbLS3h1o7wujGKMcRJn
-7I7eTDd26ZhLvnh5/AitYjdxrtUkQDgyxwLFJKhZu0ik2vXqj0fL961/quJL8Gyp
-hNj3Nf7WMohQMSohEmCCX2sHyZGVGYmQHs5omAtkH/NNySqmsWNcpgd3M0aPDRBZ
-5VFreOSGKBTJnoLNqods/S9RV0by84hm3j6aQ/tMDIVE9VCJtrE6evzC0MWyVFwR
-ftgwcxyEq5SkiR+6BCwdzAMqADV37TzXDHLjwSrMIrgLV5xZM20Kk6chxI5QAr/f
-7tsqAxw=
------END CERTIFICATE-----`
+	grpcCertDefault = collector.DefaultCertificate
 
 	// These are hard-coded parameters for the gRPC reporter. Any of them become
 	// configurable in future versions will be moved to package config.
@@ -107,6 +80,10 @@ type grpcConnection struct {
 	// This channel is closed after flushing the metrics.
 	flushed     chan struct{}
 	flushedOnce sync.Once
+
+	// the time of the last successful call of each RPC method
+	lastSuccess     map[string]time.Time
+	lastSuccessLock sync.Mutex
 }
 
 // GrpcConnOpt defines the function type that sets an option of the grpcConnection
@@ -465,7 +442,7 @@ func (c *grpcConnection) connect() error {
 	// Skip it if the connection is not stale - someone else may have done
 	// the connection.
 	if c.isActive() {
-		log.Debugf("[%s] Someone else has done the redirection.", c.name)
+		c.logger().Debug("Someone else has done the redirection.")
 		return nil
 	}
 	// create a new connection object for this client
@@ -483,10 +460,22 @@ func (c *grpcConnection) connect() error {
 	c.client = collector.NewTraceCollectorClient(c.connection)
 	c.setActive(true)
 
-	log.Infof("[%s] Connected to %s", c.name, c.address)
+	c.logger().Infof("Connected to %s", c.address)
 	return nil
 }
 
+// logger returns a log message builder with the component, the connection
+// name and the extra fields attached.
+func (c *grpcConnection) logger(fields ...log.Fields) log.Entry {
+	f := log.Fields{"component": "reporter", "connection": c.name}
+	for _, fs := range fields {
+		for k, v := range fs {
+			f[k] = v
+		}
+	}
+	return log.WithFields(f)
+}
+
 func (c *grpcConnection) isActive() bool {
 	return atomic.LoadInt32(&c.atomicActive) == 1
 }
@@ -634,8 +623,14 @@ func (r *grpcReporter) reportEvent(ctx *oboeContext, e *event) error {
 		return err
 	}
 
+	return r.queueEvent((*e).bbuf.GetBuf())
+}
+
+// queueEvent puts the BSON encoded event on the events message channel, unless
+// the channel is full.
+func (r *grpcReporter) queueEvent(buf []byte) error {
 	select {
-	case r.eventMessages <- (*e).bbuf.GetBuf():
+	case r.eventMessages <- buf:
 		atomic.AddInt64(&r.eventConnection.queueStats.totalEvents, int64(1))
 		return nil
 	default:
@@ -880,8 +875,14 @@ func (r *grpcReporter) reportStatus(ctx *oboeContext, e *event) error {
 		return err
 	}
 
+	return r.queueStatus((*e).bbuf.GetBuf())
+}
+
+// queueStatus puts the BSON encoded status message on the status message
+// channel, unless the channel is full.
+func (r *grpcReporter) queueStatus(buf []byte) error {
 	select {
-	case r.statusMessages <- (*e).bbuf.GetBuf():
+	case r.statusMessages <- buf:
 		return nil
 	default:
 		return errors.New("status message queue is full")
@@ -1056,7 +1057,7 @@ func (c *grpcConnection) InvokeRPC(exit chan struct{}, m Method) error {
 			code := status.Code(err)
 			if code == codes.DeadlineExceeded ||
 				code == codes.Canceled {
-				log.Infof("[%s] Connection becomes stale: %v.", c.name, err)
+				c.logger(log.Fields{"error": err}).Info("Connection becomes stale.")
 				err = errConnStale
 				c.setActive(false)
 			}
@@ -1071,14 +1072,15 @@ func (c *grpcConnection) InvokeRPC(exit chan struct{}, m Method) error {
 		if err != nil {
 			// gRPC handles the reconnection automatically.
 			failsNum++
+			l := c.logger(log.Fields{"method": m.String(), "error": err, "failures": failsNum})
 			if failsNum == grpcRetryLogThreshold {
-				log.Warningf("[%s] invocation error: %v.", m, err)
+				l.Warning("RPC invocation error.")
 			} else {
-				log.Debugf("[%s] (%v) invocation error: %v.", m, failsNum, err)
+				l.Debug("RPC invocation error.")
 			}
 		} else {
 			if failsNum >= grpcRetryLogThreshold {
-				log.Warningf("[%s] error recovered.", m)
+				c.logger(log.Fields{"method": m.String()}).Warning("RPC invocation error recovered.")
 			}
 			failsNum = 0
 
@@ -1086,6 +1088,7 @@ func (c *grpcConnection) InvokeRPC(exit chan struct{}, m Method) error {
 			switch result, _ := m.ResultCode(); result {
 			case collector.ResultCode_OK:
 				atomic.AddInt64(&c.queueStats.numSent, m.MessageLen())
+				c.setLastSuccess(m.String(), time.Now())
 				return nil
 
 			case collector.ResultCode_TRY_LATER:
@@ -1196,30 +1199,7 @@ type DefaultDialer struct{}
 // Dial issues the connection to the remote address with attributes provided by
 // the grpcConnection.
 func (d *DefaultDialer) Dial(c grpcConnection) (*grpc.ClientConn, error) {
-	certPool := x509.NewCertPool()
-
-	if ok := certPool.AppendCertsFromPEM(c.certificate); !ok {
-		return nil, errors.New("unable to append the certificate to pool")
-	}
-
-	// trim port from server name used for TLS verification
-	serverName := c.address
-	if s := strings.Split(c.address, ":"); len(s) > 0 {
-		serverName = s[0]
-	}
-
-	tlsConfig := &tls.Config{
-		ServerName:         serverName,
-		RootCAs:            certPool,
-		InsecureSkipVerify: c.insecureSkipVerify,
-	}
-	// turn off server certificate verification for Go < 1.8
-	if !utils.IsHigherOrEqualGoVersion("go1.8") {
-		tlsConfig.InsecureSkipVerify = true
-	}
-	creds := credentials.NewTLS(tlsConfig)
-
-	return grpc.Dial(c.address, grpc.WithTransportCredentials(creds))
+	return collector.Dial(c.address, c.certificate, c.insecureSkipVerify)
 }
 
 func printRPCMsg(m Method) {
@@ -5,14 +5,18 @@ package reporter
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"os"
 	"path"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/config"
 	pb "github.com/appoptics/appoptics-apm-go/v1/ao/internal/reporter/collector"
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/reporter/collectortest"
 	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -133,3 +137,97 @@ func (s *TestGRPCServer) Ping(ctx context.Context, req *pb.PingRequest) (*pb.Mes
 	s.pings++
 	return &pb.MessageResult{Result: pb.ResultCode_OK}, nil
 }
+
+func newFakeCollectorConn(t *testing.T, s *collectortest.Server) *grpcConnection {
+	c, err := newGrpcConnection("events channel", s.Addr, WithCert(s.CertPEM),
+		WithBackoff(func(retries int, wait func(d time.Duration)) error {
+			if retries > grpcMaxRetries {
+				return errGiveUpAfterRetries
+			}
+			return nil
+		}))
+	require.NoError(t, err)
+	return c
+}
+
+func TestInvokeRPCFakeCollector(t *testing.T) {
+	s, err := collectortest.NewServer("localhost:0")
+	require.NoError(t, err)
+	defer s.Stop()
+	redirected, err := collectortest.NewServer("localhost:0")
+	require.NoError(t, err)
+	defer redirected.Stop()
+
+	c := newFakeCollectorConn(t, s)
+	defer c.Close()
+	exit := make(chan struct{})
+
+	// retried after TRY_LATER and LIMIT_EXCEEDED
+	s.Respond(collectortest.PostEvents,
+		collectortest.Response{Result: pb.ResultCode_TRY_LATER},
+		collectortest.Response{Result: pb.ResultCode_LIMIT_EXCEEDED})
+	assert.NoError(t, c.InvokeRPC(exit, newPostEventsMethod(serviceKey, [][]byte{[]byte("hello")})))
+	assert.Len(t, s.Events(), 3)
+
+	// redirected to another collector
+	s.Respond(collectortest.PostEvents, collectortest.Response{Result: pb.ResultCode_REDIRECT, Arg: redirected.Addr})
+	assert.NoError(t, c.InvokeRPC(exit, newPostEventsMethod(serviceKey, [][]byte{[]byte("hello")})))
+	assert.Len(t, s.Events(), 4)
+	assert.Len(t, redirected.Events(), 1)
+	assert.Equal(t, redirected.Addr, c.address)
+
+	// not retried with an invalid key
+	redirected.Respond(collectortest.PostStatus, collectortest.Response{Result: pb.ResultCode_INVALID_API_KEY})
+	assert.Equal(t, errInvalidServiceKey, c.InvokeRPC(exit, newPostStatusMethod(serviceKey, [][]byte{[]byte("hello")})))
+	assert.Len(t, redirected.Status(), 1)
+}
+
+func TestGRPCReporterFakeCollector(t *testing.T) {
+	s, err := collectortest.NewServer("localhost:0")
+	require.NoError(t, err)
+	defer s.Stop()
+	s.Respond(collectortest.PostEvents, collectortest.Response{Result: pb.ResultCode_TRY_LATER})
+
+	certFile, err := ioutil.TempFile("", "collector-cert")
+	require.NoError(t, err)
+	defer os.Remove(certFile.Name())
+	_, err = certFile.Write(s.CertPEM)
+	require.NoError(t, err)
+	certFile.Close()
+
+	os.Setenv("APPOPTICS_COLLECTOR", s.Addr)
+	os.Setenv("APPOPTICS_TRUSTEDPATH", certFile.Name())
+	config.Refresh()
+	defer func() {
+		os.Unsetenv("APPOPTICS_COLLECTOR")
+		os.Unsetenv("APPOPTICS_TRUSTEDPATH")
+		config.Refresh()
+	}()
+	oldReporter := globalReporter
+	setGlobalReporter("ssl")
+	require.IsType(t, &grpcReporter{}, globalReporter)
+	r := globalReporter.(*grpcReporter)
+	defer func() {
+		r.ShutdownNow()
+		globalReporter = oldReporter
+	}()
+
+	// periodic tasks are disabled in tests
+	r.getSettings(make(chan bool, 1))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.True(t, r.WaitForReady(ctx))
+	assert.NotEmpty(t, s.SettingsRequests())
+
+	tctx := newTestContext(t)
+	ev, err := tctx.newEvent(LabelInfo, "fake-collector")
+	require.NoError(t, err)
+	assert.NoError(t, r.reportEvent(tctx, ev))
+
+	// the event is posted again after TRY_LATER
+	require.Eventually(t, func() bool { return len(s.Events()) >= 2 }, 5*time.Second, 10*time.Millisecond)
+	msgs, err := collectortest.Decode(s.Events()[1:])
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "fake-collector", msgs[0]["Layer"])
+}
@@ -119,13 +119,14 @@ func TestTestReporter(t *testing.T) {
 	r.Close(1) // wait on late event -- blocks until timeout or event received
 	assert.Len(t, r.EventBufs, 1)
 
-	// send an event after calling Close -- should panic
-	assert.Panics(t, func() {
+	// send an event after calling Close -- it's dropped
+	assert.NotPanics(t, func() {
 		ctx := newTestContext(t)
 		ev, err := ctx.newEvent(LabelExit, testLayer)
 		assert.NoError(t, err)
 		assert.NoError(t, r.reportEvent(ctx, ev))
 	})
+	assert.Len(t, r.EventBufs, 1)
 }
 
 // ========================= NULL Reporter =============================
@@ -0,0 +1,197 @@
+// Copyright (C) 2017 Librato, Inc. All rights reserved.
+
+package reporter
+
+import (
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Status is a snapshot of the reporter internals for self-diagnostics.
+type Status struct {
+	// The reporter type: ssl, udp, none or test
+	Type string `json:"type"`
+	// Whether the reporter has got a valid default sampling setting
+	Ready bool `json:"ready"`
+	// Whether the reporter has been closed
+	Closed bool `json:"closed"`
+	// The connections to the collector, only for the ssl reporter
+	Connections []ConnectionStatus `json:"connections,omitempty"`
+	// The queues of the messages waiting to be sent, only for the ssl reporter
+	Queues []QueueStatus `json:"queues,omitempty"`
+	// The events counters since the last metrics message, only for the ssl reporter
+	Events *EventsStatus `json:"events,omitempty"`
+	// The current default sampling setting, nil if there is none
+	Sampling *SamplingStatus `json:"sampling"`
+}
+
+// ConnectionStatus is the status of a gRPC connection to the collector.
+type ConnectionStatus struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Active  bool   `json:"active"`
+	// The time of the last successful call of each RPC method
+	LastSuccess map[string]time.Time `json:"lastSuccess"`
+}
+
+// QueueStatus is the depth and capacity of a message queue.
+type QueueStatus struct {
+	Name     string `json:"name"`
+	Depth    int    `json:"depth"`
+	Capacity int    `json:"capacity"`
+}
+
+// EventsStatus is the events counters, which are reset after each metrics
+// message is sent.
+type EventsStatus struct {
+	Sent       int64 `json:"sent"`
+	Failed     int64 `json:"failed"`
+	Overflowed int64 `json:"overflowed"`
+	Total      int64 `json:"total"`
+	Largest    int64 `json:"largest"`
+}
+
+// SamplingStatus is the current default sampling setting.
+type SamplingStatus struct {
+	// The tracing mode defined by APPOPTICS_TRACING_MODE: always or never
+	TracingMode string `json:"tracingMode"`
+	// The sample rate, out of 1000000
+	Rate  int    `json:"rate"`
+	Flags string `json:"flags"`
+	// The token bucket which limits the traces per second
+	BucketRate      float64 `json:"bucketRate"`
+	BucketCapacity  float64 `json:"bucketCapacity"`
+	BucketAvailable float64 `json:"bucketAvailable"`
+	// The remaining time to live of the setting in seconds
+	TTLRemaining int64 `json:"ttlRemaining"`
+}
+
+// GetStatus returns the status of the current reporter.
+func GetStatus() Status {
+	st := Status{
+		Ready:    hasDefaultSetting(),
+		Closed:   globalReporter.Closed(),
+		Sampling: samplingStatus(),
+	}
+
+	switch r := globalReporter.(type) {
+	case *grpcReporter:
+		st.Type = "ssl"
+		st.Ready = r.isReady()
+		st.Connections = []ConnectionStatus{
+			r.eventConnection.status(),
+			r.metricConnection.status(),
+		}
+		st.Queues = []QueueStatus{
+			{"events", len(r.eventMessages), cap(r.eventMessages)},
+			{"spans", len(r.spanMessages), cap(r.spanMessages)},
+			{"status", len(r.statusMessages), cap(r.statusMessages)},
+		}
+		st.Events = r.eventConnection.queueStats.status()
+	case *udpReporter:
+		st.Type = "udp"
+	case *TestReporter:
+		st.Type = "test"
+	default:
+		st.Type = "none"
+	}
+	return st
+}
+
+// status returns the status of the gRPC connection.
+func (c *grpcConnection) status() ConnectionStatus {
+	c.lock.RLock()
+	address := c.address
+	c.lock.RUnlock()
+
+	c.lastSuccessLock.Lock()
+	last := make(map[string]time.Time, len(c.lastSuccess))
+	for m, t := range c.lastSuccess {
+		last[m] = t
+	}
+	c.lastSuccessLock.Unlock()
+
+	return ConnectionStatus{
+		Name:        c.name,
+		Address:     address,
+		Active:      c.isActive(),
+		LastSuccess: last,
+	}
+}
+
+// setLastSuccess records the time of the last successful call of the method.
+func (c *grpcConnection) setLastSuccess(method string, t time.Time) {
+	c.lastSuccessLock.Lock()
+	defer c.lastSuccessLock.Unlock()
+	if c.lastSuccess == nil {
+		c.lastSuccess = make(map[string]time.Time)
+	}
+	c.lastSuccess[method] = t
+}
+
+// status returns the current values of the counters without resetting them.
+func (s *eventQueueStats) status() *EventsStatus {
+	return &EventsStatus{
+		Sent:       atomic.LoadInt64(&s.numSent),
+		Failed:     atomic.LoadInt64(&s.numFailed),
+		Overflowed: atomic.LoadInt64(&s.numOverflowed),
+		Total:      atomic.LoadInt64(&s.totalEvents),
+		Largest:    atomic.LoadInt64(&s.queueLargest),
+	}
+}
+
+// samplingStatus returns the status of the default sampling setting, or nil if
+// there is none.
+func samplingStatus() *SamplingStatus {
+	setting, ok := getSetting("")
+	if !ok {
+		return nil
+	}
+
+	globalSettingsCfg.lock.RLock()
+	st := &SamplingStatus{
+		TracingMode: tracingModeString(globalSettingsCfg.tracingMode),
+		Rate:        setting.value,
+		Flags:       setting.flags.String(),
+		TTLRemaining: int64(time.Until(setting.timestamp.Add(
+			time.Duration(setting.ttl)*time.Second)) / time.Second),
+	}
+	globalSettingsCfg.lock.RUnlock()
+
+	b := setting.bucket
+	b.lock.Lock()
+	st.BucketRate = b.ratePerSec
+	st.BucketCapacity = b.capacity
+	st.BucketAvailable = b.available
+	b.lock.Unlock()
+
+	return st
+}
+
+func tracingModeString(m tracingMode) string {
+	if m == TRACE_NEVER {
+		return "never"
+	}
+	return "always"
+}
+
+// String returns the names of the flags separated by commas, in the same
+// format as the flags of the collector's settings.
+func (f settingFlag) String() string {
+	names := map[settingFlag]string{
+		FLAG_OVERRIDE:              "OVERRIDE",
+		FLAG_SAMPLE_START:          "SAMPLE_START",
+		FLAG_SAMPLE_THROUGH:        "SAMPLE_THROUGH",
+		FLAG_SAMPLE_THROUGH_ALWAYS: "SAMPLE_THROUGH_ALWAYS",
+	}
+	var s []string
+	for flag, name := range names {
+		if f&flag != 0 {
+			s = append(s, name)
+		}
+	}
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
@@ -0,0 +1,58 @@
+// Copyright (C) 2017 Librato, Inc. All rights reserved.
+
+package reporter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/reporter/collectortest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionStatus(t *testing.T) {
+	s, err := collectortest.NewServer("localhost:0")
+	require.NoError(t, err)
+	defer s.Stop()
+
+	c := newFakeCollectorConn(t, s)
+	defer c.Close()
+
+	st := c.status()
+	assert.Equal(t, "events channel", st.Name)
+	assert.Equal(t, s.Addr, st.Address)
+	assert.True(t, st.Active)
+	assert.Empty(t, st.LastSuccess)
+
+	before := time.Now()
+	require.NoError(t, c.InvokeRPC(make(chan struct{}), newPostEventsMethod(serviceKey, [][]byte{[]byte("hello")})))
+	st = c.status()
+	require.Contains(t, st.LastSuccess, "PostEvents")
+	assert.False(t, st.LastSuccess["PostEvents"].Before(before))
+}
+
+func TestReporterStatus(t *testing.T) {
+	r := SetTestReporter()
+	defer r.Close(0)
+
+	st := GetStatus()
+	assert.Equal(t, "test", st.Type)
+	assert.True(t, st.Ready)
+	assert.False(t, st.Closed)
+	assert.Nil(t, st.Connections)
+	require.NotNil(t, st.Sampling)
+	assert.Equal(t, "always", st.Sampling.TracingMode)
+	assert.Equal(t, 1000000, st.Sampling.Rate)
+	assert.Equal(t, "SAMPLE_START,SAMPLE_THROUGH_ALWAYS", st.Sampling.Flags)
+	assert.Equal(t, float64(1000000), st.Sampling.BucketCapacity)
+	assert.InDelta(t, 120, st.Sampling.TTLRemaining, 1)
+
+	resetSettings()
+	assert.Nil(t, GetStatus().Sampling)
+}
+
+func TestSettingFlagString(t *testing.T) {
+	assert.Equal(t, "", settingFlag(0).String())
+	assert.Equal(t, "OVERRIDE,SAMPLE_THROUGH", (FLAG_OVERRIDE | FLAG_SAMPLE_THROUGH).String())
+}
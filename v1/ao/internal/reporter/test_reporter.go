@@ -22,7 +22,9 @@ type TestReporter struct {
 	CaptureMetrics        bool
 	ErrorEvents           map[int]bool // whether to drop an event
 	eventCount            int64
+	eventsOnly            bool // whether Close waits for the events only
 	done                  chan int
+	closed                chan struct{}
 	wg                    sync.WaitGroup
 	eventChan             chan []byte
 	spanMsgChan           chan SpanMessage
@@ -75,7 +77,8 @@ func SetTestReporter(options ...TestReporterOption) *TestReporter {
 		ShouldTrace: true,
 		UseSettings: true,
 		Timeout:     defaultTestReporterTimeout,
-		done:        make(chan int),
+		done:        make(chan int, 1),
+		closed:      make(chan struct{}),
 		eventChan:   make(chan []byte),
 		spanMsgChan: make(chan SpanMessage),
 	}
@@ -102,12 +105,21 @@ func SetTestReporter(options ...TestReporterOption) *TestReporter {
 	return r
 }
 
+// received returns the number of events and span messages received, or only
+// the number of events if eventsOnly is set.
+func (r *TestReporter) received() int {
+	if r.eventsOnly {
+		return len(r.EventBufs)
+	}
+	return len(r.EventBufs) + len(r.SpanMessages)
+}
+
 func (r *TestReporter) resultWriter() {
 	var numBufs int
 	for {
 		select {
 		case numBufs = <-r.done:
-			if len(r.EventBufs)+len(r.SpanMessages) >= numBufs {
+			if r.received() >= numBufs {
 				r.wg.Done()
 				return
 			}
@@ -117,13 +129,13 @@ func (r *TestReporter) resultWriter() {
 			return
 		case buf := <-r.eventChan:
 			r.EventBufs = append(r.EventBufs, buf)
-			if r.done == nil && len(r.EventBufs)+len(r.SpanMessages) >= numBufs {
+			if r.done == nil && r.received() >= numBufs {
 				r.wg.Done()
 				return
 			}
 		case buf := <-r.spanMsgChan:
 			r.SpanMessages = append(r.SpanMessages, buf)
-			if r.done == nil && len(r.EventBufs)+len(r.SpanMessages) >= numBufs {
+			if r.done == nil && r.received() >= numBufs {
 				r.wg.Done()
 				return
 			}
@@ -131,14 +143,26 @@ func (r *TestReporter) resultWriter() {
 	}
 }
 
-// Close stops the test reporter from listening for events; r.EventBufs will no longer be updated and any
-// calls to WritePacket() will panic.
+// Close stops the test reporter from listening for events once numBufs events and span messages
+// have been received; r.EventBufs will no longer be updated and the events reported afterwards are
+// dropped.
 func (r *TestReporter) Close(numBufs int) {
+	r.close(numBufs)
+}
+
+// CloseEvents is like Close, but only counts the events and not the span messages, e.g. when the
+// span message of a trace may be reported before the exit events of its async spans.
+func (r *TestReporter) CloseEvents(numEvents int) {
+	r.eventsOnly = true
+	r.close(numEvents)
+}
+
+func (r *TestReporter) close(numBufs int) {
 	r.done <- numBufs
 	// wait for reader goroutine to receive numBufs events, or timeout.
 	r.wg.Wait()
-	close(r.eventChan)
-	received := len(r.EventBufs) + len(r.SpanMessages)
+	close(r.closed)
+	received := r.received()
 	if received < numBufs {
 		log.Printf("# FIX: TestReporter.Close() waited for %d events, got %d", numBufs, received)
 	}
@@ -180,7 +204,10 @@ func (r *TestReporter) report(ctx *oboeContext, e *event) error {
 		(r.ErrorEvents != nil && r.ErrorEvents[(int(r.eventCount)-1)]) { // error certain specified events
 		return errors.New("TestReporter error")
 	}
-	r.eventChan <- (*e).bbuf.GetBuf() // a send to a closed channel panics.
+	select {
+	case r.eventChan <- (*e).bbuf.GetBuf():
+	case <-r.closed: // dropped after Close
+	}
 	return nil
 }
 
@@ -193,7 +220,10 @@ func (r *TestReporter) reportStatus(ctx *oboeContext, e *event) error {
 }
 
 func (r *TestReporter) reportSpan(span SpanMessage) error {
-	r.spanMsgChan <- span
+	select {
+	case r.spanMsgChan <- span:
+	case <-r.closed: // dropped after Close
+	}
 	return nil
 }
 
@@ -9,6 +9,7 @@ import (
 	"runtime"
 	"runtime/debug"
 	"sync"
+	"time"
 
 	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/reporter"
 )
@@ -23,11 +24,16 @@ const (
 const (
 	// KeyBackTrace is the key to report current stack trace.
 	KeyBackTrace = "Backtrace"
+	// KeyTimestamp is the key to report the time of an event explicitly, with
+	// a time.Time value, instead of the time the event is reported.
+	KeyTimestamp = reporter.TimestampKey
 )
 
 // Keys for internal use
 const (
 	keyEdge            = "Edge"
+	keyLink            = "Link"
+	keyLinkType        = "LinkType"
 	keySpec            = "Spec"
 	keyErrorClass      = "ErrorClass"
 	keyErrorMsg        = "ErrorMsg"
@@ -118,6 +124,24 @@ type Profile interface {
 	Err(error)
 }
 
+// SpanWithEndAt is implemented by the spans, traces and profiles of this package,
+// which can end at an explicit time, e.g. to report a span from recorded timings.
+// Adding the method to Span and Profile would break their other implementations.
+type SpanWithEndAt interface {
+	// EndAt ends the span at the time provided instead of the current time,
+	// optionally reporting KV pairs provided by args.
+	EndAt(end time.Time, args ...interface{})
+}
+
+// SpanWithErrorKVs is implemented by the spans and traces of this package, which
+// can report an error along with extra KVs. It's not part of the Span interface,
+// so that its implementations outside of this package keep compiling.
+type SpanWithErrorKVs interface {
+	// ErrorWithKVs reports details about an error for this Span, along with the
+	// KV pairs provided by args. The stack trace is not reported.
+	ErrorWithKVs(class, msg string, args ...interface{})
+}
+
 // SpanOptions defines the options of creating a span
 type SpanOptions struct {
 	// WithBackTrace indicates whether to include the backtrace in BeginSpan
@@ -125,8 +149,20 @@ type SpanOptions struct {
 	// `debug.Stack()` internally to gather the stack trace. Please consider
 	// the impact on performance/memory footprint carefully.
 	WithBackTrace bool
+
+	// StartTime is the time the span started, if not the time it's created,
+	// e.g. to report a span from recorded timings.
+	StartTime time.Time
+
+	// Links are the metadata strings of other spans this span follows from,
+	// e.g. the producer of a queued message or the requests of a batch. Each
+	// link is reported as an extra edge, marked with a FollowsFrom link type.
+	Links []string
 }
 
+// linkTypeFollowsFrom marks the edges added for the Links of a span.
+const linkTypeFollowsFrom = "FollowsFrom"
+
 // SpanOpt defines the function type that changes the SpanOptions
 type SpanOpt func(*SpanOptions)
 
@@ -137,6 +173,20 @@ func WithBackTrace() SpanOpt {
 	}
 }
 
+// WithStartTime returns a function that sets the start time of the span
+func WithStartTime(start time.Time) SpanOpt {
+	return func(o *SpanOptions) {
+		o.StartTime = start
+	}
+}
+
+// WithLinks returns a function that adds links to other spans' metadata
+func WithLinks(links ...string) SpanOpt {
+	return func(o *SpanOptions) {
+		o.Links = append(o.Links, links...)
+	}
+}
+
 // BeginSpan starts a new Span, provided a parent context and name. It returns a Span
 // and context bound to the new child Span.
 func BeginSpan(ctx context.Context, spanName string, args ...interface{}) (Span, context.Context) {
@@ -147,11 +197,33 @@ func BeginSpan(ctx context.Context, spanName string, args ...interface{}) (Span,
 func addKVsFromOpts(opts SpanOptions, args ...interface{}) []interface{} {
 	kvs := args
 	if opts.WithBackTrace {
-		kvs = mergeKVs(args, []interface{}{KeyBackTrace, string(debug.Stack())})
+		kvs = mergeKVs(kvs, []interface{}{KeyBackTrace, string(debug.Stack())})
+	}
+	if !opts.StartTime.IsZero() {
+		kvs = mergeKVs(kvs, []interface{}{KeyTimestamp, opts.StartTime})
+	}
+	if links := linkKVs(opts.Links); links != nil {
+		kvs = mergeKVs(kvs, links)
 	}
 	return kvs
 }
 
+// linkKVs returns the KVs to report the links provided, or nil if there are
+// none. Edges are only added for links within the same trace, so the full
+// metadata of each link is reported as well.
+func linkKVs(links []string) []interface{} {
+	var mds []string
+	for _, md := range links {
+		if md != "" {
+			mds = append(mds, md)
+		}
+	}
+	if len(mds) == 0 {
+		return nil
+	}
+	return []interface{}{keyEdge, mds, keyLink, mds, keyLinkType, linkTypeFollowsFrom}
+}
+
 // mergeKVs merges two slices into a single one. An empty slice instead of
 // nil will be returned if both of the arguments are nil.
 func mergeKVs(left []interface{}, right []interface{}) []interface{} {
@@ -226,11 +298,21 @@ func (s *layerSpan) BeginProfile(profileName string, args ...interface{}) Profil
 // End a profiled block or method.
 func (s *span) End(args ...interface{}) {
 	if s.ok() {
+		// the child profiles end at the same time as the span. They are ended
+		// before locking the span as they report their exit to the span.
+		s.lock.RLock()
+		profiles := s.childProfiles
+		s.lock.RUnlock()
+		end := timestampFromKVs(args)
+		for _, prof := range profiles {
+			if p, ok := prof.(SpanWithEndAt); ok && !end.IsZero() {
+				p.EndAt(end)
+			} else {
+				prof.End()
+			}
+		}
 		s.lock.Lock()
 		defer s.lock.Unlock()
-		for _, prof := range s.childProfiles {
-			prof.End()
-		}
 		args = append(args, s.endArgs...)
 		for _, edge := range s.childEdges { // add Edge KV for each joined child
 			args = append(args, keyEdge, edge)
@@ -246,6 +328,11 @@ func (s *span) End(args ...interface{}) {
 	}
 }
 
+// EndAt ends a profiled block or method at the time provided.
+func (s *span) EndAt(end time.Time, args ...interface{}) {
+	s.End(append(args, KeyTimestamp, end)...)
+}
+
 // AddEndArgs adds KV pairs as variadic args that will be serialized (and dereferenced,
 // for pointer values) at the end of this trace's span.
 func (s *layerSpan) AddEndArgs(args ...interface{}) {
@@ -327,11 +414,16 @@ func (s *span) GetTransactionName() string {
 // Error reports an error, distinguished by its class and message
 func (s *span) Error(class, msg string) {
 	if s.ok() {
-		s.aoCtx.ReportEvent(reporter.LabelError, s.layerName(),
-			keySpec, "error",
-			keyErrorClass, class,
-			keyErrorMsg, msg,
-			KeyBackTrace, string(debug.Stack()))
+		s.ErrorWithKVs(class, msg, KeyBackTrace, string(debug.Stack()))
+	}
+}
+
+// ErrorWithKVs reports an error, distinguished by its class and message, with
+// the additional KV pairs provided by args.
+func (s *span) ErrorWithKVs(class, msg string, args ...interface{}) {
+	if s.ok() {
+		args = append([]interface{}{keySpec, "error", keyErrorClass, class, keyErrorMsg, msg}, args...)
+		s.aoCtx.ReportEvent(reporter.LabelError, s.layerName(), args...)
 	}
 }
 
@@ -365,9 +457,11 @@ func (s nullSpan) BeginSpanWithOptions(spanName string, opts SpanOptions, args .
 }
 func (s nullSpan) BeginProfile(name string, args ...interface{}) Profile { return nullSpan{} }
 func (s nullSpan) End(args ...interface{})                               {}
+func (s nullSpan) EndAt(end time.Time, args ...interface{})              {}
 func (s nullSpan) AddEndArgs(args ...interface{})                        {}
 func (s nullSpan) Error(class, msg string)                               {}
 func (s nullSpan) Err(err error)                                         {}
+func (s nullSpan) ErrorWithKVs(class, msg string, args ...interface{})   {}
 func (s nullSpan) Info(args ...interface{})                              {}
 func (s nullSpan) InfoWithOptions(opts SpanOptions, args ...interface{}) {}
 func (s nullSpan) IsReporting() bool                                     { return false }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/reporter"
 	"github.com/stretchr/testify/assert"
@@ -148,6 +149,26 @@ func TestAddKVsFromOpts(t *testing.T) {
 
 	kvs = addKVsFromOpts(SpanOptions{WithBackTrace: true}, "hello", 1)
 	assert.Equal(t, 4, len(kvs))
+
+	start := time.Now().Add(-time.Minute)
+	kvs = addKVsFromOpts(SpanOptions{StartTime: start}, "hello", 1)
+	assert.Equal(t, []interface{}{"hello", 1, KeyTimestamp, start}, kvs)
+
+	so := &SpanOptions{}
+	WithStartTime(start)(so)
+	assert.Equal(t, start, so.StartTime)
+
+	links := []string{"link1", "", "link2"}
+	kvs = addKVsFromOpts(SpanOptions{Links: links}, "hello", 1)
+	assert.Equal(t, []interface{}{"hello", 1,
+		keyEdge, []string{"link1", "link2"},
+		keyLink, []string{"link1", "link2"},
+		keyLinkType, linkTypeFollowsFrom}, kvs)
+	assert.Equal(t, []interface{}{"hello", 1}, addKVsFromOpts(SpanOptions{Links: []string{""}}, "hello", 1))
+
+	WithLinks(links[0])(so)
+	WithLinks(links[2])(so)
+	assert.Equal(t, []string{"link1", "link2"}, so.Links)
 }
 
 func TestMergeKVs(t *testing.T) {
@@ -170,3 +191,30 @@ func TestMergeKVs(t *testing.T) {
 		assert.Equal(t, c.merged, mergeKVs(c.left, c.right), fmt.Sprintf("Test case: #%d", idx))
 	}
 }
+
+func TestSpanWithErrorKVs(t *testing.T) {
+	r := reporter.SetTestReporter()
+	tr := NewTrace("test")
+	s := tr.BeginSpan("L1")
+	for _, sp := range []interface{}{tr, s, nullSpan{}, &nullTrace{}} {
+		assert.Implements(t, (*SpanWithErrorKVs)(nil), sp)
+	}
+	s.End()
+	tr.End()
+	r.Close(5) // 4 events and a span message
+}
+
+func TestSpanWithEndAt(t *testing.T) {
+	r := reporter.SetTestReporter()
+	tr := NewTrace("test")
+	s := tr.BeginSpan("L1")
+	ctx := NewContext(context.Background(), tr)
+	as, _ := Detach(ctx, "async")
+	for _, sp := range []interface{}{tr, s, as, s.BeginProfile("P1"), nullSpan{}, &nullTrace{}} {
+		assert.Implements(t, (*SpanWithEndAt)(nil), sp)
+	}
+	as.End()
+	s.End()
+	tr.End()
+	r.Close(9) // 8 events and a span message
+}
@@ -0,0 +1,65 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package opentelemetry
+
+import (
+	"encoding/json"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Map selected OpenTelemetry semantic convention attributes to AppOptics analogs
+var otelAOMap = map[attribute.Key]string{
+	"peer.service":   "RemoteController",
+	"server.address": "RemoteHost",
+	"net.peer.name":  "RemoteHost",
+
+	"url.full":                  "URL",
+	"http.url":                  "URL",
+	"http.request.method":       "Method",
+	"http.method":               "Method",
+	"http.response.status_code": "Status",
+	"http.status_code":          "Status",
+
+	"db.namespace":  "Database",
+	"db.name":       "Database",
+	"db.query.text": "Query",
+	"db.statement":  "Query",
+	"db.system":     "Flavor",
+}
+
+func translateAttributeName(key attribute.Key) string {
+	if k := otelAOMap[key]; k != "" {
+		return k
+	}
+	return string(key)
+}
+
+// attributesToKVs returns the KVs of the attributes. The values of the slice
+// attributes are reported as JSON arrays.
+func attributesToKVs(attrs []attribute.KeyValue) []interface{} {
+	kvs := make([]interface{}, 0, 2*len(attrs))
+	for _, a := range attrs {
+		var v interface{}
+		switch a.Value.Type() {
+		case attribute.BOOL:
+			v = a.Value.AsBool()
+		case attribute.INT64:
+			v = a.Value.AsInt64()
+		case attribute.FLOAT64:
+			v = a.Value.AsFloat64()
+		case attribute.STRING:
+			v = a.Value.AsString()
+		case attribute.INVALID:
+			continue
+		default:
+			b, err := json.Marshal(a.Value.AsInterface())
+			if err != nil {
+				continue
+			}
+			v = string(b)
+		}
+		kvs = append(kvs, translateAttributeName(a.Key), v)
+	}
+	return kvs
+}
@@ -0,0 +1,139 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package opentelemetry
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The layout of the hex string of the X-Trace metadata: a header byte, the
+// task ID, the op ID and a flags byte.
+const (
+	xtraceLen       = 60
+	xtraceHeader    = "2B"
+	xtraceTaskStart = 2
+	xtraceOpStart   = 42
+	xtraceFlagStart = 58
+	flagSampled     = 0x01
+)
+
+type remoteXTraceKeyT struct{}
+
+var remoteXTraceKey = remoteXTraceKeyT{}
+
+// NewPropagator returns a propagator which propagates the distributed trace
+// context in the X-Trace header.
+func NewPropagator() propagation.TextMapPropagator {
+	return Propagator{}
+}
+
+// Propagator propagates the AppOptics distributed trace context in the X-Trace
+// header. It implements the propagation.TextMapPropagator interface.
+type Propagator struct{}
+
+// Inject belongs to the propagation.TextMapPropagator interface. It sets the
+// X-Trace metadata of the span in the context, if any.
+func (p Propagator) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	var md string
+	if s, ok := trace.SpanFromContext(ctx).(*span); ok {
+		s.Lock()
+		md = s.aoSpan.MetadataString()
+		s.Unlock()
+	} else {
+		md = ao.MetadataString(ctx)
+	}
+	if md == "" {
+		// propagate the remote trace context if it's not continued in process
+		md = remoteXTrace(ctx)
+	}
+	if md != "" {
+		carrier.Set(ao.HTTPHeaderName, md)
+	}
+}
+
+// Extract belongs to the propagation.TextMapPropagator interface. It returns a
+// copy of the context with the X-Trace metadata of the carrier, which is
+// continued by the next span started without a parent span. The remote span
+// context is bound to the returned context as well.
+func (p Propagator) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	md := carrier.Get(ao.HTTPHeaderName)
+	sc, ok := spanContextFromXTrace(md, true)
+	if !ok {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, remoteXTraceKey, md)
+	return trace.ContextWithRemoteSpanContext(ctx, sc)
+}
+
+// Fields belongs to the propagation.TextMapPropagator interface.
+func (p Propagator) Fields() []string {
+	return []string{ao.HTTPHeaderName}
+}
+
+// remoteXTrace returns the X-Trace metadata extracted by the propagator, if any.
+func remoteXTrace(ctx context.Context) string {
+	md, _ := ctx.Value(remoteXTraceKey).(string)
+	return md
+}
+
+// spanContextFromXTrace returns the span context of the X-Trace metadata. The
+// trace ID is the first 16 bytes of the task ID, and the span ID is the op ID.
+func spanContextFromXTrace(md string, remote bool) (trace.SpanContext, bool) {
+	if len(md) != xtraceLen {
+		return trace.SpanContext{}, false
+	}
+	b, err := hex.DecodeString(md)
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+	var traceID trace.TraceID
+	var spanID trace.SpanID
+	copy(traceID[:], b[xtraceTaskStart/2:])
+	copy(spanID[:], b[xtraceOpStart/2:])
+	var flags trace.TraceFlags
+	if b[xtraceFlagStart/2]&flagSampled != 0 {
+		flags = trace.FlagsSampled
+	}
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: flags,
+		Remote:     remote,
+	})
+	return sc, sc.IsValid()
+}
+
+// edgeToSpanContext returns the X-Trace metadata of the span context if it
+// belongs to the same trace as the X-Trace metadata md, otherwise an empty
+// string.
+func edgeToSpanContext(md string, sc trace.SpanContext) string {
+	own, ok := spanContextFromXTrace(md, false)
+	if !ok || own.TraceID() != sc.TraceID() {
+		return ""
+	}
+	return md[:xtraceOpStart] + strings.ToUpper(sc.SpanID().String()) + md[xtraceFlagStart:]
+}
+
+// linkXTrace returns the X-Trace metadata of a linked span context. The task ID
+// of a span of the trace of the X-Trace metadata md is known, but it's padded
+// from the trace ID for the spans of other traces.
+func linkXTrace(md string, sc trace.SpanContext) string {
+	if !sc.IsValid() {
+		return ""
+	}
+	if edge := edgeToSpanContext(md, sc); edge != "" {
+		return edge
+	}
+	flags := "00"
+	if sc.IsSampled() {
+		flags = "01"
+	}
+	taskID := sc.TraceID().String() + strings.Repeat("0", xtraceOpStart-xtraceTaskStart-2*len(sc.TraceID()))
+	return xtraceHeader + strings.ToUpper(taskID+sc.SpanID().String()) + flags
+}
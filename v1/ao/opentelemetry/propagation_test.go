@@ -0,0 +1,93 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package opentelemetry
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	g "github.com/appoptics/appoptics-apm-go/v1/ao/internal/graphtest"
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/reporter"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const testXTrace = "2B7435A9FE510AE4533414D425DADF4E180D2B4E3649E60702469DB05F01"
+
+func TestSpanContextFromXTrace(t *testing.T) {
+	sc, ok := spanContextFromXTrace(testXTrace, true)
+	assert.True(t, ok)
+	assert.Equal(t, "7435a9fe510ae4533414d425dadf4e18", sc.TraceID().String())
+	assert.Equal(t, "49e60702469db05f", sc.SpanID().String())
+	assert.True(t, sc.IsSampled())
+	assert.True(t, sc.IsRemote())
+
+	sc, ok = spanContextFromXTrace(testXTrace[:58]+"00", false)
+	assert.True(t, ok)
+	assert.False(t, sc.IsSampled())
+	assert.False(t, sc.IsRemote())
+
+	for _, md := range []string{"", "invalid", testXTrace[:58], testXTrace[:58] + "ZZ",
+		"2B0000000000000000000000000000000000000000000000000000000001"} {
+		_, ok = spanContextFromXTrace(md, false)
+		assert.False(t, ok, md)
+	}
+}
+
+func TestEdgeToSpanContext(t *testing.T) {
+	sc, _ := spanContextFromXTrace("2B7435A9FE510AE4533414D425DADF4E180D2B4E36AAAAAAAAAAAAAAAA01", false)
+	assert.Equal(t, "2B7435A9FE510AE4533414D425DADF4E180D2B4E36AAAAAAAAAAAAAAAA01", edgeToSpanContext(testXTrace, sc))
+
+	other, _ := spanContextFromXTrace("2B0000000000000000000000000000000000000000AAAAAAAAAAAAAAAA01", false)
+	assert.Equal(t, "", edgeToSpanContext(testXTrace, other))
+	assert.Equal(t, "", edgeToSpanContext("", sc))
+}
+
+func TestLinkXTrace(t *testing.T) {
+	sc, _ := spanContextFromXTrace("2B7435A9FE510AE4533414D425DADF4E180D2B4E36AAAAAAAAAAAAAAAA01", false)
+	assert.Equal(t, "2B7435A9FE510AE4533414D425DADF4E180D2B4E36AAAAAAAAAAAAAAAA01", linkXTrace(testXTrace, sc))
+
+	// the task ID of another trace is padded from its trace ID
+	other, _ := spanContextFromXTrace("2B1111111111111111111111111111111122222222AAAAAAAAAAAAAAAA00", false)
+	assert.Equal(t, "2B1111111111111111111111111111111100000000AAAAAAAAAAAAAAAA00", linkXTrace(testXTrace, other))
+	assert.Equal(t, "2B1111111111111111111111111111111100000000AAAAAAAAAAAAAAAA00", linkXTrace("", other))
+	assert.Equal(t, "", linkXTrace(testXTrace, trace.SpanContext{}))
+}
+
+func TestPropagator(t *testing.T) {
+	r := reporter.SetTestReporter() // set up test reporter
+	p := NewPropagator()
+	assert.Equal(t, []string{ao.HTTPHeaderName}, p.Fields())
+	tr := NewTracerProvider().Tracer("")
+
+	// nothing to inject or extract
+	header := http.Header{}
+	p.Inject(context.Background(), propagation.HeaderCarrier(header))
+	assert.Empty(t, header)
+	assert.Equal(t, context.Background(), p.Extract(context.Background(), propagation.HeaderCarrier(header)))
+
+	ctx, client := tr.Start(context.Background(), "client")
+	p.Inject(ctx, propagation.HeaderCarrier(header))
+	assert.Len(t, header.Get(ao.HTTPHeaderName), xtraceLen)
+	assert.Equal(t, client.SpanContext().SpanID(), trace.SpanContextFromContext(
+		p.Extract(context.Background(), propagation.HeaderCarrier(header))).SpanID())
+
+	// the remote trace is continued by the server span
+	serverCtx := p.Extract(context.Background(), propagation.HeaderCarrier(header))
+	assert.True(t, trace.SpanContextFromContext(serverCtx).IsRemote())
+	_, server := tr.Start(serverCtx, "server")
+	assert.Equal(t, client.SpanContext().TraceID(), server.SpanContext().TraceID())
+	server.End()
+	client.End()
+
+	r.Close(4)
+	g.AssertGraph(t, r.EventBufs, 4, g.AssertNodeMap{
+		{"client", "entry"}: {},
+		{"server", "entry"}: {Edges: g.Edges{{"client", "entry"}}},
+		{"server", "exit"}:  {Edges: g.Edges{{"server", "entry"}}},
+		{"client", "exit"}:  {Edges: g.Edges{{"client", "entry"}}},
+	})
+}
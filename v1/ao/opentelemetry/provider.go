@@ -0,0 +1,35 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+// Package opentelemetry provides an OpenTelemetry trace.TracerProvider backed by
+// the ao package, so that the spans created by libraries instrumented with the
+// OpenTelemetry API are reported to AppOptics as part of the same traces.
+//
+//	otel.SetTracerProvider(opentelemetry.NewTracerProvider())
+//	otel.SetTextMapPropagator(opentelemetry.NewPropagator())
+//
+// A span started without a parent begins a new AppOptics trace, or continues the
+// distributed trace extracted by the Propagator from the X-Trace header. A span
+// started with a parent, either an OpenTelemetry span of this package or an
+// ao.Span bound to the context, is reported as a child span.
+package opentelemetry
+
+import (
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+)
+
+// NewTracerProvider returns a new AppOptics tracer provider.
+func NewTracerProvider() trace.TracerProvider {
+	return &TracerProvider{}
+}
+
+// TracerProvider provides the tracers which report spans to AppOptics.
+type TracerProvider struct {
+	embedded.TracerProvider
+}
+
+// Tracer belongs to the trace.TracerProvider interface. The name of the
+// instrumentation library is reported by the spans of the tracer.
+func (p *TracerProvider) Tracer(name string, opts ...trace.TracerOption) trace.Tracer {
+	return &Tracer{provider: p, name: name}
+}
@@ -0,0 +1,213 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package opentelemetry
+
+import (
+	"sync"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+)
+
+// The keys of the KVs reported for the events and status of the spans
+const (
+	keyEventName   = "OTEventName"
+	keyStatusCode  = "OTStatusCode"
+	errorClassOTel = "error"
+)
+
+// The KVs of the links added after a span started, which are reported like
+// the links of ao.SpanOptions.
+const (
+	keyLink             = "Link"
+	keyLinkType         = "LinkType"
+	linkTypeFollowsFrom = "FollowsFrom"
+)
+
+// span is an OpenTelemetry span which reports to an ao.Span, or to an ao.Trace
+// if it's the root span.
+type span struct {
+	embedded.Span
+	tracer *Tracer
+
+	sync.Mutex // protects the fields below
+	aoSpan     ao.Span
+	trace      ao.Trace // set if the span is the root span of the trace
+	sc         trace.SpanContext
+	ended      bool
+	statusCode codes.Code
+	statusDesc string
+	// the attributes used to categorize the service metrics of a root span
+	method, path string
+	httpStatus   int
+}
+
+func (s *span) beginChild(spanName string, links []trace.Link, kvs []interface{}) ao.Span {
+	s.Lock()
+	defer s.Unlock()
+	return s.aoSpan.BeginSpanWithOptions(spanName, linkOptions(s.aoSpan.MetadataString(), links), kvs...)
+}
+
+// overriddenBy returns true if the ao span bound to the context is another
+// (sampled) ao span than the one of this span, e.g. an ao span started in the
+// context of this span.
+func (s *span) overriddenBy(aoSpan ao.Span) bool {
+	s.Lock()
+	defer s.Unlock()
+	return aoSpan != s.aoSpan && aoSpan.MetadataString() != ""
+}
+
+// End belongs to the trace.Span interface.
+func (s *span) End(opts ...trace.SpanEndOption) {
+	cfg := trace.NewSpanEndConfig(opts...)
+	s.Lock()
+	defer s.Unlock()
+	if s.ended {
+		return
+	}
+	s.ended = true
+
+	var args []interface{}
+	if s.statusCode != codes.Unset {
+		args = append(args, keyStatusCode, s.statusCode.String())
+	}
+	if s.statusCode == codes.Error {
+		s.aoSpan.Error(errorClassOTel, s.statusDesc)
+	}
+	if s.trace != nil {
+		if s.method != "" {
+			s.trace.SetMethod(s.method)
+		}
+		if s.path != "" {
+			s.trace.SetPath(s.path)
+		}
+		if s.httpStatus != 0 {
+			s.trace.SetStatus(s.httpStatus)
+		}
+	}
+	if sp, ok := s.aoSpan.(ao.SpanWithEndAt); ok && !cfg.Timestamp().IsZero() {
+		sp.EndAt(cfg.Timestamp(), args...)
+	} else {
+		s.aoSpan.End(args...)
+	}
+}
+
+// AddEvent belongs to the trace.Span interface. The event is reported as an
+// info event of the span.
+func (s *span) AddEvent(name string, opts ...trace.EventOption) {
+	cfg := trace.NewEventConfig(opts...)
+	s.Lock()
+	defer s.Unlock()
+	if s.ended {
+		return
+	}
+	args := []interface{}{keyEventName, name}
+	if ts := cfg.Timestamp(); !ts.IsZero() {
+		args = append(args, ao.KeyTimestamp, ts)
+	}
+	s.aoSpan.Info(append(args, attributesToKVs(cfg.Attributes())...)...)
+}
+
+// AddLink belongs to the trace.Span interface. The link is reported by the
+// exit event of the span, with an edge to the linked span if it belongs to
+// the same trace.
+func (s *span) AddLink(link trace.Link) {
+	s.Lock()
+	defer s.Unlock()
+	if s.ended {
+		return
+	}
+	if md := linkXTrace(s.aoSpan.MetadataString(), link.SpanContext); md != "" {
+		s.aoSpan.AddEndArgs("Edge", md, keyLink, md, keyLinkType, linkTypeFollowsFrom)
+	}
+}
+
+// IsRecording belongs to the trace.Span interface.
+func (s *span) IsRecording() bool {
+	s.Lock()
+	defer s.Unlock()
+	return !s.ended && s.aoSpan.IsReporting()
+}
+
+// RecordError belongs to the trace.Span interface. The error is reported as an
+// error event of the span.
+func (s *span) RecordError(err error, opts ...trace.EventOption) {
+	if err == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	if s.ended {
+		return
+	}
+	s.aoSpan.Err(err)
+}
+
+// SpanContext belongs to the trace.Span interface. The span context is derived
+// from the X-Trace metadata of the entry event of the span.
+func (s *span) SpanContext() trace.SpanContext {
+	s.Lock()
+	defer s.Unlock()
+	return s.sc
+}
+
+// SetStatus belongs to the trace.Span interface. An Error status is reported
+// as an error event when the span ends.
+func (s *span) SetStatus(code codes.Code, description string) {
+	s.Lock()
+	defer s.Unlock()
+	// an Ok status is final, and a status is never reset to Unset.
+	if s.ended || s.statusCode == codes.Ok || code == codes.Unset {
+		return
+	}
+	s.statusCode = code
+	if code == codes.Error {
+		s.statusDesc = description
+	}
+}
+
+// SetName belongs to the trace.Span interface.
+func (s *span) SetName(name string) {
+	s.Lock()
+	defer s.Unlock()
+	if s.ended {
+		return
+	}
+	s.aoSpan.SetOperationName(name)
+}
+
+// SetAttributes belongs to the trace.Span interface. The attributes are
+// reported by the exit event of the span.
+func (s *span) SetAttributes(kv ...attribute.KeyValue) {
+	s.Lock()
+	defer s.Unlock()
+	if s.ended {
+		return
+	}
+	s.recordAttributes(kv)
+	s.aoSpan.AddEndArgs(attributesToKVs(kv)...)
+}
+
+// TracerProvider belongs to the trace.Span interface.
+func (s *span) TracerProvider() trace.TracerProvider { return s.tracer.provider }
+
+// recordAttributes keeps the attributes which categorize the service metrics.
+func (s *span) recordAttributes(kv []attribute.KeyValue) {
+	for _, a := range kv {
+		switch a.Key {
+		case "http.request.method", "http.method":
+			s.method = a.Value.Emit()
+		case "http.route", "url.path", "http.target":
+			if s.path == "" || a.Key == "http.route" {
+				s.path = a.Value.Emit()
+			}
+		case "http.response.status_code", "http.status_code":
+			if a.Value.Type() == attribute.INT64 {
+				s.httpStatus = int(a.Value.AsInt64())
+			}
+		}
+	}
+}
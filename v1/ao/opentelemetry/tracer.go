@@ -0,0 +1,109 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package opentelemetry
+
+import (
+	"context"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+)
+
+// The keys of the KVs reported by the spans
+const (
+	keySpanKind        = "OTSpanKind"
+	keyInstrumentation = "OTInstrumentation"
+)
+
+// Tracer reports the spans of an instrumentation library to AppOptics.
+type Tracer struct {
+	embedded.Tracer
+	provider *TracerProvider
+	name     string
+}
+
+// Start belongs to the trace.Tracer interface.
+func (t *Tracer) Start(ctx context.Context, spanName string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	cfg := trace.NewSpanStartConfig(opts...)
+	kvs := attributesToKVs(cfg.Attributes())
+	if kind := cfg.SpanKind(); kind != trace.SpanKindUnspecified {
+		kvs = append(kvs, keySpanKind, kind.String())
+	}
+	if t.name != "" {
+		kvs = append(kvs, keyInstrumentation, t.name)
+	}
+	start := cfg.Timestamp()
+	if !start.IsZero() {
+		kvs = append(kvs, ao.KeyTimestamp, start)
+	}
+
+	s := &span{tracer: t}
+	s.recordAttributes(cfg.Attributes())
+	if !cfg.NewRoot() {
+		s.aoSpan = beginChildSpan(ctx, spanName, cfg.Links(), kvs)
+	}
+	if s.aoSpan == nil {
+		// no parent span found, so make a new trace, continuing the remote
+		// trace extracted by the propagator, if any.
+		var remote string
+		if !cfg.NewRoot() {
+			remote = remoteXTrace(ctx)
+		}
+		s.trace = ao.NewTraceFromIDWithOptions(spanName, remote, linkOptions(remote, cfg.Links()),
+			func() ao.KVMap {
+				return fromKVs(kvs)
+			})
+		if !start.IsZero() {
+			s.trace.SetStartTime(start)
+		}
+		s.aoSpan = s.trace
+	}
+	s.sc, _ = spanContextFromXTrace(s.aoSpan.MetadataString(), false)
+
+	if s.trace != nil {
+		ctx = ao.NewContext(ctx, s.trace)
+	} else {
+		ctx = ao.NewSpanContext(ctx, s.aoSpan)
+	}
+	return trace.ContextWithSpan(ctx, s), s
+}
+
+// beginChildSpan returns a child of the parent span in the context, which is
+// either a span of this package or an ao.Span. An ao.Span bound to the context
+// after the span of this package was started takes precedence. It returns nil
+// if there is no parent span.
+func beginChildSpan(ctx context.Context, spanName string, links []trace.Link, kvs []interface{}) ao.Span {
+	aoParent := ao.FromContext(ctx)
+	if parent, ok := trace.SpanFromContext(ctx).(*span); ok {
+		if !parent.overriddenBy(aoParent) {
+			return parent.beginChild(spanName, links, kvs)
+		}
+	}
+	if md := aoParent.MetadataString(); md != "" {
+		return aoParent.BeginSpanWithOptions(spanName, linkOptions(md, links), kvs...)
+	}
+	return nil
+}
+
+// linkOptions returns the options of a span of the trace of the X-Trace
+// metadata md, which follows from the spans linked.
+func linkOptions(md string, links []trace.Link) ao.SpanOptions {
+	var opts ao.SpanOptions
+	for _, l := range links {
+		if link := linkXTrace(md, l.SpanContext); link != "" {
+			opts.Links = append(opts.Links, link)
+		}
+	}
+	return opts
+}
+
+func fromKVs(kvs []interface{}) ao.KVMap {
+	m := make(ao.KVMap, len(kvs)/2)
+	for i := 0; i+1 < len(kvs); i += 2 {
+		if k, ok := kvs[i].(string); ok {
+			m[k] = kvs[i+1]
+		}
+	}
+	return m
+}
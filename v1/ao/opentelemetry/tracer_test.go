@@ -0,0 +1,153 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package opentelemetry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	g "github.com/appoptics/appoptics-apm-go/v1/ao/internal/graphtest"
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/reporter"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracer(t *testing.T) {
+	r := reporter.SetTestReporter() // set up test reporter
+	tr := NewTracerProvider().Tracer("test-lib")
+
+	ctx, root := tr.Start(context.Background(), "root", trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("http.request.method", "GET"), attribute.String("http.route", "/users/:id")))
+	assert.True(t, root.IsRecording())
+	assert.True(t, root.SpanContext().IsValid())
+	assert.True(t, root.SpanContext().IsSampled())
+	assert.Equal(t, root, trace.SpanFromContext(ctx))
+	md := ao.MetadataString(ctx)
+	assert.NotEmpty(t, md)
+
+	childCtx, child := tr.Start(ctx, "child", trace.WithAttributes(attribute.String("db.system", "postgresql")))
+	child.AddEvent("query", trace.WithAttributes(attribute.Int("rows", 3)))
+	child.SetAttributes(attribute.StringSlice("tags", []string{"a", "b"}))
+	child.SetStatus(codes.Error, "query failed")
+	child.End()
+	child.End() // ending a span twice is a no-op
+	assert.False(t, child.IsRecording())
+
+	// a span started from an ao span is a child of the ao span
+	aoSpan, aoCtx := ao.BeginSpan(ctx, "aoSpan")
+	_, grandchild := tr.Start(aoCtx, "grandchild", trace.WithLinks(trace.Link{SpanContext: child.SpanContext()}))
+	grandchild.RecordError(errors.New("grandchild error"))
+	grandchild.End()
+	aoSpan.End()
+
+	root.SetAttributes(attribute.Int("http.response.status_code", 404))
+	root.SetStatus(codes.Ok, "")
+	root.SetStatus(codes.Error, "ignored")
+	root.End()
+	assert.NotNil(t, childCtx)
+
+	r.Close(11)
+	g.AssertGraph(t, r.EventBufs, 11, g.AssertNodeMap{
+		{"root", "entry"}: {Callback: func(n g.Node) {
+			assert.Equal(t, "server", n.Map[keySpanKind])
+			assert.Equal(t, "test-lib", n.Map[keyInstrumentation])
+			assert.Equal(t, "GET", n.Map["Method"])
+		}},
+		{"child", "entry"}: {Edges: g.Edges{{"root", "entry"}}, Callback: func(n g.Node) {
+			assert.Equal(t, "postgresql", n.Map["Flavor"])
+		}},
+		{"child", "info"}: {Edges: g.Edges{{"child", "entry"}}, Callback: func(n g.Node) {
+			assert.Equal(t, "query", n.Map[keyEventName])
+			assert.EqualValues(t, 3, n.Map["rows"])
+		}},
+		{"child", "error"}: {Edges: g.Edges{{"child", "info"}}, Callback: func(n g.Node) {
+			assert.Equal(t, "query failed", n.Map["ErrorMsg"])
+		}},
+		{"child", "exit"}: {Edges: g.Edges{{"child", "error"}}, Callback: func(n g.Node) {
+			assert.Equal(t, `["a","b"]`, n.Map["tags"])
+			assert.Equal(t, "Error", n.Map[keyStatusCode])
+		}},
+		{"aoSpan", "entry"}: {Edges: g.Edges{{"root", "entry"}}},
+		{"grandchild", "entry"}: {Edges: g.Edges{{"child", "entry"}, {"aoSpan", "entry"}}, Callback: func(n g.Node) {
+			assert.Equal(t, edgeToSpanContext(md, child.SpanContext()), n.Map[keyLink])
+			assert.Equal(t, linkTypeFollowsFrom, n.Map[keyLinkType])
+		}},
+		{"grandchild", "error"}: {Edges: g.Edges{{"grandchild", "entry"}}, Callback: func(n g.Node) {
+			assert.Equal(t, "grandchild error", n.Map["ErrorMsg"])
+		}},
+		{"grandchild", "exit"}: {Edges: g.Edges{{"grandchild", "error"}}},
+		{"aoSpan", "exit"}:     {Edges: g.Edges{{"grandchild", "exit"}, {"aoSpan", "entry"}}},
+		{"root", "exit"}: {Edges: g.Edges{{"child", "exit"}, {"aoSpan", "exit"}, {"root", "entry"}}, Callback: func(n g.Node) {
+			assert.Equal(t, "Ok", n.Map[keyStatusCode])
+			assert.EqualValues(t, 404, n.Map["Status"])
+		}},
+	})
+}
+
+func TestTracerNewRoot(t *testing.T) {
+	r := reporter.SetTestReporter() // set up test reporter
+	tr := NewTracerProvider().Tracer("")
+
+	ctx, span1 := tr.Start(context.Background(), "span1")
+	_, span2 := tr.Start(ctx, "span2", trace.WithNewRoot())
+	assert.NotEqual(t, span1.SpanContext().TraceID(), span2.SpanContext().TraceID())
+	span2.End()
+	span1.End()
+
+	r.Close(4)
+	g.AssertGraph(t, r.EventBufs, 4, g.AssertNodeMap{
+		{"span1", "entry"}: {},
+		{"span1", "exit"}:  {Edges: g.Edges{{"span1", "entry"}}},
+		{"span2", "entry"}: {},
+		{"span2", "exit"}:  {Edges: g.Edges{{"span2", "entry"}}},
+	})
+}
+
+func TestTracerLinks(t *testing.T) {
+	r := reporter.SetTestReporter() // set up test reporter
+	tr := NewTracerProvider().Tracer("")
+
+	_, other := tr.Start(context.Background(), "other")
+	other.End()
+	ctx, root := tr.Start(context.Background(), "root", trace.WithLinks(trace.Link{SpanContext: other.SpanContext()}))
+	_, child := tr.Start(ctx, "child")
+	child.End()
+	root.(*span).AddLink(trace.Link{SpanContext: child.SpanContext()})
+	root.(*span).AddLink(trace.Link{}) // invalid links are ignored
+	md := ao.MetadataString(ctx)
+	root.End()
+
+	r.Close(6)
+	g.AssertGraph(t, r.EventBufs, 6, g.AssertNodeMap{
+		{"other", "entry"}: {},
+		{"other", "exit"}:  {Edges: g.Edges{{"other", "entry"}}},
+		// there is no edge to the span of another trace
+		{"root", "entry"}: {Callback: func(n g.Node) {
+			assert.Equal(t, linkXTrace(md, other.SpanContext()), n.Map[keyLink])
+			assert.Equal(t, linkTypeFollowsFrom, n.Map[keyLinkType])
+		}},
+		{"child", "entry"}: {Edges: g.Edges{{"root", "entry"}}},
+		{"child", "exit"}:  {Edges: g.Edges{{"child", "entry"}}},
+		// the links added after the span started are reported when it ends
+		{"root", "exit"}: {Edges: g.Edges{{"child", "entry"}, {"child", "exit"}, {"root", "entry"}}, Callback: func(n g.Node) {
+			assert.Equal(t, edgeToSpanContext(md, child.SpanContext()), n.Map[keyLink])
+			assert.Equal(t, linkTypeFollowsFrom, n.Map[keyLinkType])
+		}},
+	})
+}
+
+func TestAttributesToKVs(t *testing.T) {
+	assert.Equal(t, []interface{}{"URL", "http://a/b", "Status", int64(200), "ok", true, "ratio", 0.5, "ids", "[1,2]"},
+		attributesToKVs([]attribute.KeyValue{
+			attribute.String("url.full", "http://a/b"),
+			attribute.Int("http.response.status_code", 200),
+			attribute.Bool("ok", true),
+			attribute.Float64("ratio", 0.5),
+			attribute.IntSlice("ids", []int{1, 2}),
+			{Key: "invalid"},
+		}))
+}
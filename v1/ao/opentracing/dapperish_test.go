@@ -20,7 +20,6 @@ import (
 	"github.com/opentracing/opentracing-go/log"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"context"
 )
 
 // client/server test based on basictracer-go/examples/dapperish.go
@@ -34,10 +33,9 @@ const (
 
 func client(t *testing.T, port int, wg *sync.WaitGroup) {
 	span := opentracing.StartSpan("getInput")
-	ctx := opentracing.ContextWithSpan(context.Background(), span)
 	// Make sure that global baggage propagation works.
 	span.SetBaggageItem(testBaggageKey, testBaggageVal)
-	span.LogFields(log.Object("ctx", ctx))
+	span.LogFields(log.String("event", "start"))
 	text := strings.TrimSpace(testTextVal)
 	span.LogFields(log.String(testTextKey, text))
 
@@ -52,7 +50,7 @@ func client(t *testing.T, port int, wg *sync.WaitGroup) {
 	if err != nil {
 		span.LogFields(log.Error(err))
 	} else {
-		span.LogFields(log.Object("response", resp))
+		span.LogFields(log.String("event", "response"), log.Int("status", resp.StatusCode))
 	}
 
 	span.Finish()
@@ -101,18 +99,23 @@ func TestTracer(t *testing.T) {
 	go client(t, port, &wg)
 
 	wg.Wait()
-	r.Close(4)
-	g.AssertGraph(t, r.EventBufs, 4, g.AssertNodeMap{
-		{"getInput", "entry"}: {},
-		{"getInput", "exit"}: {Edges: g.Edges{{"getInput", "entry"}}, Callback: func(n g.Node) {
-			assert.Equal(t, testTextVal, n.Map[otLogPrefix+testTextKey])
+	r.Close(8)
+	g.AssertGraph(t, r.EventBufs, 8, g.AssertNodeKVMap{
+		{"getInput", "entry", "", ""}:                                {},
+		{"getInput", "info", otLogPrefix + "event", "start"}:         {Edges: g.Edges{{"getInput", "entry"}}},
+		{"getInput", "info", otLogPrefix + testTextKey, testTextVal}: {Edges: g.Edges{{"getInput", "info"}}},
+		{"getInput", "info", otLogPrefix + "event", "response"}: {Edges: g.Edges{{"getInput", "info"}}, Callback: func(n g.Node) {
+			assert.EqualValues(t, http.StatusOK, n.Map[otLogPrefix+"status"])
 		}},
-		{"serverSpan", "entry"}: {Edges: g.Edges{{"getInput", "entry"}}, Callback: nil},
-		{"serverSpan", "exit"}: {Edges: g.Edges{{"serverSpan", "entry"}}, Callback: func(n g.Node) {
+		{"getInput", "exit", "", ""}:    {Edges: g.Edges{{"getInput", "info"}}},
+		{"serverSpan", "entry", "", ""}: {Edges: g.Edges{{"getInput", "info"}}, Callback: nil},
+		{"serverSpan", "info", "", ""}: {Edges: g.Edges{{"serverSpan", "entry"}}, Callback: func(n g.Node) {
+			assert.Equal(t, testTextVal, n.Map[otLogPrefix+"request body"])
+		}},
+		{"serverSpan", "exit", "", ""}: {Edges: g.Edges{{"serverSpan", "info"}}, Callback: func(n g.Node) {
 			assert.Equal(t, "server", n.Map["OTComponent"])
 			assert.Equal(t, "/", n.Map["URL"])
 			assert.Equal(t, "POST", n.Map["Method"])
-			assert.Equal(t, testTextVal, n.Map[otLogPrefix+"request body"])
 		}},
 	})
 }
@@ -3,6 +3,7 @@
 package opentracing
 
 import (
+	"fmt"
 	"sync"
 
 	"github.com/appoptics/appoptics-apm-go/v1/ao"
@@ -35,44 +36,76 @@ func (t *Tracer) StartSpan(operationName string, opts ...ot.StartSpanOption) ot.
 }
 
 func (t *Tracer) startSpanWithOptions(operationName string, opts ot.StartSpanOptions) ot.Span {
-	// check if trace has already started (use Trace if there is no parent, Span otherwise)
-	// XXX handle StartTime
-
-	for _, ref := range opts.References {
-		switch ref.Type {
-		// trace has parent XXX only handles one parent
-		case ot.ChildOfRef, ot.FollowsFromRef:
-			refCtx := ref.ReferencedContext.(spanContext)
-			if refCtx.span == nil { // referenced spanContext created by Extract()
-				var span ao.Span
-				if refCtx.sampled {
-					span = ao.NewTraceFromID(operationName, refCtx.remoteMD, func() ao.KVMap {
-						return translateTags(opts.Tags)
-					})
-				} else {
-					span = ao.NewNullTrace()
-				}
-				return &spanImpl{tracer: t, context: spanContext{
-					span:    span,
-					sampled: refCtx.sampled,
-					baggage: refCtx.baggage,
-				},
-				}
-			}
-			// referenced spanContext was in-process
-			return &spanImpl{tracer: t, context: spanContext{span: refCtx.span.BeginSpan(operationName)}}
-		}
+	// The parent is the first ChildOf reference, or the first FollowsFrom
+	// reference if there is none. XXX only handles one ChildOf parent
+	parent, followsFrom := firstReference(opts.References, ot.ChildOfRef)
+	if parent == nil {
+		parent, followsFrom = firstReference(opts.References, ot.FollowsFromRef)
+	}
+	aoOpts := ao.SpanOptions{StartTime: opts.StartTime, Links: followsFrom}
+	if parent == nil {
+		// no parent span found, so make new trace and return as span
+		return t.newSpan(ao.NewTraceFromIDWithOptions(operationName, "", aoOpts, nil), nil)
 	}
 
-	// otherwise, no parent span found, so make new trace and return as span
-	newSpan := &spanImpl{tracer: t, context: spanContext{span: ao.NewTrace(operationName)}}
-	return newSpan
+	// referenced spanContext was in-process
+	if parent.span != nil && parent.span.MetadataString() != "" {
+		return t.newSpan(parent.span.BeginSpanWithOptions(operationName, aoOpts), nil)
+	}
+	// referenced spanContext created by Extract(), or an in-process span
+	// that has already finished: continue the trace from its metadata.
+	var span ao.Span
+	if parent.span != nil && parent.remoteMD != "" || parent.span == nil && parent.sampled {
+		span = ao.NewTraceFromIDWithOptions(operationName, parent.remoteMD, aoOpts, func() ao.KVMap {
+			return translateTags(opts.Tags)
+		})
+	} else {
+		span = ao.NewNullTrace()
+	}
+	return &spanImpl{tracer: t, context: spanContext{
+		span:     span,
+		remoteMD: span.MetadataString(),
+		sampled:  parent.sampled,
+		baggage:  parent.baggage,
+	},
+	}
+}
+
+// newSpan returns a new span of the tracer, keeping the metadata of the ao
+// span at its start so that other spans can follow from it once it finished.
+func (t *Tracer) newSpan(span ao.Span, baggage map[string]string) *spanImpl {
+	return &spanImpl{tracer: t, context: spanContext{
+		span:     span,
+		remoteMD: span.MetadataString(),
+		baggage:  baggage,
+	}}
+}
+
+// firstReference returns the context of the first reference of type refType,
+// and the metadata of all the spans referenced as FollowsFrom.
+func firstReference(refs []ot.SpanReference, refType ot.SpanReferenceType) (*spanContext, []string) {
+	var first *spanContext
+	var followsFrom []string
+	for _, ref := range refs {
+		refCtx, ok := ref.ReferencedContext.(spanContext)
+		if !ok {
+			continue
+		}
+		if ref.Type == refType && first == nil {
+			first = &refCtx
+		}
+		if ref.Type == ot.FollowsFromRef {
+			followsFrom = append(followsFrom, refCtx.metadataString())
+		}
+	}
+	return first, followsFrom
 }
 
 type spanContext struct {
 	// 1. spanContext created by StartSpanWithOptions
 	span ao.Span
-	// 2. spanContext created by Extract()
+	// 2. spanContext created by Extract(), or the metadata of span at its
+	// start, to continue from it once it finished
 	remoteMD string
 	sampled  bool
 
@@ -133,22 +166,77 @@ func (s *spanImpl) BaggageItem(key string) string {
 	return s.context.baggage[key]
 }
 
+// metadataString returns the metadata of the span referenced by c, or the
+// metadata it started or was extracted with if it has no current metadata.
+func (c spanContext) metadataString() string {
+	if c.span != nil {
+		if md := c.span.MetadataString(); md != "" {
+			return md
+		}
+	}
+	return c.remoteMD
+}
+
 const otLogPrefix = "OT-Log-"
 
-// LogFields adds the fields to the span.
+// LogFields reports the fields as an info event of the span, or an error event
+// if the fields follow the error logging convention of OpenTracing.
 func (s *spanImpl) LogFields(fields ...log.Field) {
 	s.Lock()
 	defer s.Unlock()
-	for _, field := range fields {
-		s.context.span.AddEndArgs(otLogPrefix+field.Key(), field.Value())
-	}
+	s.logRecord(ot.LogRecord{Fields: fields})
 }
 
-// LogKV adds KVs to the span.
+// LogKV reports the alternating key and value arguments as an info event of
+// the span, like LogFields.
 func (s *spanImpl) LogKV(keyVals ...interface{}) {
-	s.Lock()
-	defer s.Unlock()
-	s.context.span.AddEndArgs(keyVals...)
+	fields, err := log.InterleavedKVToFields(keyVals...)
+	if err != nil {
+		s.LogFields(log.Error(err), log.String("function", "LogKV"))
+		return
+	}
+	s.LogFields(fields...)
+}
+
+// logRecord reports the log record as an info event, or an error event if the
+// record has an "error" (or "error.object") field or an "event" field with the
+// value "error". The event is reported with the timestamp of the record, if any.
+func (s *spanImpl) logRecord(rec ot.LogRecord) {
+	var args []interface{}
+	if !rec.Timestamp.IsZero() {
+		args = append(args, ao.KeyTimestamp, rec.Timestamp)
+	}
+	var isError bool
+	errClass, errMsg := "error", ""
+	for _, field := range rec.Fields {
+		value := field.Value()
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		switch field.Key() {
+		case "error", "error.object":
+			isError = true
+			if errMsg == "" {
+				errMsg = fmt.Sprint(value)
+			}
+		case "event":
+			if value == "error" {
+				isError = true
+			}
+		case "error.kind":
+			errClass = fmt.Sprint(value)
+		case "message":
+			errMsg = fmt.Sprint(value)
+		}
+		args = append(args, otLogPrefix+field.Key(), value)
+	}
+	if !isError {
+		s.context.span.Info(args...)
+	} else if span, ok := s.context.span.(ao.SpanWithErrorKVs); ok {
+		span.ErrorWithKVs(errClass, errMsg, args...)
+	} else {
+		s.context.span.Error(errClass, errMsg)
+	}
 }
 
 // Context returns the span context.
@@ -170,11 +258,20 @@ func (s *spanImpl) Tracer() ot.Tracer { return s.tracer }
 
 // FinishWithOptions is like Finish() but with explicit control over
 // timestamps and log data.
-// XXX handle FinishTime, LogRecords
 func (s *spanImpl) FinishWithOptions(opts ot.FinishOptions) {
 	s.Lock()
 	defer s.Unlock()
-	s.context.span.End()
+	for _, rec := range opts.LogRecords {
+		s.logRecord(rec)
+	}
+	for _, data := range opts.BulkLogData {
+		s.logRecord(data.ToLogRecord())
+	}
+	if span, ok := s.context.span.(ao.SpanWithEndAt); ok && !opts.FinishTime.IsZero() {
+		span.EndAt(opts.FinishTime)
+	} else {
+		s.context.span.End()
+	}
 }
 
 // SetOperationName sets or changes the operation name.
@@ -197,14 +294,22 @@ func (s *spanImpl) SetTag(key string, value interface{}) ot.Span {
 // LogEvent logs a event to the span.
 //
 // Deprecated: this method is deprecated.
-func (s *spanImpl) LogEvent(event string) {}
+func (s *spanImpl) LogEvent(event string) {
+	s.Log(ot.LogData{Event: event})
+}
 
 // LogEventWithPayload logs a event with a payload.
 //
 // Deprecated: this method is deprecated.
-func (s *spanImpl) LogEventWithPayload(event string, payload interface{}) {}
+func (s *spanImpl) LogEventWithPayload(event string, payload interface{}) {
+	s.Log(ot.LogData{Event: event, Payload: payload})
+}
 
 // Log logs the LogData.
 //
 // Deprecated: this method is deprecated.
-func (s *spanImpl) Log(data ot.LogData) {}
+func (s *spanImpl) Log(data ot.LogData) {
+	s.Lock()
+	defer s.Unlock()
+	s.logRecord(data.ToLogRecord())
+}
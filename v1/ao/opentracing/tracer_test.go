@@ -3,10 +3,14 @@
 package opentracing
 
 import (
+	"errors"
 	"testing"
+	"time"
 
+	g "github.com/appoptics/appoptics-apm-go/v1/ao/internal/graphtest"
 	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/reporter"
 	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -23,3 +27,91 @@ func TestSpanBaggageUnsampled(t *testing.T) {
 	childSpan := tr.StartSpan("op2", opentracing.ChildOf(sp.Context()))
 	assert.NotNil(t, childSpan)
 }
+
+func TestSpanTimestamps(t *testing.T) {
+	r := reporter.SetTestReporter() // set up test reporter
+	tr := NewTracer()
+	start := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	at := func(d time.Duration) int64 { return start.Add(d).UnixNano() / 1000 }
+
+	span := tr.StartSpan("op", opentracing.StartTime(start))
+	child := tr.StartSpan("child", opentracing.ChildOf(span.Context()),
+		opentracing.StartTime(start.Add(time.Second)))
+	child.FinishWithOptions(opentracing.FinishOptions{
+		FinishTime: start.Add(3 * time.Second),
+		LogRecords: []opentracing.LogRecord{
+			{Timestamp: start.Add(2 * time.Second), Fields: []log.Field{log.String("k", "v")}},
+			{Timestamp: start.Add(2500 * time.Millisecond), Fields: []log.Field{
+				log.String("event", "error"), log.String("message", "failed"), log.String("error.kind", "MyError")}},
+		},
+	})
+	span.LogFields(log.Error(errors.New("op error")))
+	span.LogEventWithPayload("payload event", "payload")
+	span.FinishWithOptions(opentracing.FinishOptions{FinishTime: start.Add(4 * time.Second)})
+
+	r.Close(8)
+	g.AssertGraph(t, r.EventBufs, 8, g.AssertNodeKVMap{
+		{"op", "entry", "", ""}: {Callback: func(n g.Node) {
+			assert.Equal(t, at(0), n.Map["Timestamp_u"])
+		}},
+		{"child", "entry", "", ""}: {Edges: g.Edges{{"op", "entry"}}, Callback: func(n g.Node) {
+			assert.Equal(t, at(time.Second), n.Map["Timestamp_u"])
+		}},
+		{"child", "info", "", ""}: {Edges: g.Edges{{"child", "entry"}}, Callback: func(n g.Node) {
+			assert.Equal(t, at(2*time.Second), n.Map["Timestamp_u"])
+			assert.Equal(t, "v", n.Map[otLogPrefix+"k"])
+		}},
+		{"child", "error", "", ""}: {Edges: g.Edges{{"child", "info"}}, Callback: func(n g.Node) {
+			assert.Equal(t, at(2500*time.Millisecond), n.Map["Timestamp_u"])
+			assert.Equal(t, "MyError", n.Map["ErrorClass"])
+			assert.Equal(t, "failed", n.Map["ErrorMsg"])
+		}},
+		{"child", "exit", "", ""}: {Edges: g.Edges{{"child", "error"}}, Callback: func(n g.Node) {
+			assert.Equal(t, at(3*time.Second), n.Map["Timestamp_u"])
+		}},
+		{"op", "error", "", ""}: {Edges: g.Edges{{"op", "entry"}}, Callback: func(n g.Node) {
+			assert.Equal(t, "error", n.Map["ErrorClass"])
+			assert.Equal(t, "op error", n.Map["ErrorMsg"])
+		}},
+		{"op", "info", "", ""}: {Edges: g.Edges{{"op", "error"}}, Callback: func(n g.Node) {
+			assert.Equal(t, "payload event", n.Map[otLogPrefix+"event"])
+			assert.Equal(t, "payload", n.Map[otLogPrefix+"payload"])
+		}},
+		{"op", "exit", "", ""}: {Edges: g.Edges{{"child", "exit"}, {"op", "info"}}, Callback: func(n g.Node) {
+			assert.Equal(t, at(4*time.Second), n.Map["Timestamp_u"])
+		}},
+	})
+}
+
+func TestSpanFollowsFrom(t *testing.T) {
+	r := reporter.SetTestReporter() // set up test reporter
+	tr := NewTracer()
+
+	span := tr.StartSpan("op")
+	produce := tr.StartSpan("produce", opentracing.ChildOf(span.Context()))
+	produce.Finish()
+	// follows from a finished span, continuing its trace
+	consume := tr.StartSpan("consume", opentracing.FollowsFrom(produce.Context()))
+	// a child of op, linked to the other spans it follows from
+	batch := tr.StartSpan("batch", opentracing.FollowsFrom(produce.Context()),
+		opentracing.ChildOf(span.Context()), opentracing.FollowsFrom(consume.Context()))
+	batch.Finish()
+	consume.Finish()
+	span.Finish()
+
+	r.Close(8)
+	g.AssertGraph(t, r.EventBufs, 8, g.AssertNodeMap{
+		{"op", "entry"}:      {},
+		{"produce", "entry"}: {Edges: g.Edges{{"op", "entry"}}},
+		{"produce", "exit"}:  {Edges: g.Edges{{"produce", "entry"}}},
+		{"consume", "entry"}: {Edges: g.Edges{{"produce", "entry"}}, Callback: func(n g.Node) {
+			assert.Equal(t, "FollowsFrom", n.Map["LinkType"])
+		}},
+		{"consume", "exit"}: {Edges: g.Edges{{"consume", "entry"}}},
+		{"batch", "entry"}: {Edges: g.Edges{{"produce", "entry"}, {"consume", "entry"}, {"op", "entry"}}, Callback: func(n g.Node) {
+			assert.Equal(t, "FollowsFrom", n.Map["LinkType"])
+		}},
+		{"batch", "exit"}: {Edges: g.Edges{{"batch", "entry"}}},
+		{"op", "exit"}:    {Edges: g.Edges{{"produce", "exit"}, {"batch", "exit"}, {"op", "entry"}}},
+	})
+}
@@ -0,0 +1,56 @@
+// Copyright (C) 2017 Librato, Inc. All rights reserved.
+
+package ao
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/config"
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/reporter"
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/utils"
+)
+
+// AgentStatus is a snapshot of the agent internals for self-diagnostics, e.g.,
+// to find out why there are no traces without turning on the DEBUG logs.
+type AgentStatus struct {
+	// The version of the agent
+	Version string `json:"version"`
+	// Whether the agent is disabled by APPOPTICS_DISABLED
+	Disabled bool `json:"disabled"`
+	// The reporter type, connections, queues and sampling setting
+	Reporter ReporterStatus `json:"reporter"`
+	// The current configuration, with the service key masked
+	Config map[string]interface{} `json:"config"`
+}
+
+// ReporterStatus is the status of the reporter which sends the events and
+// metrics to the collector.
+type ReporterStatus = reporter.Status
+
+// Status returns a snapshot of the agent internals.
+func Status() AgentStatus {
+	return AgentStatus{
+		Version:  utils.Version(),
+		Disabled: Disabled(),
+		Reporter: reporter.GetStatus(),
+		Config:   config.ToMaskedMap(),
+	}
+}
+
+// StatusHandler returns an http.Handler which responds with the agent status
+// in JSON. It's not registered anywhere by default, the application may serve
+// it on an internal port, e.g.,
+//
+//	http.Handle("/debug/appoptics", ao.StatusHandler())
+func StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := json.MarshalIndent(Status(), "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	})
+}
@@ -0,0 +1,44 @@
+// Copyright (C) 2017 Librato, Inc. All rights reserved.
+
+package ao
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/reporter"
+	"github.com/appoptics/appoptics-apm-go/v1/ao/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatus(t *testing.T) {
+	r := reporter.SetTestReporter()
+	defer r.Close(0)
+
+	st := Status()
+	assert.Equal(t, utils.Version(), st.Version)
+	assert.Equal(t, "test", st.Reporter.Type)
+	assert.True(t, st.Reporter.Ready)
+	require.NotNil(t, st.Reporter.Sampling)
+	assert.Equal(t, 1000000, st.Reporter.Sampling.Rate)
+	assert.Contains(t, st.Config, "ServiceKey")
+}
+
+func TestStatusHandler(t *testing.T) {
+	r := reporter.SetTestReporter()
+	defer r.Close(0)
+
+	rr := httptest.NewRecorder()
+	StatusHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/status", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+	var m map[string]interface{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &m))
+	assert.Equal(t, utils.Version(), m["version"])
+	assert.Equal(t, "test", m["reporter"].(map[string]interface{})["type"])
+	assert.Contains(t, m["config"], "CollectorHost")
+}
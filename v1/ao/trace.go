@@ -69,6 +69,7 @@ type KVMap map[string]interface{}
 type traceHTTPSpan struct {
 	span       reporter.HTTPSpanMessage
 	start      time.Time
+	end        time.Time // the time the trace ended, if its exit is delayed
 	controller string
 	action     string
 }
@@ -77,6 +78,7 @@ type aoTrace struct {
 	layerSpan
 	exitEvent reporter.Event
 	httpSpan  traceHTTPSpan
+	async     asyncSpans
 }
 
 func (t *aoTrace) aoContext() reporter.Context { return t.aoCtx }
@@ -90,10 +92,30 @@ func NewTrace(spanName string) Trace {
 
 // NewTraceWithOptions creates a new trace with the provided options
 func NewTraceWithOptions(spanName string, opts SpanOptions) Trace {
+	return NewTraceFromIDWithOptions(spanName, "", opts, nil)
+}
+
+// NewTraceFromIDWithOptions creates a new Trace like NewTraceFromID, with the
+// provided options, e.g. to continue a remote trace with links to other spans.
+func NewTraceFromIDWithOptions(spanName, mdStr string, opts SpanOptions, cb func() KVMap) Trace {
 	kvs := addKVsFromOpts(opts)
-	return NewTraceFromID(spanName, "", func() KVMap {
-		return fromKVs(kvs...)
+	t := NewTraceFromID(spanName, mdStr, func() KVMap {
+		var m KVMap
+		if cb != nil {
+			m = cb()
+		}
+		if m == nil {
+			m = make(KVMap)
+		}
+		for i := 0; i+1 < len(kvs); i += 2 {
+			m[kvs[i].(string)] = kvs[i+1]
+		}
+		return m
 	})
+	if !opts.StartTime.IsZero() {
+		t.SetStartTime(opts.StartTime)
+	}
+	return t
 }
 
 // NewTraceFromID creates a new Trace for reporting to AppOptics, provided an
@@ -135,10 +157,16 @@ func GetTransactionName(ctx context.Context) string {
 func (t *aoTrace) End(args ...interface{}) {
 	if t.ok() {
 		t.AddEndArgs(args...)
-		t.reportExit()
+		t.finish()
 	}
 }
 
+// EndAt reports the exit event for the span name that was used when calling
+// NewTrace() at the time provided, instead of the current time.
+func (t *aoTrace) EndAt(end time.Time, args ...interface{}) {
+	t.End(append(args, KeyTimestamp, end)...)
+}
+
 // EndCallback ends a Trace, reporting additional KV pairs returned by calling cb
 func (t *aoTrace) EndCallback(cb func() KVMap) {
 	if t.ok() {
@@ -149,7 +177,7 @@ func (t *aoTrace) EndCallback(cb func() KVMap) {
 			}
 			t.AddEndArgs(args...)
 		}
-		t.reportExit()
+		t.finish()
 	}
 }
 
@@ -193,7 +221,14 @@ func (t *aoTrace) reportExit() {
 
 		// if this is an HTTP trace, record a new span
 		if !t.httpSpan.start.IsZero() {
-			t.httpSpan.span.Duration = time.Now().Sub(t.httpSpan.start)
+			end := timestampFromKVs(t.endArgs)
+			if end.IsZero() {
+				end = t.httpSpan.end
+			}
+			if end.IsZero() {
+				end = time.Now()
+			}
+			t.httpSpan.span.Duration = end.Sub(t.httpSpan.start)
 			t.recordHTTPSpan()
 		}
 
@@ -212,6 +247,19 @@ func (t *aoTrace) reportExit() {
 	}
 }
 
+// timestampFromKVs returns the time provided by the KeyTimestamp KV, if any.
+func timestampFromKVs(args []interface{}) time.Time {
+	var ts time.Time
+	for i := 0; i+1 < len(args); i += 2 {
+		if k, ok := args[i].(string); ok && k == KeyTimestamp {
+			if v, ok := args[i+1].(time.Time); ok {
+				ts = v
+			}
+		}
+	}
+	return ts
+}
+
 // IsSampled indicates if the trace is sampled.
 func (t *aoTrace) IsSampled() bool { return t != nil && t.aoCtx.IsSampled() }
 
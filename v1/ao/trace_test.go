@@ -372,3 +372,74 @@ func TestTraceWithOptions(t *testing.T) {
 		{"testWithBacktrace", "exit"}: {Edges: g.Edges{{"testWithBacktrace", "entry"}}},
 	})
 }
+
+func TestTraceStartEndTime(t *testing.T) {
+	r := reporter.SetTestReporter()
+	start := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	at := func(d time.Duration) int64 { return start.Add(d).UnixNano() / 1000 }
+
+	tr := ao.NewTraceWithOptions("test", ao.SpanOptions{StartTime: start})
+	s := tr.BeginSpanWithOptions("L1", ao.SpanOptions{StartTime: start.Add(time.Second)})
+	s.BeginProfile("P1")
+	s.(ao.SpanWithEndAt).EndAt(start.Add(3 * time.Second))
+	tr.(ao.SpanWithEndAt).EndAt(start.Add(5*time.Second), "k", "v")
+
+	r.Close(7) // 6 events and a span message
+	assert.Len(t, r.SpanMessages, 1)
+	if m, ok := r.SpanMessages[0].(*reporter.HTTPSpanMessage); assert.True(t, ok) {
+		assert.Equal(t, 5*time.Second, m.Duration)
+	}
+	g.AssertGraph(t, r.EventBufs, 6, g.AssertNodeMap{
+		{"test", "entry"}: {Callback: func(n g.Node) {
+			assert.Equal(t, at(0), n.Map["Timestamp_u"])
+		}},
+		{"L1", "entry"}: {Edges: g.Edges{{"test", "entry"}}, Callback: func(n g.Node) {
+			assert.Equal(t, at(time.Second), n.Map["Timestamp_u"])
+		}},
+		{"", "profile_entry"}: {Edges: g.Edges{{"L1", "entry"}}},
+		// the profile ends at the same time as its span
+		{"", "profile_exit"}: {Edges: g.Edges{{"", "profile_entry"}}, Callback: func(n g.Node) {
+			assert.Equal(t, at(3*time.Second), n.Map["Timestamp_u"])
+		}},
+		{"L1", "exit"}: {Edges: g.Edges{{"", "profile_exit"}, {"L1", "entry"}}, Callback: func(n g.Node) {
+			assert.Equal(t, at(3*time.Second), n.Map["Timestamp_u"])
+		}},
+		{"test", "exit"}: {Edges: g.Edges{{"L1", "exit"}, {"test", "entry"}}, Callback: func(n g.Node) {
+			assert.Equal(t, at(5*time.Second), n.Map["Timestamp_u"])
+			assert.Equal(t, "v", n.Map["k"])
+		}},
+	})
+}
+
+func TestSpanLinks(t *testing.T) {
+	r := reporter.SetTestReporter()
+
+	tr := ao.NewTrace("fanIn")
+	ctx := ao.NewContext(context.Background(), tr)
+	p1, _ := ao.BeginSpan(ctx, "p1")
+	p2, _ := ao.BeginSpan(ctx, "p2")
+	links := []string{p1.MetadataString(), p2.MetadataString()}
+	p1.End()
+	p2.End()
+
+	// a link to another trace is reported without an edge
+	remote := "2B0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF0123456701"
+	s, _ := ao.BeginSpanWithOptions(ctx, "batch", ao.SpanOptions{Links: append(links, remote)})
+	s.End()
+	tr.End()
+
+	r.Close(8)
+	g.AssertGraph(t, r.EventBufs, 8, g.AssertNodeMap{
+		{"fanIn", "entry"}: {},
+		{"p1", "entry"}:    {Edges: g.Edges{{"fanIn", "entry"}}},
+		{"p1", "exit"}:     {Edges: g.Edges{{"p1", "entry"}}},
+		{"p2", "entry"}:    {Edges: g.Edges{{"fanIn", "entry"}}},
+		{"p2", "exit"}:     {Edges: g.Edges{{"p2", "entry"}}},
+		{"batch", "entry"}: {Edges: g.Edges{{"p1", "entry"}, {"p2", "entry"}, {"fanIn", "entry"}}, Callback: func(n g.Node) {
+			assert.Equal(t, "FollowsFrom", n.Map["LinkType"])
+			assert.Contains(t, n.Map, "Link")
+		}},
+		{"batch", "exit"}: {Edges: g.Edges{{"batch", "entry"}}},
+		{"fanIn", "exit"}: {Edges: g.Edges{{"p1", "exit"}, {"p2", "exit"}, {"batch", "exit"}, {"fanIn", "entry"}}},
+	})
+}
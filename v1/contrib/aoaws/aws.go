@@ -0,0 +1,131 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+// Package aoaws provides AppOptics instrumentation for the AWS SDK for Go.
+//
+//	sess := aoaws.WrapSession(session.Must(session.NewSession()))
+//	db := dynamodb.New(sess)
+//	out, err := db.GetItemWithContext(ctx, input)
+//
+// Each API call made with the context of a span, e.g. with the WithContext
+// methods of the service clients, is traced in a remote span, a child of that
+// span, which reports the service, operation, region, request ID, number of
+// retries and HTTP status of the call, and the table of DynamoDB calls or the
+// bucket and key of S3 calls.
+package aoaws
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+const (
+	protocol = "aws"
+
+	beginHandlerName = "appoptics.BeginSpan"
+	endHandlerName   = "appoptics.EndSpan"
+)
+
+// WrapSession adds the handlers tracing the API calls to the session, which
+// is returned. The clients created from the session afterwards are traced.
+func WrapSession(s *session.Session) *session.Session {
+	AddHandlers(&s.Handlers)
+	return s
+}
+
+// AddHandlers adds the handlers tracing the API calls to the handlers provided,
+// e.g. the Handlers of a session.Session or of a single service client.
+func AddHandlers(h *request.Handlers) {
+	h.Validate.PushFrontNamed(request.NamedHandler{Name: beginHandlerName, Fn: beginSpan})
+	h.Complete.PushBackNamed(request.NamedHandler{Name: endHandlerName, Fn: endSpan})
+}
+
+type spanKey struct{}
+
+// beginSpan begins the span of the API call, unless it's only presigned.
+func beginSpan(r *request.Request) {
+	if r.ExpireTime != 0 {
+		return
+	}
+	ctx := r.Context()
+	l := ao.BeginRPCSpan(ctx, r.ClientInfo.ServiceName, protocol, r.ClientInfo.ServiceName,
+		remoteHost(r), spanArgs(r)...)
+	r.SetContext(context.WithValue(ctx, spanKey{}, l))
+}
+
+// endSpan ends the span of the API call, reporting its error, if any.
+func endSpan(r *request.Request) {
+	l, ok := r.Context().Value(spanKey{}).(ao.Span)
+	if !ok {
+		return
+	}
+	l.Err(r.Error)
+	args := []interface{}{"RetryCount", r.RetryCount}
+	if r.RequestID != "" {
+		args = append(args, "AWSRequestID", r.RequestID)
+	}
+	if r.HTTPResponse != nil {
+		args = append(args, "HTTPStatus", r.HTTPResponse.StatusCode)
+	}
+	l.End(args...)
+}
+
+// remoteHost returns the host of the endpoint the API call is sent to.
+func remoteHost(r *request.Request) string {
+	if r.HTTPRequest != nil && r.HTTPRequest.URL != nil {
+		return r.HTTPRequest.URL.Host
+	}
+	if u, err := url.Parse(r.ClientInfo.Endpoint); err == nil {
+		return u.Host
+	}
+	return ""
+}
+
+// spanArgs returns the KVs reported when the span of the API call begins.
+func spanArgs(r *request.Request) []interface{} {
+	args := []interface{}{
+		"RemoteAction", r.Operation.Name,
+		"AWSService", r.ClientInfo.ServiceName,
+		"AWSOperation", r.Operation.Name,
+		"AWSRegion", aws.StringValue(r.Config.Region),
+	}
+	switch r.ClientInfo.ServiceName {
+	case "dynamodb":
+		if table := paramValue(r.Params, "TableName"); table != "" {
+			args = append(args, "DynamoDBTable", table)
+		}
+	case "s3":
+		if bucket := paramValue(r.Params, "Bucket"); bucket != "" {
+			args = append(args, "S3Bucket", bucket)
+		}
+		if key := paramValue(r.Params, "Key"); key != "" {
+			args = append(args, "S3Key", key)
+		}
+	}
+	return args
+}
+
+// paramValue returns the value of the input parameter at the path provided,
+// or an empty string if it isn't set.
+func paramValue(params interface{}, path string) string {
+	if params == nil {
+		return ""
+	}
+	values, err := awsutil.ValuesAtPath(params, path)
+	if err != nil || len(values) == 0 || values[0] == nil {
+		return ""
+	}
+	switch v := values[0].(type) {
+	case *string:
+		return aws.StringValue(v)
+	case string:
+		return v
+	}
+	return fmt.Sprint(values[0])
+}
@@ -0,0 +1,123 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package aoaws
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	"github.com/appoptics/appoptics-apm-go/v1/ao/aotest"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSession(t *testing.T, url string) *session.Session {
+	s, err := session.NewSession(&aws.Config{
+		Endpoint:         aws.String(url),
+		Region:           aws.String("us-east-1"),
+		Credentials:      credentials.NewStaticCredentials("id", "secret", ""),
+		DisableSSL:       aws.Bool(true),
+		S3ForcePathStyle: aws.Bool(true),
+		MaxRetries:       aws.Int(1),
+	})
+	assert.NoError(t, err)
+	return WrapSession(s)
+}
+
+func TestDynamoDB(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-amzn-RequestId", "req-1")
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.Write([]byte(`{"Item":{"id":{"S":"1"}}}`))
+	}))
+	defer srv.Close()
+
+	rec := aotest.Record()
+	ctx := ao.NewContext(context.Background(), ao.NewTrace("test"))
+	out, err := dynamodb.New(testSession(t, srv.URL)).GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String("users"),
+		Key:       map[string]*dynamodb.AttributeValue{"id": {S: aws.String("1")}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "1", aws.StringValue(out.Item["id"].S))
+	ao.EndTrace(ctx)
+
+	traces := rec.Stop(4)
+	root := aotest.AssertSpan(t, traces, "test")
+	require.Len(t, root.Children, 1)
+	s := root.Children[0]
+	assert.Equal(t, "dynamodb", s.Name)
+	aotest.AssertKV(t, s, "Spec", "rsc")
+	aotest.AssertKV(t, s, "RemoteProtocol", protocol)
+	aotest.AssertKV(t, s, "RemoteHost", strings.TrimPrefix(srv.URL, "http://"))
+	aotest.AssertKV(t, s, "RemoteController", "dynamodb")
+	aotest.AssertKV(t, s, "RemoteAction", "GetItem")
+	aotest.AssertKV(t, s, "AWSService", "dynamodb")
+	aotest.AssertKV(t, s, "AWSOperation", "GetItem")
+	aotest.AssertKV(t, s, "AWSRegion", "us-east-1")
+	aotest.AssertKV(t, s, "DynamoDBTable", "users")
+	aotest.AssertKV(t, s, "AWSRequestID", "req-1")
+	aotest.AssertKV(t, s, "HTTPStatus", http.StatusOK)
+	aotest.AssertKV(t, s, "RetryCount", 0)
+	assert.Empty(t, s.Errors)
+}
+
+func TestS3Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-amz-request-id", "req-2")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`<Error><Code>NoSuchKey</Code><Message>not found</Message></Error>`))
+	}))
+	defer srv.Close()
+
+	rec := aotest.Record()
+	ctx := ao.NewContext(context.Background(), ao.NewTrace("test"))
+	_, err := s3.New(testSession(t, srv.URL)).GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String("bucket"),
+		Key:    aws.String("a/b.txt"),
+	})
+	assert.Error(t, err)
+	ao.EndTrace(ctx)
+
+	traces := rec.Stop(5)
+	s := aotest.AssertSpan(t, traces, "s3")
+	aotest.AssertKV(t, s, "RemoteHost", strings.TrimPrefix(srv.URL, "http://"))
+	aotest.AssertKV(t, s, "AWSOperation", "GetObject")
+	aotest.AssertKV(t, s, "S3Bucket", "bucket")
+	aotest.AssertKV(t, s, "S3Key", "a/b.txt")
+	aotest.AssertKV(t, s, "AWSRequestID", "req-2")
+	aotest.AssertKV(t, s, "HTTPStatus", http.StatusNotFound)
+	aotest.AssertError(t, s, "error", err.Error())
+}
+
+func TestPresign(t *testing.T) {
+	req, _ := s3.New(testSession(t, "http://localhost:0")).GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String("bucket"),
+		Key:    aws.String("key"),
+	})
+	ctx := ao.NewContext(context.Background(), ao.NewTrace("test"))
+	req.SetContext(ctx)
+	_, err := req.Presign(time.Minute)
+	assert.NoError(t, err)
+	// presigning a request doesn't begin its span
+	assert.Nil(t, req.Context().Value(spanKey{}))
+	ao.EndTrace(ctx)
+}
+
+func TestParamValue(t *testing.T) {
+	input := &s3.GetObjectInput{Bucket: aws.String("bucket")}
+	assert.Equal(t, "bucket", paramValue(input, "Bucket"))
+	assert.Equal(t, "", paramValue(input, "Key"))
+	assert.Equal(t, "", paramValue(nil, "Key"))
+	assert.Equal(t, "users", paramValue(&dynamodb.QueryInput{TableName: aws.String("users")}, "TableName"))
+}
@@ -0,0 +1,95 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+// Package aochi provides the AppOptics tracing middleware for the chi router.
+//
+//	r := chi.NewRouter()
+//	r.Use(aochi.Middleware)
+//
+// Each request is traced with a transaction named after the matched route,
+// e.g. "GET /users/{id}", unless a custom transaction name is set by the handler.
+// The trace is bound to the context of the http.Request and can be retrieved
+// by the handlers with ao.TraceFromContext(r.Context()).
+package aochi
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	"github.com/appoptics/appoptics-apm-go/v1/contrib/internal/router"
+	"github.com/go-chi/chi"
+)
+
+const spanName = "chi"
+
+// Middleware traces the requests with AppOptics. If the request headers describe
+// a distributed trace, the trace will be continued.
+func Middleware(next http.Handler) http.Handler {
+	return MiddlewareWithOptions()(next)
+}
+
+// MiddlewareWithOptions returns a middleware which traces the requests with
+// AppOptics, creating the traces with the provided options.
+func MiddlewareWithOptions(opts ...ao.SpanOpt) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if ao.Disabled() {
+			return next
+		}
+		handlers := &endpoints{}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ao.Closed() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			t, w, r := ao.TraceFromHTTPRequestResponse(spanName, w, r, opts...)
+			var endArgs []interface{}
+			defer func() {
+				t.End(endArgs...)
+			}()
+			defer router.ReportPanic(t, w.(*ao.HTTPResponseWriter))
+			// the route pattern is complete only after the request is routed
+			defer func() {
+				if rctx := chi.RouteContext(r.Context()); rctx != nil {
+					pattern := rctx.RoutePattern()
+					router.SetTransactionName(t, r.Method, pattern)
+					endArgs = handlers.endArgs(rctx.Routes, r.Method, pattern)
+				}
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// endpoints caches the Controller and Action KVs of the handlers of the routes,
+// as chi does not expose the handler of the matched route to the middleware.
+type endpoints struct {
+	sync.RWMutex
+	args map[string][]interface{}
+}
+
+func (e *endpoints) endArgs(routes chi.Routes, method, pattern string) []interface{} {
+	key := router.TransactionName(method, pattern)
+	e.RLock()
+	args, ok := e.args[key]
+	e.RUnlock()
+	if ok || routes == nil {
+		return args
+	}
+
+	e.Lock()
+	defer e.Unlock()
+	if e.args == nil {
+		e.args = make(map[string][]interface{})
+	}
+	chi.Walk(routes, func(method string, route string, handler http.Handler,
+		middlewares ...func(http.Handler) http.Handler) error {
+		e.args[router.TransactionName(method, route)] = router.EndArgs(router.FuncName(handler))
+		return nil
+	})
+	// remember the routes not found as well to avoid walking the tree again
+	args = e.args[key]
+	e.args[key] = args
+	return args
+}
@@ -0,0 +1,93 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package aochi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	"github.com/appoptics/appoptics-apm-go/v1/ao/aotest"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serve(h http.Handler, method, path string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func getUser(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusTeapot)
+	w.Write([]byte("user " + chi.URLParam(r, "id")))
+}
+
+func TestMiddleware(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/users/{id}", getUser)
+	r.Route("/api", func(r chi.Router) {
+		r.Get("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+			assert.NotNil(t, ao.TraceFromContext(r.Context()))
+		})
+	})
+
+	rec := aotest.Record()
+	w := serve(r, "GET", "/users/1")
+	assert.Equal(t, http.StatusTeapot, w.Code)
+	assert.Equal(t, "user 1", w.Body.String())
+
+	w = serve(r, "GET", "/api/items/1")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(r, "GET", "/notfound")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	traces := rec.Stop(6)
+	require.Len(t, traces, 3)
+	user := traces[0].Root
+	assert.Equal(t, spanName, user.Name)
+	aotest.AssertKV(t, user, "TransactionName", "GET /users/{id}")
+	aotest.AssertKV(t, user, "Controller", "aochi")
+	aotest.AssertKV(t, user, "Action", "getUser")
+	aotest.AssertKV(t, user, "Status", http.StatusTeapot)
+	aotest.AssertKV(t, traces[1].Root, "TransactionName", "GET /api/items/{id}")
+	aotest.AssertKV(t, traces[2].Root, "Status", http.StatusNotFound)
+}
+
+func TestMiddlewarePanic(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("panicking!")
+	})
+
+	rec := aotest.Record()
+	assert.PanicsWithValue(t, "panicking!", func() { serve(r, "GET", "/panic") })
+	traces := rec.Stop(3)
+
+	s := aotest.AssertSpan(t, traces, spanName)
+	aotest.AssertError(t, s, "panic", "panicking!")
+	aotest.AssertKV(t, s, "Status", http.StatusInternalServerError)
+	aotest.AssertKV(t, s, "TransactionName", "GET /panic")
+}
+
+func TestEndpoints(t *testing.T) {
+	r := chi.NewRouter()
+	r.Get("/users/{id}", getUser)
+	r.Route("/api", func(r chi.Router) {
+		r.Post("/users/{id}", getUser)
+	})
+
+	e := &endpoints{}
+	assert.Equal(t, []interface{}{"Controller", "aochi", "Action", "getUser"},
+		e.endArgs(r, "GET", "/users/{id}"))
+	assert.Equal(t, []interface{}{"Controller", "aochi", "Action", "getUser"},
+		e.endArgs(r, "POST", "/api/users/{id}"))
+	assert.Nil(t, e.endArgs(r, "GET", "/api/users/{id}"))
+	assert.Nil(t, e.endArgs(nil, "GET", "/notfound"))
+}
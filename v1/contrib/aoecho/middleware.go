@@ -0,0 +1,99 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+// Package aoecho provides the AppOptics tracing middleware for the Echo web framework.
+//
+//	e := echo.New()
+//	e.Use(aoecho.Middleware())
+//
+// Each request is traced with a transaction named after the matched route,
+// e.g. "GET /users/:id", unless a custom transaction name is set by the handler.
+package aoecho
+
+import (
+	"sync"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	"github.com/appoptics/appoptics-apm-go/v1/contrib/internal/router"
+	"github.com/labstack/echo"
+)
+
+const (
+	// contextKey is the key of the trace bound to the echo.Context
+	contextKey = "github.com/appoptics/appoptics-apm-go/v1/contrib/aoecho.Trace"
+	spanName   = "echo"
+)
+
+// Middleware returns an echo.MiddlewareFunc which traces the requests with AppOptics.
+// If the request headers describe a distributed trace, the trace will be continued.
+// The trace is bound to both the echo.Context and the context of its http.Request.
+func Middleware(opts ...ao.SpanOpt) echo.MiddlewareFunc {
+	handlers := &endpoints{}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if ao.Disabled() || ao.Closed() {
+				return next(c)
+			}
+
+			resp := c.Response()
+			t, w, r := ao.TraceFromHTTPRequestResponse(spanName, resp.Writer, c.Request(), opts...)
+			aoWriter := w.(*ao.HTTPResponseWriter)
+			resp.Writer = aoWriter
+			c.SetRequest(r)
+			c.Set(contextKey, t)
+
+			defer func() {
+				router.SetTransactionName(t, r.Method, c.Path())
+				t.End(handlers.endArgs(c.Echo(), r.Method, c.Path())...)
+			}()
+			defer router.ReportPanic(t, aoWriter)
+
+			err := next(c)
+			if err != nil {
+				t.Err(err)
+				// let the error handler write the response so its status is observed
+				c.Error(err)
+			}
+			return err
+		}
+	}
+}
+
+// TraceFromContext returns the trace bound to the echo.Context by the middleware, if any.
+func TraceFromContext(c echo.Context) ao.Trace {
+	if t, ok := c.Get(contextKey).(ao.Trace); ok {
+		return t
+	}
+	return ao.NewNullTrace()
+}
+
+// endpoints caches the Controller and Action KVs of the handlers of the routes.
+// echo wraps the handler of each route, so the name of the handler is looked up
+// in the routes instead.
+type endpoints struct {
+	sync.RWMutex
+	args map[string][]interface{}
+}
+
+func (e *endpoints) endArgs(app *echo.Echo, method, path string) []interface{} {
+	key := router.TransactionName(method, path)
+	e.RLock()
+	args, ok := e.args[key]
+	e.RUnlock()
+	if ok || path == "" {
+		return args
+	}
+
+	for _, rt := range app.Routes() {
+		if rt.Method == method && rt.Path == path {
+			args = router.EndArgs(rt.Name)
+			e.Lock()
+			if e.args == nil {
+				e.args = make(map[string][]interface{})
+			}
+			e.args[key] = args
+			e.Unlock()
+			break
+		}
+	}
+	return args
+}
@@ -0,0 +1,118 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package aoecho
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	"github.com/appoptics/appoptics-apm-go/v1/ao/aotest"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serve(e *echo.Echo, method, path string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, nil)
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func getUser(c echo.Context) error {
+	return c.String(http.StatusTeapot, "user "+c.Param("id"))
+}
+
+func TestMiddleware(t *testing.T) {
+	e := echo.New()
+	e.Use(Middleware())
+	e.GET("/users/:id", getUser)
+	e.GET("/items/:id", func(c echo.Context) error {
+		assert.NotNil(t, TraceFromContext(c))
+		assert.NotNil(t, ao.TraceFromContext(c.Request().Context()))
+		return c.NoContent(http.StatusOK)
+	})
+	e.GET("/error", func(c echo.Context) error {
+		return errors.New("handler error")
+	})
+	e.GET("/http_error", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusForbidden)
+	})
+
+	rec := aotest.Record()
+	w := serve(e, "GET", "/users/1")
+	assert.Equal(t, http.StatusTeapot, w.Code)
+	assert.Equal(t, "user 1", w.Body.String())
+
+	w = serve(e, "GET", "/items/1")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(e, "GET", "/error")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	w = serve(e, "GET", "/http_error")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = serve(e, "GET", "/notfound")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	traces := rec.Stop(13)
+	require.Len(t, traces, 5)
+	user := traces[0].Root
+	assert.Equal(t, spanName, user.Name)
+	aotest.AssertKV(t, user, "TransactionName", "GET /users/:id")
+	aotest.AssertKV(t, user, "Controller", "aoecho")
+	aotest.AssertKV(t, user, "Action", "getUser")
+	aotest.AssertKV(t, user, "Status", http.StatusTeapot)
+	assert.Empty(t, user.Errors)
+	aotest.AssertKV(t, traces[1].Root, "TransactionName", "GET /items/:id")
+
+	// the errors returned by the handlers are reported
+	handlerErr := traces[2].Root
+	aotest.AssertKV(t, handlerErr, "TransactionName", "GET /error")
+	aotest.AssertKV(t, handlerErr, "Status", http.StatusInternalServerError)
+	aotest.AssertError(t, handlerErr, "error", "handler error")
+	aotest.AssertKV(t, traces[3].Root, "Status", http.StatusForbidden)
+	aotest.AssertError(t, traces[3].Root, "error", echo.NewHTTPError(http.StatusForbidden).Error())
+	aotest.AssertKV(t, traces[4].Root, "Status", http.StatusNotFound)
+}
+
+func TestMiddlewarePanic(t *testing.T) {
+	e := echo.New()
+	e.Use(Middleware())
+	e.GET("/panic", func(c echo.Context) error {
+		panic("panicking!")
+	})
+
+	rec := aotest.Record()
+	assert.PanicsWithValue(t, "panicking!", func() { serve(e, "GET", "/panic") })
+	traces := rec.Stop(3)
+
+	s := aotest.AssertSpan(t, traces, spanName)
+	aotest.AssertError(t, s, "panic", "panicking!")
+	aotest.AssertKV(t, s, "Status", http.StatusInternalServerError)
+	aotest.AssertKV(t, s, "TransactionName", "GET /panic")
+}
+
+func TestTraceFromContext(t *testing.T) {
+	e := echo.New()
+	c := e.NewContext(httptest.NewRequest("GET", "/", nil), httptest.NewRecorder())
+	assert.Equal(t, ao.NewNullTrace(), TraceFromContext(c))
+}
+
+func TestEndpoints(t *testing.T) {
+	e := echo.New()
+	e.GET("/users/:id", getUser)
+	e.Group("/api").POST("/users/:id", getUser)
+
+	h := &endpoints{}
+	assert.Equal(t, []interface{}{"Controller", "aoecho", "Action", "getUser"},
+		h.endArgs(e, "GET", "/users/:id"))
+	assert.Equal(t, []interface{}{"Controller", "aoecho", "Action", "getUser"},
+		h.endArgs(e, "POST", "/api/users/:id"))
+	assert.Nil(t, h.endArgs(e, "GET", "/api/users/:id"))
+	assert.Nil(t, h.endArgs(e, "GET", ""))
+}
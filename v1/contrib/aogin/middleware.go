@@ -0,0 +1,87 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+// Package aogin provides the AppOptics tracing middleware for the Gin web framework.
+//
+//	router := gin.Default()
+//	router.Use(aogin.Middleware())
+//
+// Each request is traced with a transaction named after the matched route,
+// e.g. "GET /users/:id", unless a custom transaction name is set by the handler.
+package aogin
+
+import (
+	"net/http"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	"github.com/appoptics/appoptics-apm-go/v1/contrib/internal/router"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// contextKey is the key of the trace bound to the gin.Context
+	contextKey = "github.com/appoptics/appoptics-apm-go/v1/contrib/aogin.Trace"
+	spanName   = "gin"
+)
+
+// Middleware returns a gin.HandlerFunc which traces the requests with AppOptics.
+// If the request headers describe a distributed trace, the trace will be continued.
+// The trace is bound to both the gin.Context and the context of its http.Request.
+func Middleware(opts ...ao.SpanOpt) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ao.Disabled() || ao.Closed() {
+			c.Next()
+			return
+		}
+
+		t, w, r := ao.TraceFromHTTPRequestResponse(spanName, c.Writer, c.Request, opts...)
+		aoWriter := w.(*ao.HTTPResponseWriter)
+		c.Writer = &responseWriter{ResponseWriter: c.Writer, aoWriter: aoWriter}
+		c.Request = r
+		c.Set(contextKey, t)
+
+		defer func() {
+			router.SetTransactionName(t, c.Request.Method, c.FullPath())
+			t.End(router.EndArgs(c.HandlerName())...)
+		}()
+		defer router.ReportPanic(t, aoWriter)
+
+		// Pass to the next handler
+		c.Next()
+
+		if !aoWriter.WroteHeader {
+			aoWriter.StatusCode = c.Writer.Status()
+		}
+		for _, err := range c.Errors {
+			t.Err(err.Err)
+		}
+	}
+}
+
+// TraceFromContext returns the trace bound to the gin.Context by the middleware, if any.
+func TraceFromContext(c *gin.Context) ao.Trace {
+	if t, ok := c.Get(contextKey); ok {
+		if tr, ok := t.(ao.Trace); ok {
+			return tr
+		}
+	}
+	return ao.NewNullTrace()
+}
+
+// responseWriter satisfies the gin.ResponseWriter interface
+type responseWriter struct {
+	// handles all other gin.ResponseWriter methods
+	gin.ResponseWriter
+	// handles Write, WriteString, WriteHeader, Header (by calling wrapped gin writer)
+	aoWriter *ao.HTTPResponseWriter
+}
+
+func (w *responseWriter) Header() http.Header               { return w.aoWriter.Header() }
+func (w *responseWriter) Write(p []byte) (int, error)       { return w.aoWriter.Write(p) }
+func (w *responseWriter) WriteString(s string) (int, error) { return w.aoWriter.Write([]byte(s)) }
+func (w *responseWriter) WriteHeader(status int)            { w.aoWriter.WriteHeader(status) }
+func (w *responseWriter) WriteHeaderNow() {
+	if !w.aoWriter.WroteHeader {
+		w.aoWriter.WriteHeader(w.aoWriter.StatusCode)
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
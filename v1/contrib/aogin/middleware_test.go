@@ -0,0 +1,102 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package aogin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	"github.com/appoptics/appoptics-apm-go/v1/ao/aotest"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func serve(r *gin.Engine, method, path string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func getUser(c *gin.Context) {
+	c.String(http.StatusTeapot, "user %s", c.Param("id"))
+}
+
+func TestMiddleware(t *testing.T) {
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/users/:id", getUser)
+	r.GET("/items/:id", func(c *gin.Context) {
+		assert.NotNil(t, TraceFromContext(c))
+		assert.NotNil(t, ao.TraceFromContext(c.Request.Context()))
+	})
+	r.GET("/status", func(c *gin.Context) {
+		c.AbortWithStatus(http.StatusForbidden)
+	})
+
+	rec := aotest.Record()
+	w := serve(r, "GET", "/users/1")
+	assert.Equal(t, http.StatusTeapot, w.Code)
+	assert.Equal(t, "user 1", w.Body.String())
+
+	w = serve(r, "GET", "/items/1")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(r, "GET", "/status")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = serve(r, "GET", "/notfound")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	traces := rec.Stop(8)
+	require.Len(t, traces, 4)
+	user := traces[0].Root
+	assert.Equal(t, spanName, user.Name)
+	aotest.AssertKV(t, user, "TransactionName", "GET /users/:id")
+	aotest.AssertKV(t, user, "Controller", "aogin")
+	aotest.AssertKV(t, user, "Action", "getUser")
+	aotest.AssertKV(t, user, "Status", http.StatusTeapot)
+	aotest.AssertKV(t, traces[1].Root, "TransactionName", "GET /items/:id")
+	aotest.AssertKV(t, traces[2].Root, "TransactionName", "GET /status")
+	aotest.AssertKV(t, traces[2].Root, "Status", http.StatusForbidden)
+	aotest.AssertKV(t, traces[3].Root, "Status", http.StatusNotFound)
+}
+
+func TestMiddlewarePanic(t *testing.T) {
+	rec := aotest.Record()
+	r := gin.New()
+	r.Use(gin.Recovery(), Middleware())
+	r.GET("/panic", func(c *gin.Context) {
+		panic("panicking!")
+	})
+
+	w := serve(r, "GET", "/panic")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	r = gin.New()
+	r.Use(Middleware())
+	r.GET("/panic", func(c *gin.Context) {
+		panic("panicking!")
+	})
+	assert.PanicsWithValue(t, "panicking!", func() { serve(r, "GET", "/panic") })
+
+	traces := rec.Stop(6)
+	require.Len(t, traces, 2)
+	for _, tr := range traces {
+		aotest.AssertError(t, tr.Root, "panic", "panicking!")
+		aotest.AssertKV(t, tr.Root, "Status", http.StatusInternalServerError)
+		aotest.AssertKV(t, tr.Root, "TransactionName", "GET /panic")
+	}
+}
+
+func TestTraceFromContext(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	assert.Equal(t, ao.NewNullTrace(), TraceFromContext(c))
+}
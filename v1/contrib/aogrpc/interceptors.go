@@ -14,7 +14,21 @@ import (
 
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// xtraceKey is the gRPC metadata key of the X-Trace metadata. The keys of gRPC
+// metadata are always lowercase.
+var xtraceKey = strings.ToLower(ao.HTTPHeaderName)
+
+// The types of the gRPC methods reported by the spans
+const (
+	rpcTypeUnary        = "unary"
+	rpcTypeClientStream = "client_stream"
+	rpcTypeServerStream = "server_stream"
+	rpcTypeBidiStream   = "bidi_stream"
 )
 
 func actionFromMethod(method string) string {
@@ -23,6 +37,79 @@ func actionFromMethod(method string) string {
 	return mParts[len(mParts)-1]
 }
 
+// serviceFromMethod returns the service part of a full gRPC method name, e.g.
+// "pkg.Service" of "/pkg.Service/Method".
+func serviceFromMethod(method string) string {
+	mParts := strings.Split(strings.TrimPrefix(method, "/"), "/")
+	if len(mParts) < 2 {
+		return ""
+	}
+	return mParts[len(mParts)-2]
+}
+
+func streamType(clientStreams, serverStreams bool) string {
+	switch {
+	case clientStreams && serverStreams:
+		return rpcTypeBidiStream
+	case clientStreams:
+		return rpcTypeClientStream
+	case serverStreams:
+		return rpcTypeServerStream
+	default:
+		return rpcTypeUnary
+	}
+}
+
+// xtraceFromMD returns the X-Trace metadata in the gRPC metadata, if any.
+func xtraceFromMD(md metadata.MD) string {
+	if xt, ok := md[xtraceKey]; ok && len(xt) > 0 {
+		return xt[0]
+	} else if xt, ok = md[ao.HTTPHeaderName]; ok && len(xt) > 0 {
+		return xt[0]
+	}
+	return ""
+}
+
+// statusCode returns the gRPC status code of the error returned by an RPC. The
+// io.EOF which marks the end of a stream is not an error.
+func statusCode(err error) codes.Code {
+	if err == nil || err == io.EOF {
+		return codes.OK
+	}
+	return status.Convert(err).Code()
+}
+
+// httpStatusFromCode maps a gRPC status code to the HTTP status code used to
+// categorize the service metrics, following the mapping of grpc-gateway.
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return 200
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return 400
+	case codes.DeadlineExceeded:
+		return 504
+	case codes.NotFound:
+		return 404
+	case codes.AlreadyExists, codes.Aborted:
+		return 409
+	case codes.PermissionDenied:
+		return 403
+	case codes.Unauthenticated:
+		return 401
+	case codes.ResourceExhausted:
+		return 429
+	case codes.Unimplemented:
+		return 501
+	case codes.Unavailable:
+		return 503
+	default: // Unknown, Internal, DataLoss
+		return 500
+	}
+}
+
 // StackTracer is a copy of the stackTracer interface of pkg/errors.
 //
 // This may be fragile as stackTracer is not imported, just try our best though.
@@ -65,58 +152,80 @@ func getTopFramePkg(st StackTracer) (string, error) {
 	return fp.Base(fp.Dir(frames[1])), nil
 }
 
-func tracingContext(ctx context.Context, serverName string, methodName string, statusCode *int) (context.Context, ao.Trace) {
-
-	action := actionFromMethod(methodName)
-
+func tracingContext(ctx context.Context, serverName string, methodName string, rpcType string,
+	o *options) (context.Context, ao.Trace) {
 	xtID := ""
 	md, ok := metadata.FromIncomingContext(ctx)
 	if ok {
-		if xt, ok := md[ao.HTTPHeaderName]; ok {
-			xtID = xt[0]
-		} else if xt, ok = md[strings.ToLower(ao.HTTPHeaderName)]; ok {
-			xtID = xt[0]
-		}
+		xtID = xtraceFromMD(md)
 	}
 
 	t := ao.NewTraceFromID(serverName, xtID, func() ao.KVMap {
-		return ao.KVMap{
-			"Method":     "POST",
-			"Controller": serverName,
-			"Action":     action,
+		kvs := ao.KVMap{
 			"URL":        methodName,
-			"Status":     statusCode,
+			"GRPCMethod": methodName,
+			"GRPCType":   rpcType,
 		}
+		mdKVs := o.metadataKVs(md)
+		for i := 0; i+1 < len(mdKVs); i += 2 {
+			kvs[mdKVs[i].(string)] = mdKVs[i+1]
+		}
+		return kvs
 	})
+	// gRPC requests are HTTP/2 POST requests
 	t.SetMethod("POST")
-	t.SetTransactionName(serverName + "." + action)
+	t.SetPath(methodName)
 	t.SetStartTime(time.Now())
+	// name the transaction after the service and method, e.g. "pkg.Service.Method",
+	// unless a custom transaction name is set by the handler.
+	t.AddEndArgs("Controller", serviceFromMethod(methodName), "Action", actionFromMethod(methodName))
 
 	return ao.NewContext(ctx, t), t
 }
 
+// endTrace reports the gRPC status of the RPC and ends the trace.
+func endTrace(t ao.Trace, err error) {
+	code := statusCode(err)
+	if code != codes.OK {
+		t.Error(getErrClass(err), err.Error())
+	}
+	httpStatus := httpStatusFromCode(code)
+	t.SetStatus(httpStatus)
+	t.End("Status", httpStatus, "GRPCStatus", code.String())
+}
+
 // UnaryServerInterceptor returns an interceptor that traces gRPC unary server RPCs using AppOptics.
-// If the client is using UnaryClientInterceptor, the distributed trace's context will be read from the client.
-func UnaryServerInterceptor(serverName string) grpc.UnaryServerInterceptor {
+// If the client is using UnaryClientInterceptor, the distributed trace's context will be read from the client,
+// and the exit metadata of the server's trace is sent back to the client in the response headers.
+func UnaryServerInterceptor(serverName string, opts ...Option) grpc.UnaryServerInterceptor {
+	o := newOptions(opts...)
 	return func(
 		ctx context.Context,
 		req interface{},
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler,
-	) (interface{}, error) {
-		var err error
-		var resp interface{}
-		var statusCode = 200
+	) (resp interface{}, err error) {
+		if o.skipped(info.FullMethod) {
+			return handler(ctx, req)
+		}
 		var t ao.Trace
-		ctx, t = tracingContext(ctx, serverName, info.FullMethod, &statusCode)
+		ctx, t = tracingContext(ctx, serverName, info.FullMethod, rpcTypeUnary, o)
 		defer func() {
-			t.SetStatus(statusCode)
-			ao.EndTrace(ctx)
+			endTrace(t, err)
 		}()
+		if t.IsReporting() {
+			grpc.SetHeader(ctx, metadata.Pairs(xtraceKey, t.ExitMetadata()))
+		}
+		if o.messageSizes {
+			if size, ok := messageSize(req); ok {
+				t.AddEndArgs(keyRequestSize, size)
+			}
+		}
 		resp, err = handler(ctx, req)
-		if err != nil {
-			statusCode = 500
-			ao.Error(ctx, getErrClass(err), err.Error())
+		if o.messageSizes && err == nil {
+			if size, ok := messageSize(resp); ok {
+				t.AddEndArgs(keyResponseSize, size)
+			}
 		}
 		return resp, err
 	}
@@ -139,29 +248,113 @@ func wrapServerStream(stream grpc.ServerStream) *wrappedServerStream {
 	return &wrappedServerStream{ServerStream: stream, WrappedContext: stream.Context()}
 }
 
+// messageCounter counts the messages sent and received by a stream and their
+// sizes, and reports an info event for each message if configured to.
+type messageCounter struct {
+	opts               *options
+	span               ao.Span
+	mu                 sync.Mutex
+	sent, received     int
+	sentSize, recvSize int
+}
+
+// message records a message sent or received by the stream.
+func (c *messageCounter) message(m interface{}, msgType string) {
+	size, hasSize := 0, false
+	if c.opts.messageSizes {
+		size, hasSize = messageSize(m)
+	}
+	c.mu.Lock()
+	var id int
+	if msgType == messageSent {
+		c.sent++
+		c.sentSize += size
+		id = c.sent
+	} else {
+		c.received++
+		c.recvSize += size
+		id = c.received
+	}
+	c.mu.Unlock()
+
+	if c.opts.messageEvents {
+		args := []interface{}{keyMessageType, msgType, keyMessageID, id}
+		if hasSize {
+			args = append(args, keyMessageSize, size)
+		}
+		c.span.Info(args...)
+	}
+}
+
+// endArgs returns the KVs of the number of request and response messages
+// and their total sizes. The requests are the messages received by a server
+// and sent by a client.
+func (c *messageCounter) endArgs(server bool) []interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	requests, responses := c.sent, c.received
+	requestSize, responseSize := c.sentSize, c.recvSize
+	if server {
+		requests, responses = responses, requests
+		requestSize, responseSize = responseSize, requestSize
+	}
+	args := []interface{}{keyRequestMessages, requests, keyResponseMessages, responses}
+	if c.opts.messageSizes {
+		args = append(args, keyRequestSize, requestSize, keyResponseSize, responseSize)
+	}
+	return args
+}
+
+// tracedServerStream counts the messages of a server stream
+type tracedServerStream struct {
+	*wrappedServerStream
+	counter *messageCounter
+}
+
+func (s *tracedServerStream) SendMsg(m interface{}) error {
+	err := s.wrappedServerStream.SendMsg(m)
+	if err == nil {
+		s.counter.message(m, messageSent)
+	}
+	return err
+}
+
+func (s *tracedServerStream) RecvMsg(m interface{}) error {
+	err := s.wrappedServerStream.RecvMsg(m)
+	if err == nil {
+		s.counter.message(m, messageReceived)
+	}
+	return err
+}
+
 // StreamServerInterceptor returns an interceptor that traces gRPC streaming server RPCs using AppOptics.
 // Each server span starts with the first message and ends when all request and response messages have finished streaming.
-func StreamServerInterceptor(serverName string) grpc.StreamServerInterceptor {
-	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-		var err error
-		var statusCode = 200
-		newCtx, t := tracingContext(stream.Context(), serverName, info.FullMethod, &statusCode)
+// The exit metadata of the server's trace is sent back to the client in the response headers.
+func StreamServerInterceptor(serverName string, opts ...Option) grpc.StreamServerInterceptor {
+	o := newOptions(opts...)
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		if o.skipped(info.FullMethod) {
+			return handler(srv, stream)
+		}
+		newCtx, t := tracingContext(stream.Context(), serverName, info.FullMethod,
+			streamType(info.IsClientStream, info.IsServerStream), o)
+		counter := &messageCounter{opts: o, span: t}
 		defer func() {
-			t.SetStatus(statusCode)
-			ao.EndTrace(newCtx)
+			t.AddEndArgs(counter.endArgs(true)...)
+			endTrace(t, err)
 		}()
 		// if lg.IsDebug() {
 		// 	sp := ao.FromContext(newCtx)
 		// 	lg.Debug("server stream starting", "xtrace", sp.MetadataString())
 		// }
+		if t.IsReporting() {
+			stream.SetHeader(metadata.Pairs(xtraceKey, t.ExitMetadata()))
+		}
 		wrappedStream := wrapServerStream(stream)
 		wrappedStream.WrappedContext = newCtx
-		err = handler(srv, wrappedStream)
+		err = handler(srv, &tracedServerStream{wrappedServerStream: wrappedStream, counter: counter})
 		if err == io.EOF {
 			return nil
-		} else if err != nil {
-			statusCode = 500
-			ao.Error(newCtx, getErrClass(err), err.Error())
 		}
 		return err
 	}
@@ -169,63 +362,116 @@ func StreamServerInterceptor(serverName string) grpc.StreamServerInterceptor {
 
 // UnaryClientInterceptor returns an interceptor that traces a unary RPC from a gRPC client to a server using
 // AppOptics, by propagating the distributed trace's context from client to server using gRPC metadata.
-func UnaryClientInterceptor(target string, serviceName string) grpc.UnaryClientInterceptor {
+// The exit metadata sent back by the server in the response headers is linked to the client span.
+func UnaryClientInterceptor(target string, serviceName string, opts ...Option) grpc.UnaryClientInterceptor {
+	o := newOptions(opts...)
 	return func(
 		ctx context.Context,
 		method string,
 		req, resp interface{},
 		cc *grpc.ClientConn,
 		invoker grpc.UnaryInvoker,
-		opts ...grpc.CallOption,
+		callOpts ...grpc.CallOption,
 	) error {
+		if o.skipped(method) {
+			return invoker(ctx, method, req, resp, cc, callOpts...)
+		}
 		action := actionFromMethod(method)
-		span := ao.BeginRPCSpan(ctx, action, "grpc", serviceName, target)
-		defer span.End()
+		span := ao.BeginRPCSpan(ctx, action, "grpc", serviceName, target,
+			clientSpanArgs(ctx, o, method, rpcTypeUnary)...)
 		xtID := span.MetadataString()
-		if len(xtID) > 0 {
-			ctx = metadata.AppendToOutgoingContext(ctx, ao.HTTPHeaderName, xtID)
+		if len(xtID) == 0 {
+			defer span.End()
+			return invoker(ctx, method, req, resp, cc, callOpts...)
 		}
-		err := invoker(ctx, method, req, resp, cc, opts...)
-		if err != nil {
-			span.Error(getErrClass(err), err.Error())
-			return err
+
+		if o.messageSizes {
+			if size, ok := messageSize(req); ok {
+				span.AddEndArgs(keyRequestSize, size)
+			}
+		}
+		ctx = metadata.AppendToOutgoingContext(ctx, xtraceKey, xtID)
+		var header, trailer metadata.MD
+		callOpts = append(callOpts, grpc.Header(&header), grpc.Trailer(&trailer))
+		err := invoker(ctx, method, req, resp, cc, callOpts...)
+		if o.messageSizes && err == nil {
+			if size, ok := messageSize(resp); ok {
+				span.AddEndArgs(keyResponseSize, size)
+			}
 		}
-		return nil
+		addEdge(span, header, trailer)
+		closeSpan(span, err)
+		return err
 	}
 }
 
+// clientSpanArgs returns the KVs of the entry event of a client span, including
+// the configured keys of the outgoing metadata.
+func clientSpanArgs(ctx context.Context, o *options, method, rpcType string) []interface{} {
+	args := []interface{}{"GRPCMethod", method, "GRPCType", rpcType}
+	if len(o.metadataKeys) > 0 {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		args = append(args, o.metadataKVs(md)...)
+	}
+	return args
+}
+
 // StreamClientInterceptor returns an interceptor that traces a streaming RPC from a gRPC client to a server using
 // AppOptics, by propagating the distributed trace's context from client to server using gRPC metadata.
 // The client span starts with the first message and ends when all request and response messages have finished streaming.
-func StreamClientInterceptor(target string, serviceName string) grpc.StreamClientInterceptor {
-	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+// The exit metadata sent back by the server in the response headers is linked to the client span.
+func StreamClientInterceptor(target string, serviceName string, opts ...Option) grpc.StreamClientInterceptor {
+	o := newOptions(opts...)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if o.skipped(method) {
+			return streamer(ctx, desc, cc, method, callOpts...)
+		}
 		action := actionFromMethod(method)
-		span := ao.BeginRPCSpan(ctx, action, "grpc", serviceName, target)
+		span := ao.BeginRPCSpan(ctx, action, "grpc", serviceName, target,
+			clientSpanArgs(ctx, o, method, streamType(desc.ClientStreams, desc.ServerStreams))...)
 		xtID := span.MetadataString()
 		// lg.Debug("stream client interceptor", "x-trace", xtID)
 		if len(xtID) > 0 {
-			ctx = metadata.AppendToOutgoingContext(ctx, ao.HTTPHeaderName, xtID)
+			ctx = metadata.AppendToOutgoingContext(ctx, xtraceKey, xtID)
 		}
-		clientStream, err := streamer(ctx, desc, cc, method, opts...)
+		clientStream, err := streamer(ctx, desc, cc, method, callOpts...)
 		if err != nil {
 			closeSpan(span, err)
 			return nil, err
 		}
-		return &tracedClientStream{ClientStream: clientStream, span: span}, nil
+		return &tracedClientStream{
+			ClientStream: clientStream,
+			span:         span,
+			counter:      &messageCounter{opts: o, span: span},
+		}, nil
+	}
+}
+
+// addEdge links the client span to the exit event of the server's trace, whose
+// metadata is sent back by the server in the response headers (or the trailers,
+// if the server has responded with the trailers only).
+func addEdge(span ao.Span, header, trailer metadata.MD) {
+	xt := xtraceFromMD(header)
+	if xt == "" {
+		xt = xtraceFromMD(trailer)
+	}
+	if xt != "" {
+		span.AddEndArgs("Edge", xt)
 	}
 }
 
 type tracedClientStream struct {
 	grpc.ClientStream
-	mu     sync.Mutex
-	closed bool
-	span   ao.Span
+	mu      sync.Mutex
+	closed  bool
+	span    ao.Span
+	counter *messageCounter
 }
 
 func (s *tracedClientStream) Header() (metadata.MD, error) {
 	h, err := s.ClientStream.Header()
 	if err != nil {
-		s.closeSpan(err)
+		s.closeSpan(err, false)
 	}
 	return h, err
 }
@@ -233,7 +479,9 @@ func (s *tracedClientStream) Header() (metadata.MD, error) {
 func (s *tracedClientStream) SendMsg(m interface{}) error {
 	err := s.ClientStream.SendMsg(m)
 	if err != nil {
-		s.closeSpan(err)
+		s.closeSpan(err, false)
+	} else {
+		s.counter.message(m, messageSent)
 	}
 	return err
 }
@@ -241,7 +489,7 @@ func (s *tracedClientStream) SendMsg(m interface{}) error {
 func (s *tracedClientStream) CloseSend() error {
 	err := s.ClientStream.CloseSend()
 	if err != nil {
-		s.closeSpan(err)
+		s.closeSpan(err, false)
 	}
 	return err
 }
@@ -249,15 +497,26 @@ func (s *tracedClientStream) CloseSend() error {
 func (s *tracedClientStream) RecvMsg(m interface{}) error {
 	err := s.ClientStream.RecvMsg(m)
 	if err != nil {
-		s.closeSpan(err)
+		s.closeSpan(err, true)
+	} else {
+		s.counter.message(m, messageReceived)
 	}
 	return err
 }
 
-func (s *tracedClientStream) closeSpan(err error) {
+// closeSpan ends the span of the stream. If the stream has finished, i.e. the
+// response has been received, the span is linked to the server's trace.
+func (s *tracedClientStream) closeSpan(err error, finished bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	if !s.closed {
+		if finished {
+			// the headers and trailers are available without blocking
+			// once the stream is finished.
+			header, _ := s.ClientStream.Header()
+			addEdge(s.span, header, s.ClientStream.Trailer())
+		}
+		s.span.AddEndArgs(s.counter.endArgs(false)...)
 		closeSpan(s.span, err)
 		s.closed = true
 	}
@@ -265,8 +524,9 @@ func (s *tracedClientStream) closeSpan(err error) {
 
 func closeSpan(span ao.Span, err error) {
 	// lg.Debug("closing span", "err", err.Error())
-	if err != nil && err != io.EOF {
+	code := statusCode(err)
+	if code != codes.OK {
 		span.Error(getErrClass(err), err.Error())
 	}
-	span.End()
+	span.End("GRPCStatus", code.String())
 }
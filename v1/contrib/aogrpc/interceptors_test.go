@@ -1,12 +1,26 @@
 package aogrpc
 
 import (
+	"io"
+	"net"
 	"testing"
+	"time"
+
+	"golang.org/x/net/context"
 
 	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	"github.com/appoptics/appoptics-apm-go/v1/ao/aotest"
 	"github.com/appoptics/appoptics-apm-go/v1/contrib/aogrpc/mocks"
+	"github.com/golang/protobuf/proto"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
 )
 
 func TestGetTopFramePkg(t *testing.T) {
@@ -54,3 +68,234 @@ func TestGetTopFramePkg(t *testing.T) {
 	}
 
 }
+
+func TestMethodNames(t *testing.T) {
+	assert.Equal(t, "Check", actionFromMethod("/grpc.health.v1.Health/Check"))
+	assert.Equal(t, "grpc.health.v1.Health", serviceFromMethod("/grpc.health.v1.Health/Check"))
+	assert.Equal(t, "", serviceFromMethod("Check"))
+
+	assert.Equal(t, rpcTypeUnary, streamType(false, false))
+	assert.Equal(t, rpcTypeClientStream, streamType(true, false))
+	assert.Equal(t, rpcTypeServerStream, streamType(false, true))
+	assert.Equal(t, rpcTypeBidiStream, streamType(true, true))
+}
+
+func TestStatusCode(t *testing.T) {
+	assert.Equal(t, codes.OK, statusCode(nil))
+	assert.Equal(t, codes.OK, statusCode(io.EOF))
+	assert.Equal(t, codes.Unknown, statusCode(errors.New("unknown")))
+	assert.Equal(t, codes.NotFound, statusCode(status.Error(codes.NotFound, "not found")))
+
+	assert.Equal(t, 200, httpStatusFromCode(codes.OK))
+	assert.Equal(t, 404, httpStatusFromCode(codes.NotFound))
+	assert.Equal(t, 503, httpStatusFromCode(codes.Unavailable))
+	assert.Equal(t, 500, httpStatusFromCode(codes.Unknown))
+	assert.Equal(t, 500, httpStatusFromCode(codes.DataLoss))
+}
+
+func TestXTraceFromMD(t *testing.T) {
+	assert.Equal(t, "", xtraceFromMD(nil))
+	assert.Equal(t, "", xtraceFromMD(metadata.MD{}))
+	assert.Equal(t, "xt1", xtraceFromMD(metadata.Pairs("X-Trace", "xt1")))
+	assert.Equal(t, "xt2", xtraceFromMD(metadata.MD{"X-Trace": []string{"xt2"}}))
+}
+
+type healthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+}
+
+func (s *healthServer) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	if req.Service == "missing" {
+		return nil, status.Error(codes.NotFound, "unknown service")
+	}
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+}
+
+// Watch streams two status changes of the service.
+func (s *healthServer) Watch(req *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer) error {
+	for _, st := range []grpc_health_v1.HealthCheckResponse_ServingStatus{
+		grpc_health_v1.HealthCheckResponse_SERVING,
+		grpc_health_v1.HealthCheckResponse_NOT_SERVING,
+	} {
+		if err := stream.Send(&grpc_health_v1.HealthCheckResponse{Status: st}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lastEvent returns the exit event of a span.
+func lastEvent(s *aotest.Span) *aotest.Event {
+	return s.Events[len(s.Events)-1]
+}
+
+func TestInterceptors(t *testing.T) {
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor("health")),
+		grpc.StreamInterceptor(StreamServerInterceptor("health")))
+	grpc_health_v1.RegisterHealthServer(s, &healthServer{})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithDialer(func(string, time.Duration) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor("bufnet", "health")),
+		grpc.WithStreamInterceptor(StreamClientInterceptor("bufnet", "health")))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	rec := aotest.Record()
+	ctx := ao.NewContext(context.Background(), ao.NewTrace("test"))
+	client := grpc_health_v1.NewHealthClient(conn)
+	resp, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.Status)
+
+	_, err = client.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	missingErr := err
+
+	stream, err := client.Watch(ctx, &grpc_health_v1.HealthCheckRequest{})
+	require.NoError(t, err)
+	var statuses []grpc_health_v1.HealthCheckResponse_ServingStatus
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			assert.Equal(t, io.EOF, err)
+			break
+		}
+		statuses = append(statuses, resp.Status)
+	}
+	assert.Len(t, statuses, 2)
+	ao.EndTrace(ctx)
+
+	traces := rec.Stop(16)
+	require.Len(t, traces, 1)
+	root := aotest.AssertSpan(t, traces, "test")
+	checks := traces[0].FindSpans("Check")
+	require.Len(t, checks, 2)
+	watch := aotest.AssertSpan(t, traces, "Watch")
+	require.NotNil(t, watch)
+
+	for _, c := range append(checks, watch) {
+		aotest.AssertChild(t, root, c)
+		aotest.AssertKV(t, c, "Spec", "rsc")
+		aotest.AssertKV(t, c, "RemoteProtocol", "grpc")
+		aotest.AssertKV(t, c, "RemoteController", "health")
+		aotest.AssertKV(t, c, "RemoteHost", "bufnet")
+
+		// the server continues the trace of the client, which is linked to
+		// the exit event of the server's span
+		server := c.FindChild("health")
+		require.NotNil(t, server)
+		assert.True(t, server.Ended)
+		aotest.AssertKV(t, server, "Controller", "grpc.health.v1.Health")
+		aotest.AssertKV(t, server, "Action", c.Name)
+		assert.Contains(t, lastEvent(c).Edges, lastEvent(server).OpID)
+	}
+
+	ok, missing := checks[0], checks[1]
+	aotest.AssertKV(t, ok, "GRPCMethod", "/grpc.health.v1.Health/Check")
+	aotest.AssertKV(t, ok, "GRPCType", rpcTypeUnary)
+	aotest.AssertKV(t, ok, "GRPCStatus", "OK")
+	assert.Empty(t, ok.Errors)
+	aotest.AssertKV(t, ok.FindChild("health"), "Status", 200)
+
+	aotest.AssertKV(t, missing, "GRPCStatus", "NotFound")
+	aotest.AssertError(t, missing, "error", missingErr.Error())
+	server := missing.FindChild("health")
+	aotest.AssertKV(t, server, "GRPCStatus", "NotFound")
+	aotest.AssertKV(t, server, "Status", 404)
+	aotest.AssertError(t, server, "error", "rpc error: code = NotFound desc = unknown service")
+
+	aotest.AssertKV(t, watch, "GRPCType", rpcTypeServerStream)
+	aotest.AssertKV(t, watch, "GRPCStatus", "OK")
+	aotest.AssertKV(t, watch, keyRequestMessages, 1)
+	aotest.AssertKV(t, watch, keyResponseMessages, 2)
+	server = watch.FindChild("health")
+	aotest.AssertKV(t, server, "GRPCType", rpcTypeServerStream)
+	aotest.AssertKV(t, server, keyRequestMessages, 1)
+	aotest.AssertKV(t, server, keyResponseMessages, 2)
+}
+
+func TestInterceptorsWithOptions(t *testing.T) {
+	opts := []Option{WithMessageSizes(), WithMessageEvents(), WithMetadata("user-agent", "tenant")}
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor("health", opts...)),
+		grpc.StreamInterceptor(StreamServerInterceptor("health",
+			append(opts, WithSkippedMethods("/grpc.health.v1.Health/Watch"))...)))
+	grpc_health_v1.RegisterHealthServer(s, &healthServer{})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithDialer(func(string, time.Duration) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor("bufnet", "health", opts...)),
+		grpc.WithStreamInterceptor(StreamClientInterceptor("bufnet", "health", opts...)))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	rec := aotest.Record()
+	ctx := ao.NewContext(context.Background(), ao.NewTrace("test"))
+	ctx = metadata.AppendToOutgoingContext(ctx, "tenant", "t1")
+	client := grpc_health_v1.NewHealthClient(conn)
+	req := &grpc_health_v1.HealthCheckRequest{Service: "svc"}
+	resp, err := client.Check(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.Status)
+
+	stream, err := client.Watch(ctx, &grpc_health_v1.HealthCheckRequest{})
+	require.NoError(t, err)
+	for err == nil {
+		_, err = stream.Recv()
+	}
+	assert.Equal(t, io.EOF, err)
+	ao.EndTrace(ctx)
+
+	traces := rec.Stop(11)
+	require.Len(t, traces, 1)
+
+	// the sizes of the request and response are reported by both sides
+	check := aotest.AssertSpan(t, traces, "Check")
+	server := check.FindChild("health")
+	require.NotNil(t, server)
+	for _, s := range []*aotest.Span{check, server} {
+		aotest.AssertKV(t, s, keyRequestSize, proto.Size(req))
+		aotest.AssertKV(t, s, keyResponseSize, proto.Size(resp))
+		aotest.AssertKV(t, s, metadataKeyPrefix+"tenant", "t1")
+	}
+	// the user agent is only in the metadata received by the server
+	assert.NotContains(t, check.KVs, metadataKeyPrefix+"user-agent")
+	if assert.Contains(t, server.KVs, metadataKeyPrefix+"user-agent") {
+		assert.Contains(t, server.KVs[metadataKeyPrefix+"user-agent"], "grpc-go/")
+	}
+
+	// the server span of the skipped method isn't reported
+	watch := aotest.AssertSpan(t, traces, "Watch")
+	require.NotNil(t, watch)
+	assert.Empty(t, watch.Children)
+	assert.Len(t, traces[0].FindSpans("health"), 1)
+
+	// each message of the stream is reported in an info event, numbered in
+	// the order the messages are sent or received
+	ids := make(map[string][]interface{})
+	for _, e := range watch.Events {
+		if e.Label != "info" {
+			continue
+		}
+		msgType := e.KVs[keyMessageType].(string)
+		ids[msgType] = append(ids[msgType], e.KVs[keyMessageID])
+		assert.Contains(t, e.KVs, keyMessageSize)
+	}
+	require.Len(t, ids[messageSent], 1)
+	assert.EqualValues(t, 1, ids[messageSent][0])
+	require.Len(t, ids[messageReceived], 2)
+	assert.EqualValues(t, 1, ids[messageReceived][0])
+	assert.EqualValues(t, 2, ids[messageReceived][1])
+	aotest.AssertKV(t, watch, keyRequestMessages, 1)
+	aotest.AssertKV(t, watch, keyResponseMessages, 2)
+}
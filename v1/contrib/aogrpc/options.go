@@ -0,0 +1,118 @@
+package aogrpc
+
+import (
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/metadata"
+)
+
+// The keys of the KVs reported for the messages and metadata of the RPCs
+const (
+	keyRequestSize      = "RequestSize"
+	keyResponseSize     = "ResponseSize"
+	keyRequestMessages  = "RequestMessages"
+	keyResponseMessages = "ResponseMessages"
+	keyMessageType      = "MessageType"
+	keyMessageID        = "MessageID"
+	keyMessageSize      = "MessageSize"
+	metadataKeyPrefix   = "Request-Metadata-"
+)
+
+// The types of the message events
+const (
+	messageSent     = "SENT"
+	messageReceived = "RECEIVED"
+)
+
+// Option configures the interceptors
+type Option func(*options)
+
+type options struct {
+	// skip returns true if the method should not be traced
+	skip          func(fullMethod string) bool
+	messageSizes  bool
+	messageEvents bool
+	metadataKeys  []string
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithMethodFilter returns an Option which skips tracing the RPCs of the methods
+// for which f returns false. The argument of f is the full method name, e.g.
+// "/grpc.health.v1.Health/Check".
+func WithMethodFilter(f func(fullMethod string) bool) Option {
+	return func(o *options) {
+		prev := o.skip
+		o.skip = func(method string) bool {
+			return (prev != nil && prev(method)) || !f(method)
+		}
+	}
+}
+
+// WithSkippedMethods returns an Option which skips tracing the RPCs of the
+// methods provided. A method can be either a full method name, e.g.
+// "/grpc.health.v1.Health/Check", or a service name, e.g. "grpc.health.v1.Health"
+// to skip all the methods of the service.
+func WithSkippedMethods(methods ...string) Option {
+	skipped := make(map[string]bool)
+	for _, m := range methods {
+		skipped[m] = true
+	}
+	return WithMethodFilter(func(method string) bool {
+		return !skipped[method] && !skipped[serviceFromMethod(method)]
+	})
+}
+
+// WithMessageSizes returns an Option which reports the sizes of the request
+// and response messages. Only the sizes of protobuf messages are reported.
+func WithMessageSizes() Option {
+	return func(o *options) { o.messageSizes = true }
+}
+
+// WithMessageEvents returns an Option which reports an info event for each
+// message sent or received by a streaming RPC, with the type of the message
+// (SENT or RECEIVED) and its sequence number in that direction.
+func WithMessageEvents() Option {
+	return func(o *options) { o.messageEvents = true }
+}
+
+// WithMetadata returns an Option which reports the values of the request
+// metadata keys provided, e.g. "user-agent". The values are reported in KVs
+// named after the keys with the prefix "Request-Metadata-".
+func WithMetadata(keys ...string) Option {
+	return func(o *options) {
+		for _, k := range keys {
+			o.metadataKeys = append(o.metadataKeys, strings.ToLower(k))
+		}
+	}
+}
+
+func (o *options) skipped(method string) bool {
+	return o.skip != nil && o.skip(method)
+}
+
+// metadataKVs returns the KVs of the configured metadata keys found in md
+func (o *options) metadataKVs(md metadata.MD) []interface{} {
+	var kvs []interface{}
+	for _, k := range o.metadataKeys {
+		if vals, ok := md[k]; ok {
+			kvs = append(kvs, metadataKeyPrefix+k, strings.Join(vals, ","))
+		}
+	}
+	return kvs
+}
+
+// messageSize returns the size of a protobuf message
+func messageSize(m interface{}) (int, bool) {
+	if pm, ok := m.(proto.Message); ok {
+		return proto.Size(pm), true
+	}
+	return 0, false
+}
@@ -0,0 +1,61 @@
+package aogrpc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestSkippedMethods(t *testing.T) {
+	o := newOptions()
+	assert.False(t, o.skipped("/grpc.health.v1.Health/Check"))
+
+	o = newOptions(WithSkippedMethods("grpc.health.v1.Health", "/pkg.Service/Ping"))
+	assert.True(t, o.skipped("/grpc.health.v1.Health/Check"))
+	assert.True(t, o.skipped("/grpc.health.v1.Health/Watch"))
+	assert.True(t, o.skipped("/pkg.Service/Ping"))
+	assert.False(t, o.skipped("/pkg.Service/Get"))
+
+	// the filters are chained
+	o = newOptions(WithSkippedMethods("/pkg.Service/Ping"),
+		WithMethodFilter(func(method string) bool { return method != "/pkg.Service/Get" }))
+	assert.True(t, o.skipped("/pkg.Service/Ping"))
+	assert.True(t, o.skipped("/pkg.Service/Get"))
+	assert.False(t, o.skipped("/pkg.Service/List"))
+}
+
+func TestMetadataKVs(t *testing.T) {
+	md := metadata.Pairs("user-agent", "grpc-go", "tenant", "a", "tenant", "b", "secret", "s")
+	assert.Nil(t, newOptions().metadataKVs(md))
+	assert.Nil(t, newOptions(WithMetadata("tenant")).metadataKVs(nil))
+	assert.Equal(t, []interface{}{"Request-Metadata-user-agent", "grpc-go", "Request-Metadata-tenant", "a,b"},
+		newOptions(WithMetadata("User-Agent", "tenant", "missing")).metadataKVs(md))
+}
+
+func TestMessageSize(t *testing.T) {
+	size, ok := messageSize(&grpc_health_v1.HealthCheckRequest{Service: "svc"})
+	assert.True(t, ok)
+	assert.Equal(t, 5, size)
+
+	_, ok = messageSize("not a message")
+	assert.False(t, ok)
+}
+
+func TestMessageCounter(t *testing.T) {
+	c := &messageCounter{opts: newOptions(WithMessageSizes())}
+	req := &grpc_health_v1.HealthCheckRequest{Service: "svc"}
+	c.message(req, messageReceived)
+	c.message(req, messageReceived)
+	c.message(&grpc_health_v1.HealthCheckResponse{}, messageSent)
+
+	assert.Equal(t, []interface{}{"RequestMessages", 2, "ResponseMessages", 1, "RequestSize", 10, "ResponseSize", 0},
+		c.endArgs(true))
+	assert.Equal(t, []interface{}{"RequestMessages", 1, "ResponseMessages", 2, "RequestSize", 0, "ResponseSize", 10},
+		c.endArgs(false))
+
+	c = &messageCounter{opts: newOptions()}
+	c.message(req, messageSent)
+	assert.Equal(t, []interface{}{"RequestMessages", 1, "ResponseMessages", 0}, c.endArgs(false))
+}
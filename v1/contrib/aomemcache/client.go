@@ -0,0 +1,153 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+// Package aomemcache provides AppOptics instrumentation for the gomemcache client.
+//
+//	client := aomemcache.WrapClient(memcache.New("10.0.0.1:11211"), "10.0.0.1:11211")
+//	item, err := client.Get(ctx, "key")
+//
+// Each operation is traced in a cache span, a child of the span bound to the
+// context provided, which reports its operation, key and remote host, and
+// whether it was a hit for Get and GetMulti.
+package aomemcache
+
+import (
+	"context"
+	"strings"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+const spanName = "memcache"
+
+// Option customizes the spans of the operations.
+type Option func(*Client)
+
+// WithKeyMask sets a function which masks the keys reported by the spans, e.g.
+// to remove the IDs or sensitive data they contain.
+func WithKeyMask(mask func(key string) string) Option {
+	return func(c *Client) {
+		c.mask = mask
+	}
+}
+
+// Client traces the operations of a memcache.Client, which is available as
+// its embedded Client for the operations which aren't traced.
+type Client struct {
+	*memcache.Client
+	remoteHost string
+	mask       func(key string) string
+}
+
+// WrapClient returns a Client tracing the operations of the memcache.Client
+// provided, which connects to the remote host(s) provided.
+func WrapClient(c *memcache.Client, remoteHost string, opts ...Option) *Client {
+	tc := &Client{Client: c, remoteHost: remoteHost}
+	for _, opt := range opts {
+		opt(tc)
+	}
+	return tc
+}
+
+// Get gets the item for the given key, reporting whether it was a hit.
+func (c *Client) Get(ctx context.Context, key string) (*memcache.Item, error) {
+	l := c.beginSpan(ctx, "get", c.maskKey(key))
+	item, err := c.Client.Get(key)
+	c.endSpan(l, err, "KVHit", err == nil)
+	return item, err
+}
+
+// GetMulti gets the items for the given keys, reporting whether they all were a hit.
+func (c *Client) GetMulti(ctx context.Context, keys []string) (map[string]*memcache.Item, error) {
+	masked := make([]string, len(keys))
+	for i, key := range keys {
+		masked[i] = c.maskKey(key)
+	}
+	l := c.beginSpan(ctx, "get_multi", strings.Join(masked, ","), "KVKeyCount", len(keys))
+	items, err := c.Client.GetMulti(keys)
+	c.endSpan(l, err, "KVHit", err == nil && len(items) == len(keys), "KVHitCount", len(items))
+	return items, err
+}
+
+// Set writes the given item, unconditionally.
+func (c *Client) Set(ctx context.Context, item *memcache.Item) error {
+	return c.itemOp(ctx, "set", item, c.Client.Set)
+}
+
+// Add writes the given item, if no value already exists for its key.
+func (c *Client) Add(ctx context.Context, item *memcache.Item) error {
+	return c.itemOp(ctx, "add", item, c.Client.Add)
+}
+
+// Replace writes the given item, but only if the server already holds data for its key.
+func (c *Client) Replace(ctx context.Context, item *memcache.Item) error {
+	return c.itemOp(ctx, "replace", item, c.Client.Replace)
+}
+
+// CompareAndSwap writes the given item that was previously returned by Get,
+// if the value was neither modified or evicted between the Get and the CompareAndSwap calls.
+func (c *Client) CompareAndSwap(ctx context.Context, item *memcache.Item) error {
+	return c.itemOp(ctx, "cas", item, c.Client.CompareAndSwap)
+}
+
+// Delete deletes the item with the provided key.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	l := c.beginSpan(ctx, "delete", c.maskKey(key))
+	err := c.Client.Delete(key)
+	c.endSpan(l, err)
+	return err
+}
+
+// Touch updates the expiry for the given key.
+func (c *Client) Touch(ctx context.Context, key string, seconds int32) error {
+	l := c.beginSpan(ctx, "touch", c.maskKey(key))
+	err := c.Client.Touch(key, seconds)
+	c.endSpan(l, err)
+	return err
+}
+
+// Increment atomically increments key by delta.
+func (c *Client) Increment(ctx context.Context, key string, delta uint64) (uint64, error) {
+	l := c.beginSpan(ctx, "incr", c.maskKey(key))
+	val, err := c.Client.Increment(key, delta)
+	c.endSpan(l, err)
+	return val, err
+}
+
+// Decrement atomically decrements key by delta.
+func (c *Client) Decrement(ctx context.Context, key string, delta uint64) (uint64, error) {
+	l := c.beginSpan(ctx, "decr", c.maskKey(key))
+	val, err := c.Client.Decrement(key, delta)
+	c.endSpan(l, err)
+	return val, err
+}
+
+func (c *Client) itemOp(ctx context.Context, op string, item *memcache.Item, f func(*memcache.Item) error) error {
+	l := c.beginSpan(ctx, op, c.maskKey(item.Key))
+	err := f(item)
+	c.endSpan(l, err)
+	return err
+}
+
+// beginSpan begins the span of an operation on the (masked) key provided. Its
+// KVHit is reported when it's ended, for the operations reading an item.
+func (c *Client) beginSpan(ctx context.Context, op, key string, args ...interface{}) ao.Span {
+	kvs := append([]interface{}{"Spec", "cache", "KVOp", op, "KVKey", key, "RemoteHost", c.remoteHost}, args...)
+	l, _ := ao.BeginSpan(ctx, spanName, kvs...)
+	return l
+}
+
+func (c *Client) maskKey(key string) string {
+	if c.mask != nil {
+		return c.mask(key)
+	}
+	return key
+}
+
+// endSpan ends the span of an operation, reporting its error unless it's a cache miss.
+func (c *Client) endSpan(l ao.Span, err error, args ...interface{}) {
+	if err != nil && err != memcache.ErrCacheMiss {
+		l.Err(err)
+	}
+	l.End(args...)
+}
@@ -0,0 +1,156 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package aomemcache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	"github.com/appoptics/appoptics-apm-go/v1/ao/aotest"
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveMemcache serves the gets, set and delete commands of the memcache text
+// protocol with the items provided, until the listener is closed.
+func serveMemcache(ln net.Listener, items map[string]string) {
+	var mu sync.Mutex
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			r := bufio.NewReader(conn)
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				args := strings.Fields(line)
+				if len(args) < 2 {
+					io.WriteString(conn, "ERROR\r\n")
+					continue
+				}
+				mu.Lock()
+				switch args[0] {
+				case "get", "gets":
+					for _, key := range args[1:] {
+						if v, ok := items[key]; ok {
+							fmt.Fprintf(conn, "VALUE %s 0 %d 1\r\n%s\r\n", key, len(v), v)
+						}
+					}
+					io.WriteString(conn, "END\r\n")
+				case "set": // set <key> <flags> <exptime> <bytes>
+					size, _ := strconv.Atoi(args[len(args)-1])
+					data := make([]byte, size+2)
+					if _, err := io.ReadFull(r, data); err != nil {
+						mu.Unlock()
+						return
+					}
+					items[args[1]] = string(data[:size])
+					io.WriteString(conn, "STORED\r\n")
+				case "delete":
+					if _, ok := items[args[1]]; ok {
+						delete(items, args[1])
+						io.WriteString(conn, "DELETED\r\n")
+					} else {
+						io.WriteString(conn, "NOT_FOUND\r\n")
+					}
+				default:
+					io.WriteString(conn, "ERROR\r\n")
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+}
+
+func TestClient(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go serveMemcache(ln, map[string]string{"user:1": "a", "user:2": "b"})
+	addr := ln.Addr().String()
+
+	var masked []string
+	c := WrapClient(memcache.New(addr), addr, WithKeyMask(func(key string) string {
+		masked = append(masked, key)
+		return strings.SplitN(key, ":", 2)[0] + ":?"
+	}))
+
+	rec := aotest.Record()
+	ctx := ao.NewContext(context.Background(), ao.NewTrace("test"))
+	_, err = c.Get(ctx, "user:1")
+	assert.NoError(t, err)
+	_, err = c.Get(ctx, "user:3")
+	assert.Equal(t, memcache.ErrCacheMiss, err)
+	items, err := c.GetMulti(ctx, []string{"user:1", "user:2", "user:3"})
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+	assert.NoError(t, c.Set(ctx, &memcache.Item{Key: "user:3", Value: []byte("c")}))
+	assert.Error(t, c.Delete(ctx, "user:4"))
+	ao.EndTrace(ctx)
+	traces := rec.Stop(12)
+
+	// the keys are masked one by one
+	assert.Equal(t, []string{"user:1", "user:3", "user:1", "user:2", "user:3", "user:3", "user:4"}, masked)
+
+	root := aotest.AssertSpan(t, traces, "test")
+	require.Len(t, root.Children, 5)
+	for _, s := range root.Children {
+		assert.Equal(t, spanName, s.Name)
+		aotest.AssertKV(t, s, "Spec", "cache")
+		aotest.AssertKV(t, s, "RemoteHost", addr)
+		assert.Empty(t, s.Errors)
+		// whether it's a hit is only known when the operation is done
+		assert.NotContains(t, s.Events[0].KVs, "KVHit")
+	}
+	hit, miss, multi, set, del := root.Children[0], root.Children[1], root.Children[2], root.Children[3], root.Children[4]
+	aotest.AssertKV(t, hit, "KVOp", "get")
+	aotest.AssertKV(t, hit, "KVKey", "user:?")
+	aotest.AssertKV(t, hit, "KVHit", true)
+	aotest.AssertKV(t, miss, "KVOp", "get")
+	aotest.AssertKV(t, miss, "KVHit", false)
+	aotest.AssertKV(t, multi, "KVOp", "get_multi")
+	aotest.AssertKV(t, multi, "KVKey", "user:?,user:?,user:?")
+	aotest.AssertKV(t, multi, "KVKeyCount", 3)
+	aotest.AssertKV(t, multi, "KVHitCount", 2)
+	aotest.AssertKV(t, multi, "KVHit", false)
+	aotest.AssertKV(t, set, "KVOp", "set")
+	aotest.AssertKV(t, set, "KVKey", "user:?")
+	assert.NotContains(t, set.KVs, "KVHit")
+	aotest.AssertKV(t, del, "KVOp", "delete")
+	assert.NotContains(t, del.KVs, "KVHit")
+}
+
+func TestClientError(t *testing.T) {
+	rec := aotest.Record()
+	c := WrapClient(memcache.New("localhost:0"), "localhost:0")
+	ctx := ao.NewContext(context.Background(), ao.NewTrace("test"))
+
+	// operations fail without a server, but are still traced
+	_, err := c.Increment(ctx, "n", 1)
+	assert.Error(t, err)
+	ao.EndTrace(ctx)
+
+	traces := rec.Stop(5)
+	incr := aotest.AssertSpan(t, traces, spanName)
+	aotest.AssertKV(t, incr, "KVOp", "incr")
+	aotest.AssertKV(t, incr, "KVKey", "n")
+	aotest.AssertKV(t, incr, "RemoteHost", "localhost:0")
+	assert.NotContains(t, incr.KVs, "KVHit")
+	if assert.Len(t, incr.Errors, 1) {
+		assert.Equal(t, err.Error(), incr.Errors[0].Message)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+// Package aomongo provides AppOptics instrumentation for the official MongoDB driver.
+//
+//	opts := options.Client().ApplyURI("mongodb://localhost:27017").SetMonitor(aomongo.NewMonitor())
+//	client, err := mongo.Connect(ctx, opts)
+//
+// Each command run with the context of a span is traced in a query span, a child
+// of that span, which reports its database, collection, command name and the
+// command document, the values of which are replaced by "?".
+package aomongo
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+const (
+	spanName = "mongodb"
+	flavor   = "mongodb"
+)
+
+// ignoredFields are the fields of the command documents which aren't reported.
+var ignoredFields = map[string]bool{
+	"lsid":            true,
+	"$db":             true,
+	"$clusterTime":    true,
+	"$readPreference": true,
+}
+
+// NewMonitor returns an event.CommandMonitor tracing the commands run by a client.
+func NewMonitor() *event.CommandMonitor {
+	m := &monitor{spans: make(map[spanKey]ao.Span)}
+	return &event.CommandMonitor{
+		Started:   m.started,
+		Succeeded: m.succeeded,
+		Failed:    m.failed,
+	}
+}
+
+// spanKey identifies a command, the started and finished events of which are correlated.
+type spanKey struct {
+	connectionID string
+	requestID    int64
+}
+
+type monitor struct {
+	sync.Mutex
+	spans map[spanKey]ao.Span
+}
+
+func (m *monitor) started(ctx context.Context, e *event.CommandStartedEvent) {
+	if !ao.IsSampled(ctx) {
+		return
+	}
+	args := []interface{}{"Database", e.DatabaseName, "CommandName", e.CommandName}
+	if coll := collection(e.CommandName, e.Command); coll != "" {
+		args = append(args, "Collection", coll)
+	}
+	l := ao.BeginQuerySpan(ctx, spanName, sanitize(e.Command), flavor, remoteHost(e.ConnectionID), args...)
+
+	m.Lock()
+	m.spans[spanKey{e.ConnectionID, e.RequestID}] = l
+	m.Unlock()
+}
+
+func (m *monitor) succeeded(ctx context.Context, e *event.CommandSucceededEvent) {
+	if l, ok := m.pop(e.CommandFinishedEvent); ok {
+		l.End()
+	}
+}
+
+func (m *monitor) failed(ctx context.Context, e *event.CommandFailedEvent) {
+	if l, ok := m.pop(e.CommandFinishedEvent); ok {
+		l.Error("error", e.Failure)
+		l.End()
+	}
+}
+
+// pop removes and returns the span of the finished command.
+func (m *monitor) pop(e event.CommandFinishedEvent) (ao.Span, bool) {
+	key := spanKey{e.ConnectionID, e.RequestID}
+	m.Lock()
+	defer m.Unlock()
+	l, ok := m.spans[key]
+	delete(m.spans, key)
+	return l, ok
+}
+
+// remoteHost returns the host of the connection ID provided, e.g. "localhost:27017"
+// for "localhost:27017[-4]".
+func remoteHost(connectionID string) string {
+	if i := strings.IndexByte(connectionID, '['); i >= 0 {
+		return connectionID[:i]
+	}
+	return connectionID
+}
+
+// collection returns the collection of the command, which is the value of its
+// first element, or of its "collection" element for a getMore command.
+func collection(name string, cmd bson.Raw) string {
+	if name == "getMore" {
+		if v, ok := cmd.Lookup("collection").StringValueOK(); ok {
+			return v
+		}
+		return ""
+	}
+	elems, err := cmd.Elements()
+	if err != nil || len(elems) == 0 {
+		return ""
+	}
+	v, _ := elems[0].Value().StringValueOK()
+	return v
+}
+
+// sanitize returns the command document as relaxed extended JSON, after replacing
+// its values, except the first one, by "?".
+func sanitize(cmd bson.Raw) string {
+	var d bson.D
+	if err := bson.Unmarshal(cmd, &d); err != nil {
+		return ""
+	}
+	out := make(bson.D, 0, len(d))
+	for i, e := range d {
+		if ignoredFields[e.Key] {
+			continue
+		}
+		if i > 0 {
+			e.Value = sanitizeValue(e.Value)
+		}
+		out = append(out, e)
+	}
+	b, err := bson.MarshalExtJSON(out, false, false)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+func sanitizeValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case bson.D:
+		out := make(bson.D, len(v))
+		for i, e := range v {
+			out[i] = bson.E{Key: e.Key, Value: sanitizeValue(e.Value)}
+		}
+		return out
+	case bson.A:
+		out := make(bson.A, len(v))
+		for i, e := range v {
+			out[i] = sanitizeValue(e)
+		}
+		return out
+	}
+	return "?"
+}
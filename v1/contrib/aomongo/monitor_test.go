@@ -0,0 +1,89 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package aomongo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	"github.com/appoptics/appoptics-apm-go/v1/ao/aotest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+func mustMarshal(t *testing.T, d bson.D) bson.Raw {
+	b, err := bson.Marshal(d)
+	assert.NoError(t, err)
+	return b
+}
+
+func TestSanitize(t *testing.T) {
+	cmd := mustMarshal(t, bson.D{
+		{Key: "find", Value: "users"},
+		{Key: "filter", Value: bson.D{{Key: "name", Value: "bob"}, {Key: "age", Value: bson.D{{Key: "$gt", Value: 30}}}}},
+		{Key: "projection", Value: bson.A{"a", 1}},
+		{Key: "$db", Value: "test"},
+	})
+	assert.Equal(t, `{"find":"users","filter":{"name":"?","age":{"$gt":"?"}},"projection":["?","?"]}`, sanitize(cmd))
+	assert.Equal(t, "", sanitize(bson.Raw{1, 2}))
+}
+
+func TestCollection(t *testing.T) {
+	assert.Equal(t, "users", collection("find", mustMarshal(t, bson.D{{Key: "find", Value: "users"}})))
+	assert.Equal(t, "users", collection("getMore", mustMarshal(t, bson.D{
+		{Key: "getMore", Value: int64(42)}, {Key: "collection", Value: "users"}})))
+	assert.Equal(t, "", collection("ping", mustMarshal(t, bson.D{{Key: "ping", Value: 1}})))
+	assert.Equal(t, "", collection("find", nil))
+}
+
+func TestRemoteHost(t *testing.T) {
+	assert.Equal(t, "localhost:27017", remoteHost("localhost:27017[-4]"))
+	assert.Equal(t, "localhost:27017", remoteHost("localhost:27017"))
+}
+
+func TestMonitor(t *testing.T) {
+	rec := aotest.Record()
+	mon := NewMonitor()
+	ctx := ao.NewContext(context.Background(), ao.NewTrace("test"))
+	cmd := mustMarshal(t, bson.D{{Key: "find", Value: "users"}})
+
+	mon.Started(ctx, &event.CommandStartedEvent{Command: cmd, DatabaseName: "test",
+		CommandName: "find", RequestID: 1, ConnectionID: "localhost:27017[-1]"})
+	mon.Started(ctx, &event.CommandStartedEvent{Command: cmd, DatabaseName: "test",
+		CommandName: "find", RequestID: 2, ConnectionID: "localhost:27017[-1]"})
+	mon.Failed(ctx, &event.CommandFailedEvent{Failure: "not authorized",
+		CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "find", RequestID: 2, ConnectionID: "localhost:27017[-1]"}})
+	mon.Succeeded(ctx, &event.CommandSucceededEvent{
+		CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "find", RequestID: 1, ConnectionID: "localhost:27017[-1]"}})
+	// finished events without started event are ignored
+	mon.Succeeded(ctx, &event.CommandSucceededEvent{
+		CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "find", RequestID: 3, ConnectionID: "localhost:27017[-1]"}})
+	ao.EndTrace(ctx)
+
+	// commands without a traced context aren't traced
+	mon.Started(context.Background(), &event.CommandStartedEvent{Command: cmd, CommandName: "find", RequestID: 4})
+	mon.Succeeded(context.Background(), &event.CommandSucceededEvent{
+		CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "find", RequestID: 4}})
+
+	traces := rec.Stop(7)
+	require.Len(t, traces, 1)
+	root := aotest.AssertSpan(t, traces, "test")
+	require.Len(t, root.Children, 2)
+	for _, s := range root.Children {
+		assert.Equal(t, spanName, s.Name)
+		assert.True(t, s.Ended)
+		aotest.AssertKV(t, s, "Spec", "query")
+		aotest.AssertKV(t, s, "Flavor", flavor)
+		aotest.AssertKV(t, s, "Query", `{"find":"users"}`)
+		aotest.AssertKV(t, s, "RemoteHost", "localhost:27017")
+		aotest.AssertKV(t, s, "Database", "test")
+		aotest.AssertKV(t, s, "Collection", "users")
+		aotest.AssertKV(t, s, "CommandName", "find")
+	}
+	succeeded, failed := root.Children[0], root.Children[1]
+	assert.Empty(t, succeeded.Errors)
+	aotest.AssertError(t, failed, "error", "not authorized")
+}
@@ -0,0 +1,61 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+// Package aomux provides the AppOptics tracing middleware for the gorilla/mux router.
+//
+//	r := mux.NewRouter()
+//	r.Use(aomux.Middleware)
+//
+// Each request is traced with a transaction named after the matched route,
+// e.g. "GET /users/{id}", unless a custom transaction name is set by the handler.
+// The trace is bound to the context of the http.Request and can be retrieved
+// by the handlers with ao.TraceFromContext(r.Context()).
+package aomux
+
+import (
+	"net/http"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	"github.com/appoptics/appoptics-apm-go/v1/contrib/internal/router"
+	"github.com/gorilla/mux"
+)
+
+const spanName = "mux"
+
+// Middleware traces the requests with AppOptics. If the request headers describe
+// a distributed trace, the trace will be continued.
+func Middleware(next http.Handler) http.Handler {
+	return MiddlewareWithOptions()(next)
+}
+
+// MiddlewareWithOptions returns a mux.MiddlewareFunc which traces the requests
+// with AppOptics, creating the traces with the provided options.
+func MiddlewareWithOptions(opts ...ao.SpanOpt) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		if ao.Disabled() {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ao.Closed() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// the middleware of a mux.Router runs after the route is matched
+			var route string
+			var endArgs []interface{}
+			if rt := mux.CurrentRoute(r); rt != nil {
+				route, _ = rt.GetPathTemplate()
+				endArgs = router.EndArgs(router.FuncName(rt.GetHandler()))
+			}
+
+			t, w, r := ao.TraceFromHTTPRequestResponse(spanName, w, r, opts...)
+			defer func() {
+				router.SetTransactionName(t, r.Method, route)
+				t.End(endArgs...)
+			}()
+			defer router.ReportPanic(t, w.(*ao.HTTPResponseWriter))
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package aomux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	"github.com/appoptics/appoptics-apm-go/v1/ao/aotest"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serve(h http.Handler, method, path string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func getUser(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusTeapot)
+	w.Write([]byte("user " + mux.Vars(r)["id"]))
+}
+
+func TestMiddleware(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(Middleware)
+	r.HandleFunc("/users/{id}", getUser).Methods("GET")
+	r.HandleFunc("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		assert.NotNil(t, ao.TraceFromContext(r.Context()))
+	})
+
+	rec := aotest.Record()
+	w := serve(r, "GET", "/users/1")
+	assert.Equal(t, http.StatusTeapot, w.Code)
+	assert.Equal(t, "user 1", w.Body.String())
+
+	w = serve(r, "GET", "/items/1")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(r, "GET", "/notfound")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// the middleware of a mux.Router only runs for the matched routes
+	traces := rec.Stop(4)
+	require.Len(t, traces, 2)
+	user := traces[0].Root
+	assert.Equal(t, spanName, user.Name)
+	aotest.AssertKV(t, user, "TransactionName", "GET /users/{id}")
+	aotest.AssertKV(t, user, "Controller", "aomux")
+	aotest.AssertKV(t, user, "Action", "getUser")
+	aotest.AssertKV(t, user, "Status", http.StatusTeapot)
+	aotest.AssertKV(t, traces[1].Root, "TransactionName", "GET /items/{id}")
+}
+
+func TestMiddlewarePanic(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(Middleware)
+	r.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("panicking!")
+	})
+
+	rec := aotest.Record()
+	assert.PanicsWithValue(t, "panicking!", func() { serve(r, "GET", "/panic") })
+	traces := rec.Stop(3)
+
+	s := aotest.AssertSpan(t, traces, spanName)
+	aotest.AssertError(t, s, "panic", "panicking!")
+	aotest.AssertKV(t, s, "Status", http.StatusInternalServerError)
+	aotest.AssertKV(t, s, "TransactionName", "GET /panic")
+}
@@ -0,0 +1,86 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+// Package aonats provides AppOptics instrumentation for the NATS client.
+//
+// Messages are published in a span which sends its metadata along with the message
+// in its "X-Trace" header, so that its subscribers continue the trace:
+//
+//	err := aonats.PublishMsg(ctx, nc, &nats.Msg{Subject: "updates", Data: data})
+//
+//	nc.Subscribe("updates", aonats.MsgHandler(func(ctx context.Context, msg *nats.Msg) {
+//		// ... process the message in the span bound to ctx ...
+//	}))
+//
+// Note that headers require NATS server 2.2 or later.
+package aonats
+
+import (
+	"context"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	system          = "nats"
+	publishSpanName = "nats.publish"
+	requestSpanName = "nats.request"
+	consumeSpanName = "nats.consume"
+)
+
+// PublishMsg publishes the message on the connection provided in a publish span,
+// which is a child of the span bound to ctx. The metadata of the span is added
+// to the headers of the message.
+func PublishMsg(ctx context.Context, nc *nats.Conn, msg *nats.Msg) error {
+	l := beginPublishSpan(ctx, publishSpanName, nc, msg)
+	err := nc.PublishMsg(msg)
+	l.Err(err)
+	l.End()
+	return err
+}
+
+// RequestMsgWithContext sends the request message on the connection provided in
+// a publish span, which is a child of the span bound to ctx, and waits for its
+// response. The metadata of the span is added to the headers of the request.
+func RequestMsgWithContext(ctx context.Context, nc *nats.Conn, msg *nats.Msg) (*nats.Msg, error) {
+	l := beginPublishSpan(ctx, requestSpanName, nc, msg)
+	resp, err := nc.RequestMsgWithContext(ctx, msg)
+	if err != nil {
+		l.Err(err)
+	} else {
+		l.AddEndArgs("ResponseSize", len(resp.Data))
+	}
+	l.End()
+	return resp, err
+}
+
+func beginPublishSpan(ctx context.Context, spanName string, nc *nats.Conn, msg *nats.Msg) ao.Span {
+	l := ao.BeginPublishSpan(ctx, spanName, system, msg.Subject, "", len(msg.Data),
+		"RemoteHost", nc.ConnectedAddr())
+	if md := l.MetadataString(); md != "" {
+		if msg.Header == nil {
+			msg.Header = nats.Header{}
+		}
+		msg.Header.Set(ao.HTTPHeaderName, md)
+	}
+	return l
+}
+
+// BeginConsumeSpan returns a span for the processing of the message received,
+// along with a context bound to it. If ctx is bound to a span, the returned span
+// is its child. Otherwise the returned span is a new trace, which continues the
+// trace of the publisher of the message if its headers have its metadata.
+func BeginConsumeSpan(ctx context.Context, msg *nats.Msg) (ao.Span, context.Context) {
+	return ao.BeginConsumeSpan(ctx, consumeSpanName, msg.Header.Get(ao.HTTPHeaderName),
+		system, msg.Subject, "", len(msg.Data))
+}
+
+// MsgHandler returns a nats.MsgHandler which calls h with a context bound to a
+// span for the processing of each message received, as returned by BeginConsumeSpan.
+func MsgHandler(h func(ctx context.Context, msg *nats.Msg)) nats.MsgHandler {
+	return func(msg *nats.Msg) {
+		l, ctx := BeginConsumeSpan(context.Background(), msg)
+		defer l.End()
+		h(ctx, msg)
+	}
+}
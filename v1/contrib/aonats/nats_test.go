@@ -0,0 +1,198 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package aonats
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	"github.com/appoptics/appoptics-apm-go/v1/ao/aotest"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMD = "2B0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF0123456701"
+
+// testServer is a minimal NATS server, which delivers the messages published
+// on a connection to the subscriptions of that connection.
+type testServer struct {
+	ln net.Listener
+	wg sync.WaitGroup
+}
+
+func newTestServer(t *testing.T) *testServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &testServer{ln: ln}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				defer conn.Close()
+				s.serve(conn)
+			}()
+		}
+	}()
+	return s
+}
+
+func (s *testServer) URL() string { return "nats://" + s.ln.Addr().String() }
+
+func (s *testServer) Close() {
+	s.ln.Close()
+	s.wg.Wait()
+}
+
+func (s *testServer) serve(conn net.Conn) {
+	fmt.Fprintf(conn, "INFO {\"server_id\":\"test\",\"version\":\"2.10.0\",\"proto\":1,"+
+		"\"headers\":true,\"max_payload\":1048576}\r\n")
+	subs := make(map[string][]string) // subject -> sids
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			continue
+		}
+		switch strings.ToUpper(args[0]) {
+		case "PING":
+			io.WriteString(conn, "PONG\r\n")
+		case "SUB": // SUB <subject> [queue group] <sid>
+			subs[args[1]] = append(subs[args[1]], args[len(args)-1])
+		case "PUB", "HPUB": // [H]PUB <subject> [reply-to] [#header bytes] <#total bytes>
+			size, _ := strconv.Atoi(args[len(args)-1])
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			verb := "MSG"
+			if args[0] == "HPUB" {
+				verb = "HMSG"
+			}
+			for _, sid := range subs[args[1]] {
+				fmt.Fprintf(conn, "%s %s %s %s\r\n%s", verb, args[1], sid,
+					strings.Join(args[2:], " "), payload)
+			}
+		}
+	}
+}
+
+func TestPublishMsg(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	nc, err := nats.Connect(s.URL())
+	require.NoError(t, err)
+	defer nc.Close()
+
+	rec := aotest.Record()
+	received := make(chan *nats.Msg, 1)
+	_, err = nc.Subscribe("updates", MsgHandler(func(ctx context.Context, msg *nats.Msg) {
+		assert.True(t, ao.IsSampled(ctx))
+		received <- msg
+	}))
+	require.NoError(t, err)
+
+	ctx := ao.NewContext(context.Background(), ao.NewTrace("producer"))
+	msg := &nats.Msg{Subject: "updates", Data: []byte("update")}
+	assert.NoError(t, PublishMsg(ctx, nc, msg))
+	var consumed *nats.Msg
+	select {
+	case consumed = <-received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the message is not received")
+	}
+	ao.EndTrace(ctx)
+	traces := rec.Stop(6)
+
+	// the metadata of the publish span is sent along with the message
+	md := msg.Header.Get(ao.HTTPHeaderName)
+	assert.Len(t, md, len(testMD))
+	assert.Equal(t, md, consumed.Header.Get(ao.HTTPHeaderName))
+
+	producer := aotest.AssertSpan(t, traces, "producer")
+	publish := aotest.AssertSpan(t, traces, publishSpanName)
+	consume := aotest.AssertSpan(t, traces, consumeSpanName)
+	aotest.AssertChild(t, producer, publish)
+	assert.Equal(t, md[42:58], publish.ID)
+	aotest.AssertKV(t, publish, "Spec", "msgclient")
+	aotest.AssertKV(t, publish, "Op", "publish")
+	aotest.AssertKV(t, publish, "Flavor", system)
+	aotest.AssertKV(t, publish, "Destination", "updates")
+	aotest.AssertKV(t, publish, "PayloadSize", len("update"))
+	aotest.AssertKV(t, publish, "RemoteHost", nc.ConnectedAddr())
+
+	// the consumer continues the trace of the publisher
+	assert.Len(t, traces, 1)
+	assert.True(t, consume.Ended)
+	aotest.AssertKV(t, consume, "Op", "consume")
+	aotest.AssertKV(t, consume, "Flavor", system)
+	aotest.AssertKV(t, consume, "Destination", "updates")
+	aotest.AssertKV(t, consume, "Link", md)
+}
+
+func TestMsgHandler(t *testing.T) {
+	rec := aotest.Record()
+	msg := &nats.Msg{Subject: "updates", Data: []byte("update"), Header: nats.Header{}}
+	msg.Header.Set(ao.HTTPHeaderName, testMD)
+
+	var called bool
+	MsgHandler(func(ctx context.Context, m *nats.Msg) {
+		called = true
+		assert.Equal(t, msg, m)
+		assert.NotNil(t, ao.TraceFromContext(ctx))
+	})(msg)
+	assert.True(t, called)
+
+	traces := rec.Stop(2)
+	require.Len(t, traces, 1)
+	assert.Equal(t, testMD[2:42], traces[0].ID)
+	consume := aotest.AssertSpan(t, traces, consumeSpanName)
+	aotest.AssertKV(t, consume, "Link", testMD)
+	aotest.AssertKV(t, consume, "PayloadSize", len("update"))
+}
+
+func TestBeginConsumeSpan(t *testing.T) {
+	rec := aotest.Record()
+	// messages without headers are consumed in a new trace
+	l, ctx := BeginConsumeSpan(context.Background(), &nats.Msg{Subject: "updates"})
+	assert.Equal(t, l, ao.TraceFromContext(ctx))
+	l.End()
+
+	// or in a child span of the span bound to the context
+	ctx = ao.NewContext(context.Background(), ao.NewTrace("worker"))
+	l, cctx := BeginConsumeSpan(ctx, &nats.Msg{Subject: "updates"})
+	assert.Equal(t, l, ao.FromContext(cctx))
+	l.End()
+	ao.EndTrace(ctx)
+
+	traces := rec.Stop(6)
+	assert.Len(t, traces, 2)
+	worker := traces.FindSpan("worker")
+	if assert.NotNil(t, worker) {
+		consume := worker.FindChild(consumeSpanName)
+		if assert.NotNil(t, consume) {
+			aotest.AssertKV(t, consume, "Destination", "updates")
+			assert.NotContains(t, consume.KVs, "Link")
+		}
+	}
+}
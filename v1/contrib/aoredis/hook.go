@@ -0,0 +1,137 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+// Package aoredis provides AppOptics instrumentation for the go-redis client.
+//
+//	client := aoredis.WrapClient(redis.NewClient(&redis.Options{Addr: "localhost:6379"}))
+//	val, err := client.Get(ctx, "key").Result()
+//
+// Each command is traced in a cache span, a child of the span bound to the
+// context of the command, which reports its operation, key and remote host, and
+// whether it was a hit for read commands. A pipeline is traced in a single span
+// reporting the number of its commands.
+package aoredis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	"github.com/go-redis/redis/v8"
+)
+
+const spanName = "redis"
+
+// readCommands are the commands reporting whether they are a cache hit: they
+// return a redis.Nil error if the key doesn't exist.
+var readCommands = map[string]bool{
+	"get":    true,
+	"getex":  true,
+	"getdel": true,
+	"getset": true,
+	"hget":   true,
+	"lindex": true,
+	"zscore": true,
+	"zrank":  true,
+}
+
+// Option customizes the spans of the commands.
+type Option func(*hook)
+
+// WithKeyMask sets a function which masks the keys reported by the spans, e.g.
+// to remove the IDs or sensitive data they contain.
+func WithKeyMask(mask func(key string) string) Option {
+	return func(h *hook) {
+		h.mask = mask
+	}
+}
+
+// WrapClient adds a hook tracing the commands of the client, which is returned.
+func WrapClient(c *redis.Client, opts ...Option) *redis.Client {
+	c.AddHook(NewHook(c.Options().Addr, opts...))
+	return c
+}
+
+// NewHook returns a redis.Hook tracing the commands sent to the remote host
+// provided, e.g. to be added to a redis.ClusterClient or redis.Ring.
+func NewHook(remoteHost string, opts ...Option) redis.Hook {
+	h := &hook{remoteHost: remoteHost}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+type hook struct {
+	remoteHost string
+	mask       func(key string) string
+}
+
+type spanKey struct{}
+
+// BeforeProcess begins the span of the command.
+func (h *hook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	l, _ := ao.BeginSpan(ctx, spanName, "Spec", "cache", "KVOp", cmd.Name(),
+		"KVKey", h.key(cmd), "RemoteHost", h.remoteHost)
+	return context.WithValue(ctx, spanKey{}, l), nil
+}
+
+// AfterProcess ends the span of the command, reporting its error or, for read
+// commands, whether it was a hit.
+func (h *hook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	if l, ok := ctx.Value(spanKey{}).(ao.Span); ok {
+		err := cmd.Err()
+		if err != nil && err != redis.Nil {
+			l.Err(err)
+		}
+		if readCommands[cmd.Name()] {
+			l.End("KVHit", err == nil)
+		} else {
+			l.End()
+		}
+	}
+	return nil
+}
+
+// BeforeProcessPipeline begins the span of the pipeline.
+func (h *hook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	l, _ := ao.BeginSpan(ctx, spanName, "Spec", "cache", "KVOp", "pipeline",
+		"KVCommandCount", len(cmds), "RemoteHost", h.remoteHost)
+	return context.WithValue(ctx, spanKey{}, l), nil
+}
+
+// AfterProcessPipeline ends the span of the pipeline, reporting the errors of
+// its commands.
+func (h *hook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	if l, ok := ctx.Value(spanKey{}).(ao.Span); ok {
+		var errs []string
+		for _, cmd := range cmds {
+			if err := cmd.Err(); err != nil && err != redis.Nil {
+				errs = append(errs, cmd.Name()+": "+err.Error())
+			}
+		}
+		if len(errs) > 0 {
+			l.Error("error", strings.Join(errs, "; "))
+		}
+		l.End()
+	}
+	return nil
+}
+
+// key returns the (masked) first key of the command, if any.
+func (h *hook) key(cmd redis.Cmder) string {
+	args := cmd.Args()
+	pos := 1
+	switch cmd.Name() {
+	case "eval", "evalsha":
+		pos = 3
+	}
+	if len(args) <= pos {
+		return ""
+	}
+	key := fmt.Sprint(args[pos])
+	if h.mask != nil {
+		key = h.mask(key)
+	}
+	return key
+}
@@ -0,0 +1,102 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package aoredis
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	"github.com/appoptics/appoptics-apm-go/v1/ao/aotest"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKey(t *testing.T) {
+	ctx := context.Background()
+	h := NewHook("localhost:6379").(*hook)
+	assert.Equal(t, "user:1", h.key(redis.NewStringCmd(ctx, "get", "user:1")))
+	assert.Equal(t, "", h.key(redis.NewStatusCmd(ctx, "ping")))
+	assert.Equal(t, "k", h.key(redis.NewCmd(ctx, "eval", "return 1", 1, "k")))
+
+	h = NewHook("localhost:6379", WithKeyMask(func(key string) string {
+		return strings.SplitN(key, ":", 2)[0] + ":?"
+	})).(*hook)
+	assert.Equal(t, "user:?", h.key(redis.NewStringCmd(ctx, "get", "user:1")))
+}
+
+func TestHook(t *testing.T) {
+	rec := aotest.Record()
+	ctx := ao.NewContext(context.Background(), ao.NewTrace("test"))
+	h := NewHook("localhost:6379")
+
+	miss := redis.NewStringCmd(ctx, "get", "k")
+	miss.SetErr(redis.Nil)
+	hctx, err := h.BeforeProcess(ctx, miss)
+	assert.NoError(t, err)
+	assert.NotNil(t, hctx.Value(spanKey{}))
+	assert.NoError(t, h.AfterProcess(hctx, miss))
+
+	hit := redis.NewStringCmd(ctx, "get", "k")
+	hctx, _ = h.BeforeProcess(ctx, hit)
+	assert.NoError(t, h.AfterProcess(hctx, hit))
+
+	cmds := []redis.Cmder{redis.NewStatusCmd(ctx, "set", "k", "v"), redis.NewIntCmd(ctx, "incr", "n")}
+	cmds[1].SetErr(errors.New("ERR value is not an integer or out of range"))
+	hctx, err = h.BeforeProcessPipeline(ctx, cmds)
+	assert.NoError(t, err)
+	assert.NoError(t, h.AfterProcessPipeline(hctx, cmds))
+
+	// the span is only ended once it's been begun
+	assert.NoError(t, h.AfterProcess(ctx, miss))
+	assert.NoError(t, h.AfterProcessPipeline(ctx, cmds))
+	ao.EndTrace(ctx)
+
+	traces := rec.Stop(9)
+	root := aotest.AssertSpan(t, traces, "test")
+	require.Len(t, root.Children, 3)
+	for _, s := range root.Children {
+		assert.Equal(t, spanName, s.Name)
+		assert.True(t, s.Ended)
+		aotest.AssertKV(t, s, "Spec", "cache")
+		aotest.AssertKV(t, s, "RemoteHost", "localhost:6379")
+		// whether it's a hit is only known when the command is done
+		assert.NotContains(t, s.Events[0].KVs, "KVHit")
+	}
+	aotest.AssertKV(t, root.Children[0], "KVOp", "get")
+	aotest.AssertKV(t, root.Children[0], "KVKey", "k")
+	aotest.AssertKV(t, root.Children[0], "KVHit", false)
+	assert.Empty(t, root.Children[0].Errors)
+	aotest.AssertKV(t, root.Children[1], "KVOp", "get")
+	aotest.AssertKV(t, root.Children[1], "KVHit", true)
+
+	pipeline := root.Children[2]
+	aotest.AssertKV(t, pipeline, "KVOp", "pipeline")
+	aotest.AssertKV(t, pipeline, "KVCommandCount", 2)
+	assert.NotContains(t, pipeline.KVs, "KVHit")
+	aotest.AssertError(t, pipeline, "error", "incr: ERR value is not an integer or out of range")
+}
+
+func TestWrapClient(t *testing.T) {
+	rec := aotest.Record()
+	c := WrapClient(redis.NewClient(&redis.Options{Addr: "localhost:0"}), WithKeyMask(func(key string) string {
+		return "?"
+	}))
+	defer c.Close()
+	// commands fail without a server, but are still traced
+	ctx := ao.NewContext(context.Background(), ao.NewTrace("test"))
+	err := c.Set(ctx, "k", "v", 0).Err()
+	assert.Error(t, err)
+	ao.EndTrace(ctx)
+
+	traces := rec.Stop(5)
+	set := aotest.AssertSpan(t, traces, spanName)
+	aotest.AssertKV(t, set, "KVOp", "set")
+	aotest.AssertKV(t, set, "KVKey", "?")
+	aotest.AssertKV(t, set, "RemoteHost", "localhost:0")
+	assert.NotContains(t, set.KVs, "KVHit")
+	aotest.AssertError(t, set, "error", err.Error())
+}
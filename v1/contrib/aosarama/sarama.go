@@ -0,0 +1,90 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+// Package aosarama provides AppOptics instrumentation for the sarama Kafka client.
+//
+// Messages are published in a span which sends its metadata along with the message
+// in its "X-Trace" record header, so that its consumer continues the trace:
+//
+//	partition, offset, err := aosarama.SendMessage(ctx, producer, msg)
+//
+//	for msg := range partitionConsumer.Messages() {
+//		span, ctx := aosarama.BeginConsumeSpan(context.Background(), msg)
+//		// ... process the message ...
+//		span.End()
+//	}
+package aosarama
+
+import (
+	"context"
+
+	"github.com/Shopify/sarama"
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+)
+
+const (
+	system          = "kafka"
+	publishSpanName = "kafka.publish"
+	consumeSpanName = "kafka.consume"
+)
+
+// SendMessage sends the message with the producer provided in a publish span,
+// which is a child of the span bound to ctx. The metadata of the span is added
+// to the headers of the message. Note that headers require Kafka 0.11 or later.
+func SendMessage(ctx context.Context, p sarama.SyncProducer, msg *sarama.ProducerMessage) (partition int32, offset int64, err error) {
+	l := BeginPublishSpan(ctx, msg)
+	partition, offset, err = p.SendMessage(msg)
+	if err != nil {
+		l.Err(err)
+		l.End()
+	} else {
+		l.End("Partition", partition, "Offset", offset)
+	}
+	return partition, offset, err
+}
+
+// BeginPublishSpan returns a publish span for the message, which is a child of
+// the span bound to ctx, and adds the metadata of the span to the headers of the
+// message. It can be used with a sarama.AsyncProducer, ending the span once the
+// message is returned on its Successes or Errors channel.
+func BeginPublishSpan(ctx context.Context, msg *sarama.ProducerMessage) ao.Span {
+	size := -1
+	if msg.Value != nil {
+		size = msg.Value.Length()
+	}
+	l := ao.BeginPublishSpan(ctx, publishSpanName, system, msg.Topic, "", size)
+	if md := l.MetadataString(); md != "" {
+		msg.Headers = fromMessageHeaders(ao.InjectMetadataHeaders(toMessageHeaders(msg.Headers), md))
+	}
+	return l
+}
+
+// BeginConsumeSpan returns a span for the processing of the message consumed,
+// along with a context bound to it. If ctx is bound to a span, the returned span
+// is its child. Otherwise the returned span is a new trace, which continues the
+// trace of the publisher of the message if its headers have its metadata.
+func BeginConsumeSpan(ctx context.Context, msg *sarama.ConsumerMessage) (ao.Span, context.Context) {
+	var headers []ao.MessageHeader
+	for _, h := range msg.Headers {
+		if h != nil {
+			headers = append(headers, ao.MessageHeader{Key: h.Key, Value: h.Value})
+		}
+	}
+	return ao.BeginConsumeSpan(ctx, consumeSpanName, ao.ExtractMetadataHeaders(headers),
+		system, msg.Topic, "", len(msg.Value), "Partition", msg.Partition, "Offset", msg.Offset)
+}
+
+func toMessageHeaders(headers []sarama.RecordHeader) []ao.MessageHeader {
+	var mh []ao.MessageHeader
+	for _, h := range headers {
+		mh = append(mh, ao.MessageHeader{Key: h.Key, Value: h.Value})
+	}
+	return mh
+}
+
+func fromMessageHeaders(headers []ao.MessageHeader) []sarama.RecordHeader {
+	var rh []sarama.RecordHeader
+	for _, h := range headers {
+		rh = append(rh, sarama.RecordHeader{Key: h.Key, Value: h.Value})
+	}
+	return rh
+}
@@ -0,0 +1,119 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package aosarama
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	"github.com/appoptics/appoptics-apm-go/v1/ao/aotest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testProducer struct {
+	sarama.SyncProducer
+	sent []*sarama.ProducerMessage
+	err  error
+}
+
+func (p *testProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	p.sent = append(p.sent, msg)
+	if p.err != nil {
+		return -1, -1, p.err
+	}
+	return 1, int64(len(p.sent)), nil
+}
+
+func TestSendMessage(t *testing.T) {
+	rec := aotest.Record()
+	ctx := ao.NewContext(context.Background(), ao.NewTrace("producer"))
+	p := &testProducer{}
+	msg := &sarama.ProducerMessage{Topic: "orders", Value: sarama.StringEncoder("order")}
+	partition, offset, err := SendMessage(ctx, p, msg)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), partition)
+	assert.Equal(t, int64(1), offset)
+	assert.Equal(t, []*sarama.ProducerMessage{msg}, p.sent)
+
+	p.err = errors.New("kafka: failed to produce message")
+	_, _, err = SendMessage(ctx, p, &sarama.ProducerMessage{Topic: "orders"})
+	assert.Equal(t, p.err, err)
+	ao.EndTrace(ctx)
+
+	traces := rec.Stop(7)
+	producer := aotest.AssertSpan(t, traces, "producer")
+	require.Len(t, producer.Children, 2)
+	sent, failed := producer.Children[0], producer.Children[1]
+	assert.Equal(t, publishSpanName, sent.Name)
+	aotest.AssertKV(t, sent, "Spec", "msgclient")
+	aotest.AssertKV(t, sent, "Op", "publish")
+	aotest.AssertKV(t, sent, "Flavor", system)
+	aotest.AssertKV(t, sent, "Destination", "orders")
+	aotest.AssertKV(t, sent, "PayloadSize", len("order"))
+	aotest.AssertKV(t, sent, "Partition", 1)
+	aotest.AssertKV(t, sent, "Offset", 1)
+
+	// the metadata of the publish span is sent in the headers of the message
+	require.Len(t, msg.Headers, 1)
+	assert.Equal(t, ao.HTTPHeaderName, string(msg.Headers[0].Key))
+	md := string(msg.Headers[0].Value)
+	assert.Equal(t, traces[0].ID, md[2:42])
+	assert.Equal(t, sent.ID, md[42:58])
+
+	assert.Equal(t, publishSpanName, failed.Name)
+	assert.NotContains(t, failed.KVs, "PayloadSize")
+	assert.NotContains(t, failed.KVs, "Partition")
+	aotest.AssertError(t, failed, "error", p.err.Error())
+}
+
+func TestBeginConsumeSpan(t *testing.T) {
+	rec := aotest.Record()
+	ctx := ao.NewContext(context.Background(), ao.NewTrace("producer"))
+	pmsg := &sarama.ProducerMessage{Topic: "orders", Value: sarama.StringEncoder("order"),
+		Headers: []sarama.RecordHeader{{Key: []byte("k"), Value: []byte("v")}}}
+	BeginPublishSpan(ctx, pmsg).End()
+	ao.EndTrace(ctx)
+
+	// the consumer receives the headers sent by the producer
+	msg := &sarama.ConsumerMessage{Topic: "orders", Value: []byte("order"), Partition: 2, Offset: 7,
+		Headers: []*sarama.RecordHeader{nil}}
+	for i := range pmsg.Headers {
+		msg.Headers = append(msg.Headers, &pmsg.Headers[i])
+	}
+	l, cctx := BeginConsumeSpan(context.Background(), msg)
+	assert.Equal(t, l, ao.TraceFromContext(cctx))
+	l.End()
+
+	traces := rec.Stop(6)
+	require.Len(t, pmsg.Headers, 2)
+	md := string(pmsg.Headers[1].Value)
+
+	// the consumer continues the trace of the producer
+	require.Len(t, traces, 1)
+	publish := aotest.AssertSpan(t, traces, publishSpanName)
+	consume := aotest.AssertSpan(t, traces, consumeSpanName)
+	assert.Equal(t, publish.ID, md[42:58])
+	assert.True(t, consume.Ended)
+	aotest.AssertKV(t, consume, "Op", "consume")
+	aotest.AssertKV(t, consume, "Flavor", system)
+	aotest.AssertKV(t, consume, "Destination", "orders")
+	aotest.AssertKV(t, consume, "PayloadSize", len("order"))
+	aotest.AssertKV(t, consume, "Partition", 2)
+	aotest.AssertKV(t, consume, "Offset", 7)
+	aotest.AssertKV(t, consume, "Link", md)
+}
+
+func TestMessageHeaders(t *testing.T) {
+	headers := []sarama.RecordHeader{{Key: []byte("k"), Value: []byte("v")}}
+	mh := ao.InjectMetadataHeaders(toMessageHeaders(headers), "md")
+	assert.Equal(t, []sarama.RecordHeader{
+		{Key: []byte("k"), Value: []byte("v")},
+		{Key: []byte(ao.HTTPHeaderName), Value: []byte("md")},
+	}, fromMessageHeaders(mh))
+	assert.Nil(t, toMessageHeaders(nil))
+	assert.Nil(t, fromMessageHeaders(nil))
+}
@@ -0,0 +1,68 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+// Package router contains the helpers shared by the AppOptics middleware for
+// third-party HTTP routers.
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+)
+
+// FuncName returns the fully-qualified name of the function f, e.g.
+// "github.com/appoptics/appoptics-apm-go/v1/contrib/aogin.handler", or an
+// empty string if f is not a function.
+func FuncName(f interface{}) string {
+	v := reflect.ValueOf(f)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return ""
+	}
+	if fn := runtime.FuncForPC(v.Pointer()); fn != nil {
+		return fn.Name()
+	}
+	return ""
+}
+
+// EndArgs returns the Controller and Action KV pairs derived from the
+// fully-qualified function name fname, in the same way as ao.HTTPHandler does:
+// the package name is the controller and the remaining part is the action.
+func EndArgs(fname string) []interface{} {
+	if s := strings.SplitN(fname[strings.LastIndex(fname, "/")+1:], ".", 2); len(s) == 2 {
+		return []interface{}{"Controller", s[0], "Action", s[1]}
+	}
+	return nil
+}
+
+// TransactionName returns the transaction name of a request matched by the
+// route template, e.g. "GET /users/:id".
+func TransactionName(method, route string) string {
+	return method + " " + route
+}
+
+// SetTransactionName names the trace after the matched route template, unless
+// the route is unknown or a custom transaction name has already been set by
+// the handler.
+func SetTransactionName(t ao.Trace, method, route string) {
+	if route == "" || t.GetTransactionName() != "" {
+		return
+	}
+	t.SetTransactionName(TransactionName(method, route))
+}
+
+// ReportPanic catches and reports a panic, if one occurs, and then re-raises
+// it. It must be deferred directly (defer router.ReportPanic(t, w)) after the
+// deferred call ending the trace, so the panic is reported before the trace ends.
+func ReportPanic(t ao.Trace, w *ao.HTTPResponseWriter) {
+	if err := recover(); err != nil {
+		t.Error("panic", fmt.Sprintf("%v", err))
+		if !w.WroteHeader {
+			w.StatusCode = http.StatusInternalServerError
+		}
+		panic(err) // re-raise the panic
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright (C) 2018 Librato, Inc. All rights reserved.
+
+package router
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/appoptics/appoptics-apm-go/v1/ao"
+	"github.com/stretchr/testify/assert"
+)
+
+type ctrl struct{}
+
+func (c *ctrl) get(w http.ResponseWriter, r *http.Request) {}
+func handler(w http.ResponseWriter, r *http.Request)       {}
+
+func TestFuncName(t *testing.T) {
+	assert.Equal(t, "github.com/appoptics/appoptics-apm-go/v1/contrib/internal/router.handler",
+		FuncName(handler))
+	assert.Equal(t, "github.com/appoptics/appoptics-apm-go/v1/contrib/internal/router.handler",
+		FuncName(http.HandlerFunc(handler)))
+	assert.Equal(t, "", FuncName(nil))
+	assert.Equal(t, "", FuncName(&ctrl{}))
+	var nilFunc http.HandlerFunc
+	assert.Equal(t, "", FuncName(nilFunc))
+}
+
+func TestEndArgs(t *testing.T) {
+	assert.Equal(t, []interface{}{"Controller", "router", "Action", "handler"},
+		EndArgs("github.com/appoptics/appoptics-apm-go/v1/contrib/internal/router.handler"))
+	assert.Equal(t, []interface{}{"Controller", "main", "Action", "(*ctrl).get-fm"},
+		EndArgs("main.(*ctrl).get-fm"))
+	assert.Equal(t, []interface{}{"Controller", "main", "Action", "main.func1"},
+		EndArgs("main.main.func1"))
+	assert.Nil(t, EndArgs(""))
+	assert.Nil(t, EndArgs("handler"))
+}
+
+func TestTransactionName(t *testing.T) {
+	assert.Equal(t, "GET /users/:id", TransactionName("GET", "/users/:id"))
+	assert.Equal(t, "POST /users/{id}", TransactionName("POST", "/users/{id}"))
+}
+
+func TestReportPanic(t *testing.T) {
+	w := &ao.HTTPResponseWriter{StatusCode: http.StatusOK}
+	assert.PanicsWithValue(t, "panicking!", func() {
+		defer ReportPanic(ao.NewNullTrace(), w)
+		panic("panicking!")
+	})
+	assert.Equal(t, http.StatusInternalServerError, w.StatusCode)
+
+	// the status written to the response is kept
+	w = &ao.HTTPResponseWriter{StatusCode: http.StatusTeapot, WroteHeader: true}
+	assert.Panics(t, func() {
+		defer ReportPanic(ao.NewNullTrace(), w)
+		panic("panicking!")
+	})
+	assert.Equal(t, http.StatusTeapot, w.StatusCode)
+
+	// no panic
+	w = &ao.HTTPResponseWriter{StatusCode: http.StatusOK}
+	assert.NotPanics(t, func() {
+		defer ReportPanic(ao.NewNullTrace(), w)
+	})
+	assert.Equal(t, http.StatusOK, w.StatusCode)
+}